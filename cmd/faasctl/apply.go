@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+)
+
+type planStep struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Detail string `json:"detail"`
+}
+
+func runApply(ctx *cliContext, args []string) error {
+	fs := newFlagSet(ctx, "apply")
+	file := fs.String("f", "", "manifest file (YAML or JSON)")
+	dryRun := fs.Bool("dry-run", false, "only print the plan")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) != 0 || *file == "" {
+		return errUsage
+	}
+
+	manifest, err := loadManifest(*file)
+	if err != nil {
+		return err
+	}
+	c, err := ctx.client()
+	if err != nil {
+		return err
+	}
+
+	path := "/api/apply"
+	if *dryRun {
+		path += "?dry_run=true"
+	}
+	var resp struct {
+		FuncName string     `json:"funcName"`
+		Version  string     `json:"version"`
+		DryRun   bool       `json:"dryRun"`
+		Changed  bool       `json:"changed"`
+		Plan     []planStep `json:"plan"`
+	}
+	if err := c.do("POST", path, nil, manifest, &resp); err != nil {
+		return err
+	}
+	if ctx.jsonOutput() {
+		return printJSON(resp)
+	}
+
+	if !resp.Changed {
+		fmt.Printf("%s is up to date (version %s)\n", resp.FuncName, resp.Version)
+	}
+	if len(resp.Plan) == 0 {
+		return nil
+	}
+	rows := make([][]string, 0, len(resp.Plan))
+	for _, step := range resp.Plan {
+		rows = append(rows, []string{step.Action, step.Target, step.Detail})
+	}
+	printTable([]string{"ACTION", "TARGET", "DETAIL"}, rows)
+	if !resp.Changed {
+		return nil
+	}
+	if resp.DryRun {
+		fmt.Println("\ndry run: no changes applied")
+	} else {
+		fmt.Printf("\napplied, %s is at version %s\n", resp.FuncName, resp.Version)
+	}
+	return nil
+}
+
+// loadManifest 读取 manifest，并把 entry 指向的文件（相对 manifest 所在目录）读入 code；
+// wasm 运行时的 wasm 字段为二进制文件路径，读入后以 base64 发送，entry（胶水脚本）可省略
+func loadManifest(file string) (map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var manifest map[string]any
+	switch filepath.Ext(file) {
+	case ".json":
+		err = json.Unmarshal(data, &manifest)
+	default:
+		err = yaml.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+
+	if manifest["runtime"] == "wasm" {
+		wasmPath, _ := manifest["wasm"].(string)
+		if wasmPath == "" {
+			return nil, fmt.Errorf("manifest with runtime wasm must set wasm")
+		}
+		wasm, err := os.ReadFile(filepath.Join(filepath.Dir(file), wasmPath))
+		if err != nil {
+			return nil, fmt.Errorf("read wasm: %w", err)
+		}
+		manifest["wasm"] = wasm
+	}
+
+	if _, hasCode := manifest["code"]; !hasCode {
+		entry, _ := manifest["entry"].(string)
+		if entry == "" && manifest["runtime"] == "wasm" {
+			return manifest, nil
+		}
+		if entry == "" {
+			return nil, fmt.Errorf("manifest must set entry or code")
+		}
+		code, err := os.ReadFile(filepath.Join(filepath.Dir(file), entry))
+		if err != nil {
+			return nil, fmt.Errorf("read entry: %w", err)
+		}
+		manifest["code"] = string(code)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiClient 部署 API 客户端
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newAPIClient(p Profile) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimRight(p.URL, "/"),
+		token:   p.Token,
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (c *apiClient) newRequest(method, path string, query url.Values, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Deploy-Token", c.token)
+	}
+	return req, nil
+}
+
+// do 发送 JSON 请求并把响应 JSON 解析到 out
+func (c *apiClient) do(method, path string, query url.Values, body, out any) error {
+	req, err := c.newRequest(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	return c.send(req, out)
+}
+
+// upload 以 multipart/form-data 上传文件（files 为部分名 -> 本地路径，metadata 为 JSON 字段）
+func (c *apiClient) upload(path string, metadata any, files map[string]string, out any) error {
+	return c.uploadForm(path, metadata, func(mw *multipart.Writer) error {
+		for name, filePath := range files {
+			if err := writeFilePart(mw, name, filePath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, out)
+}
+
+// uploadData 与 upload 相同，文件内容来自内存（部分名 -> 文件名与内容）
+func (c *apiClient) uploadData(path string, metadata any, name, fileName string, data []byte, out any) error {
+	return c.uploadForm(path, metadata, func(mw *multipart.Writer) error {
+		part, err := mw.CreateFormFile(name, fileName)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(data)
+		return err
+	}, out)
+}
+
+// uploadForm 写入 metadata 字段后由 writeParts 写入文件部分，再发送请求
+func (c *apiClient) uploadForm(path string, metadata any, writeParts func(*multipart.Writer) error, out any) error {
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.WriteField("metadata", string(meta)); err != nil {
+		return err
+	}
+	if err := writeParts(mw); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(http.MethodPost, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(&buf)
+	req.ContentLength = int64(buf.Len())
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return c.send(req, out)
+}
+
+func writeFilePart(mw *multipart.Writer, name, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	part, err := mw.CreateFormFile(name, filepath.Base(filePath))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, f)
+	return err
+}
+
+// send 发送请求并把响应 JSON 解析到 out；非 2xx 时返回服务端的 error 字段
+func (c *apiClient) send(req *http.Request, out any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError(resp.StatusCode, data)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// raw 发送二进制请求体（contentType 为空时无请求体），把 2xx 响应体写入 out（可为 nil）
+func (c *apiClient) raw(method, path, contentType string, body io.Reader, out io.Writer) error {
+	req, err := c.newRequest(method, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Body = io.NopCloser(body)
+		req.Header.Set("Content-Type", contentType)
+	}
+	client := &http.Client{} // 数据库文件可能较大，不设置超时
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(resp.Body)
+		return responseError(resp.StatusCode, data)
+	}
+	if out == nil {
+		return nil
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// responseError 返回服务端的 error 字段（v1 为字符串，v2 为 {"code", "message"}）
+func responseError(status int, data []byte) error {
+	var apiErr struct {
+		Error       string `json:"error"`
+		Diagnostics []struct {
+			File    string `json:"file"`
+			Line    int    `json:"line"`
+			Column  int    `json:"column"`
+			Message string `json:"message"`
+		} `json:"diagnostics"`
+		Start *struct {
+			File   string `json:"file"`
+			Line   int    `json:"line"`
+			Column int    `json:"column"`
+			Source string `json:"source"`
+			Output string `json:"output"`
+		} `json:"start"`
+	}
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+		msg := apiErr.Error
+		for _, d := range apiErr.Diagnostics { // TypeScript 编译错误逐条列出
+			msg += fmt.Sprintf("\n  %s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+		}
+		if start := apiErr.Start; start != nil { // 新版本启动失败时附上出错位置与实例输出
+			if start.File != "" {
+				msg += fmt.Sprintf("\n  at %s:%d:%d", start.File, start.Line, start.Column)
+				if start.Source != "" {
+					msg += "\n    " + start.Source
+				}
+			}
+			if start.Output != "" {
+				msg += "\n" + strings.TrimRight(start.Output, "\n")
+			}
+		}
+		return fmt.Errorf("%s (HTTP %d)", msg, status)
+	}
+	var v2Err struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &v2Err) == nil && v2Err.Error.Message != "" {
+		return fmt.Errorf("%s (HTTP %d)", v2Err.Error.Message, status)
+	}
+	return fmt.Errorf("HTTP %d: %s", status, strings.TrimSpace(string(data)))
+}
+
+// stream 读取 SSE 响应，对每个 data 行调用 fn
+func (c *apiClient) stream(path string, query url.Values, fn func(event, data string)) error {
+	req, err := c.newRequest(http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	client := &http.Client{} // 长连接，不设置超时
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			fn(event, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "":
+			event = ""
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 目录部署时默认查找的入口文件
+var defaultEntries = []string{"index.js", "worker.js", "main.js", "index.ts", "worker.ts"}
+
+func runDeploy(ctx *cliContext, args []string) error {
+	fs := newFlagSet(ctx, "deploy")
+	version := fs.String("version", "", "version (default: timestamp)")
+	alias := fs.String("alias", "", "alias to point at the new version")
+	entry := fs.String("entry", "", "entry file when deploying a directory or archive")
+	runtime := fs.String("runtime", "", "js, ts, wasm or exec (default: by entry file extension)")
+	glue := fs.String("glue", "", "JS glue script for a .wasm deployment (default: built-in WASI glue)")
+	smokeTest := fs.String("smoke-test", "", "path to GET on the new version before switching traffic (must return 2xx)")
+	memory := fs.Int("memory", 0, "memory limit in MiB (default: unlimited)")
+	cpu := fs.Float64("cpu", 0, "CPU quota in cores, e.g. 0.5 (default: unlimited)")
+	pids := fs.Int("pids", 0, "max processes/threads (default: unlimited)")
+	openFiles := fs.Int("open-files", 0, "max open files (default: unlimited)")
+	sandbox := fs.String("sandbox", "", "run in an isolated sandbox: on or off (default: server setting)")
+	network := fs.String("network", "", "sandbox network: host or isolated (default: server setting)")
+	egress := fs.String("egress", "", "outbound policy: allow (only --egress-rule targets), deny (all but --egress-rule targets) or none (default: unrestricted)")
+	var rules egressRules
+	fs.Var(&rules, "egress-rule", "egress target host[:port,...], host may be a domain, *.domain, IP or CIDR (repeatable)")
+	var services serviceBindings
+	fs.Var(&services, "service", "service binding NAME=function[@alias], called as env.NAME.fetch() (repeatable)")
+	var durable durableObjects
+	fs.Var(&durable, "durable-object", "durable object class BINDING=Class, append :OldClass to keep the data of a renamed class (repeatable)")
+	var deletedClasses stringList
+	fs.Var(&deletedClasses, "delete-durable-object", "durable object class removed in this version, its data is deleted (repeatable)")
+	database := fs.String("database", "", "binding name of the function's private SQLite database, e.g. DB")
+	env := keyValues{}
+	fs.Var(env, "env", "environment variable KEY=VALUE (repeatable)")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) != 2 {
+		return errUsage
+	}
+	funcName, path := pos[0], pos[1]
+
+	c, err := ctx.client()
+	if err != nil {
+		return err
+	}
+	body := map[string]any{
+		"runtime":  detectRuntime(*runtime, *entry),
+		"version":  *version,
+		"alias":    *alias,
+		"env_vars": env,
+	}
+	if *smokeTest != "" {
+		body["smoke_test"] = map[string]any{"path": *smokeTest}
+	}
+	if *memory > 0 || *cpu > 0 || *pids > 0 || *openFiles > 0 {
+		body["resources"] = map[string]any{"memory_mb": *memory, "cpu": *cpu, "pids": *pids, "open_files": *openFiles}
+	}
+	if *sandbox != "" || *network != "" {
+		body["sandbox"] = map[string]any{"mode": *sandbox, "network": *network}
+	}
+	if *egress != "" || len(rules) > 0 {
+		body["egress"] = map[string]any{"mode": *egress, "rules": rules}
+	}
+	if len(services) > 0 {
+		body["services"] = services
+	}
+	if len(durable) > 0 || len(deletedClasses) > 0 {
+		body["durable_objects"] = map[string]any{"classes": durable, "deleted": deletedClasses}
+	}
+	if *database != "" {
+		body["database"] = map[string]any{"binding": *database}
+	}
+	var resp map[string]any
+
+	// 压缩包直接上传，由服务端解压为多模块版本
+	if isArchive(path) {
+		body["entry"] = *entry
+		if err := c.upload("/api/deploy/"+url.PathEscape(funcName), body, map[string]string{"archive": path}, &resp); err != nil {
+			return err
+		}
+		return printResult(ctx, resp)
+	}
+
+	// WebAssembly 二进制以文件上传，胶水脚本可选
+	if detectRuntime(*runtime, path) == "wasm" {
+		body["runtime"] = "wasm"
+		files := map[string]string{"wasm": path}
+		if *glue != "" {
+			files["glue"] = *glue
+		}
+		if err := c.upload("/api/deploy/"+url.PathEscape(funcName), body, files, &resp); err != nil {
+			return err
+		}
+		return printResult(ctx, resp)
+	}
+
+	// 目录在内存中打包为 zip 上传，入口引用的其他模块与资源文件一并部署
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entryName, err := directoryEntry(path, *entry)
+		if err != nil {
+			return err
+		}
+		data, err := packDirectory(path)
+		if err != nil {
+			return err
+		}
+		body["entry"] = entryName
+		body["runtime"] = detectRuntime(*runtime, entryName)
+		if err := c.uploadData("/api/deploy/"+url.PathEscape(funcName), body, "archive", filepath.Base(filepath.Clean(path))+".zip", data, &resp); err != nil {
+			return err
+		}
+		return printResult(ctx, resp)
+	}
+	codePath := path
+
+	// 可执行文件以文件上传（可能是二进制，不能放进 JSON）
+	if *runtime == "exec" {
+		if err := c.upload("/api/deploy/"+url.PathEscape(funcName), body, map[string]string{"executable": codePath}, &resp); err != nil {
+			return err
+		}
+		return printResult(ctx, resp)
+	}
+
+	code, err := os.ReadFile(codePath)
+	if err != nil {
+		return fmt.Errorf("read code: %w", err)
+	}
+	body["code"] = string(code)
+	body["runtime"] = detectRuntime(*runtime, codePath)
+	if err := c.do("POST", "/api/deploy/"+url.PathEscape(funcName), nil, body, &resp); err != nil {
+		return err
+	}
+	return printResult(ctx, resp)
+}
+
+// isArchive 按扩展名判断是否为压缩包
+func isArchive(path string) bool {
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(strings.ToLower(path), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectRuntime 未指定 --runtime 时按入口文件扩展名判断：.ts / .mts 为 TypeScript，.wasm 为 WebAssembly
+func detectRuntime(runtime, entry string) string {
+	if runtime != "" {
+		return runtime
+	}
+	switch filepath.Ext(entry) {
+	case ".ts", ".mts":
+		return "ts"
+	case ".wasm":
+		return "wasm"
+	}
+	return "js"
+}
+
+// directoryEntry 确定目录部署的入口（相对目录的 / 分隔路径）：--entry > faas.json 的 entry >
+// package.json 的 main（与服务端解析压缩包的顺序相同）> 默认入口 > 目录下唯一的 .js / .ts 文件
+func directoryEntry(dir, entry string) (string, error) {
+	if entry == "" {
+		entry = manifestField(filepath.Join(dir, "faas.json"), "entry")
+	}
+	if entry == "" {
+		entry = manifestField(filepath.Join(dir, "package.json"), "main")
+	}
+	if entry != "" {
+		entry = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(entry)), "./")
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(entry))); err != nil {
+			return "", fmt.Errorf("entry %s: %w", entry, err)
+		}
+		return entry, nil
+	}
+	for _, name := range defaultEntries {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return name, nil
+		}
+	}
+	for _, pattern := range []string{"*.js", "*.ts"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		if len(matches) == 1 {
+			return filepath.Base(matches[0]), nil
+		}
+	}
+	return "", fmt.Errorf("cannot determine entry file in %s, use --entry", dir)
+}
+
+// manifestField 读取 JSON 清单中的字符串字段，文件不存在或无法解析时返回空
+func manifestField(file, field string) string {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	var manifest map[string]any
+	if json.Unmarshal(data, &manifest) != nil {
+		return ""
+	}
+	value, _ := manifest[field].(string)
+	return value
+}
+
+// packDirectory 将目录打包为 zip（跳过 .git、.env 等隐藏文件与 node_modules），保留文件权限
+func packDirectory(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s: only regular files can be deployed", p)
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name, hdr.Method = filepath.ToSlash(rel), zip.Deflate
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pack %s: %w", dir, err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func runList(ctx *cliContext, args []string) error {
+	pos, err := parseArgs(newFlagSet(ctx, "list"), args)
+	if err != nil || len(pos) != 1 {
+		return errUsage
+	}
+	funcName := pos[0]
+	c, err := ctx.client()
+	if err != nil {
+		return err
+	}
+
+	var list struct {
+		FuncName string   `json:"funcName"`
+		Versions []string `json:"versions"`
+	}
+	if err := c.do("GET", "/api/list/"+url.PathEscape(funcName), nil, nil, &list); err != nil {
+		return err
+	}
+	aliases, err := fetchAliases(c, funcName)
+	if err != nil {
+		return err
+	}
+	if ctx.jsonOutput() {
+		return printJSON(map[string]any{"funcName": funcName, "versions": list.Versions, "aliases": aliases})
+	}
+
+	// 版本 -> 指向它的别名
+	byVersion := make(map[string][]string)
+	for alias, version := range aliases {
+		byVersion[version] = append(byVersion[version], alias)
+	}
+	rows := make([][]string, 0, len(list.Versions))
+	for _, v := range list.Versions {
+		sort.Strings(byVersion[v])
+		rows = append(rows, []string{v, strings.Join(byVersion[v], ",")})
+	}
+	printTable([]string{"VERSION", "ALIASES"}, rows)
+	return nil
+}
+
+func runRollback(ctx *cliContext, args []string) error {
+	fs := newFlagSet(ctx, "rollback")
+	alias := fs.String("alias", "", "alias to move (default: the version's own alias)")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) != 2 {
+		return errUsage
+	}
+	return postAndPrint(ctx, "/api/rollback/"+url.PathEscape(pos[0]),
+		map[string]any{"version": pos[1], "alias": *alias})
+}
+
+func runStop(ctx *cliContext, args []string) error {
+	pos, err := parseArgs(newFlagSet(ctx, "stop"), args)
+	if err != nil || len(pos) != 2 {
+		return errUsage
+	}
+	return postAndPrint(ctx, "/api/stop/"+url.PathEscape(pos[0]), map[string]any{"version": pos[1]})
+}
+
+func runDelete(ctx *cliContext, args []string) error {
+	fs := newFlagSet(ctx, "delete")
+	version := fs.String("version", "", "delete only this version")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) != 1 {
+		return errUsage
+	}
+	if *version != "" {
+		return postAndPrint(ctx, "/api/deleteVersion/"+url.PathEscape(pos[0]), map[string]any{"version": *version})
+	}
+	return postAndPrint(ctx, "/api/delete/"+url.PathEscape(pos[0]), nil)
+}
+
+type logLine struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+func runLogs(ctx *cliContext, args []string) error {
+	fs := newFlagSet(ctx, "logs")
+	version := fs.String("version", "", "version (default: latest)")
+	since := fs.String("since", "", "RFC3339 time or duration like 10m")
+	limit := fs.Int("limit", 100, "number of lines")
+	follow := fs.Bool("f", false, "follow new lines")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) != 1 {
+		return errUsage
+	}
+	c, err := ctx.client()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *version != "" {
+		query.Set("version", *version)
+	}
+	query.Set("limit", fmt.Sprint(*limit))
+	path := "/api/logs/" + url.PathEscape(pos[0])
+
+	if *follow {
+		return c.stream(path+"/tail", query, func(event, data string) {
+			if event != "log" {
+				return
+			}
+			var line logLine
+			if json.Unmarshal([]byte(data), &line) != nil {
+				return
+			}
+			printLogLine(ctx, line)
+		})
+	}
+
+	if *since != "" {
+		query.Set("since", *since)
+	}
+	var resp struct {
+		Lines []logLine `json:"lines"`
+	}
+	if err := c.do("GET", path, query, nil, &resp); err != nil {
+		return err
+	}
+	for _, line := range resp.Lines {
+		printLogLine(ctx, line)
+	}
+	return nil
+}
+
+func printLogLine(ctx *cliContext, line logLine) {
+	if ctx.jsonOutput() {
+		data, _ := json.Marshal(line)
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("%s %s\n", line.Time.Local().Format(time.RFC3339), line.Line)
+}
+
+func runEnv(ctx *cliContext, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+	fs := newFlagSet(ctx, "env "+sub)
+	version := fs.String("version", "", "version to read (list) or create (set/unset)")
+	alias := fs.String("alias", "", "alias for the new version (set/unset)")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) < 1 {
+		return errUsage
+	}
+	funcName := url.PathEscape(pos[0])
+
+	switch sub {
+	case "list":
+		c, err := ctx.client()
+		if err != nil {
+			return err
+		}
+		query := url.Values{}
+		if *version != "" {
+			query.Set("version", *version)
+		}
+		var resp struct {
+			Version string   `json:"version"`
+			Keys    []string `json:"keys"`
+		}
+		if err := c.do("GET", "/api/env/"+funcName, query, nil, &resp); err != nil {
+			return err
+		}
+		if ctx.jsonOutput() {
+			return printJSON(resp)
+		}
+		rows := make([][]string, 0, len(resp.Keys))
+		for _, k := range resp.Keys {
+			rows = append(rows, []string{k, resp.Version})
+		}
+		printTable([]string{"KEY", "VERSION"}, rows)
+		return nil
+	case "set":
+		set := keyValues{}
+		for _, kv := range pos[1:] {
+			if err := set.Set(kv); err != nil {
+				return err
+			}
+		}
+		if len(set) == 0 {
+			return errUsage
+		}
+		return postAndPrint(ctx, "/api/env/"+funcName,
+			map[string]any{"set": set, "version": *version, "alias": *alias})
+	case "unset":
+		if len(pos) < 2 {
+			return errUsage
+		}
+		return postAndPrint(ctx, "/api/env/"+funcName,
+			map[string]any{"unset": pos[1:], "version": *version, "alias": *alias})
+	}
+	return errUsage
+}
+
+func runAlias(ctx *cliContext, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+	pos, err := parseArgs(newFlagSet(ctx, "alias "+sub), args)
+	if err != nil || len(pos) < 1 {
+		return errUsage
+	}
+	funcName := pos[0]
+
+	switch sub {
+	case "list":
+		c, err := ctx.client()
+		if err != nil {
+			return err
+		}
+		aliases, err := fetchAliases(c, funcName)
+		if err != nil {
+			return err
+		}
+		if ctx.jsonOutput() {
+			return printJSON(aliases)
+		}
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rows := make([][]string, 0, len(names))
+		for _, name := range names {
+			rows = append(rows, []string{name, aliases[name]})
+		}
+		printTable([]string{"ALIAS", "VERSION"}, rows)
+		return nil
+	case "set":
+		if len(pos) != 3 {
+			return errUsage
+		}
+		return postAndPrint(ctx, "/api/alias/"+url.PathEscape(funcName),
+			map[string]any{"alias": pos[1], "version": pos[2]})
+	case "delete":
+		if len(pos) != 2 {
+			return errUsage
+		}
+		return postAndPrint(ctx, "/api/deleteAlias/"+url.PathEscape(funcName), map[string]any{"alias": pos[1]})
+	}
+	return errUsage
+}
+
+func runConfig(ctx *cliContext, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+	fs := newFlagSet(ctx, "config "+sub)
+	apiURL := fs.String("url", "", "API URL, e.g. http://your-host:8081")
+	token := fs.String("token", "", "deploy token")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return errUsage
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "view":
+		// 隐藏 token
+		masked := Config{Current: cfg.Current, Profiles: make(map[string]Profile)}
+		for name, p := range cfg.Profiles {
+			if p.Token != "" {
+				p.Token = "****"
+			}
+			masked.Profiles[name] = p
+		}
+		return printJSON(masked)
+	case "use":
+		if len(pos) != 1 {
+			return errUsage
+		}
+		if _, ok := cfg.Profiles[pos[0]]; !ok {
+			return fmt.Errorf("profile %q not found", pos[0])
+		}
+		cfg.Current = pos[0]
+	case "set-profile":
+		if len(pos) != 1 || *apiURL == "" {
+			return errUsage
+		}
+		cfg.Profiles[pos[0]] = Profile{URL: *apiURL, Token: *token}
+		if cfg.Current == "" {
+			cfg.Current = pos[0]
+		}
+	default:
+		return errUsage
+	}
+	if err := saveConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Printf("config saved to %s\n", configPath())
+	return nil
+}
+
+func fetchAliases(c *apiClient, funcName string) (map[string]string, error) {
+	var resp struct {
+		Aliases map[string]string `json:"aliases"`
+	}
+	if err := c.do("GET", "/api/aliases/"+url.PathEscape(funcName), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Aliases, nil
+}
+
+func postAndPrint(ctx *cliContext, path string, body any) error {
+	c, err := ctx.client()
+	if err != nil {
+		return err
+	}
+	var resp map[string]any
+	if err := c.do("POST", path, nil, body, &resp); err != nil {
+		return err
+	}
+	return printResult(ctx, resp)
+}
+
+func printResult(ctx *cliContext, resp map[string]any) error {
+	if ctx.jsonOutput() {
+		return printJSON(resp)
+	}
+	printKV(os.Stdout, resp)
+	return nil
+}
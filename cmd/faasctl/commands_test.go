@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"faas/internal/archive"
+)
+
+// writeTree 在 dir 下按相对路径创建文件
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeployDirectory(t *testing.T) {
+	var metadata map[string]any
+	var uploaded map[string][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/deploy/hello" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.Unmarshal([]byte(r.FormValue("metadata")), &metadata); err != nil {
+			t.Errorf("metadata: %v", err)
+		}
+		f, _, err := r.FormFile("archive")
+		if err != nil {
+			t.Errorf("archive part: %v", err)
+			return
+		}
+		defer f.Close()
+		data, _ := io.ReadAll(f)
+		if uploaded, err = archive.Extract(data, archive.DefaultLimits); err != nil {
+			t.Errorf("extract: %v", err)
+		}
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer srv.Close()
+	useConfig(t, nil)
+	t.Setenv("FAAS_API_URL", srv.URL)
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		args        []string
+		wantEntry   string
+		wantRuntime string
+		wantFiles   []string
+	}{
+		{
+			name: "sibling modules",
+			files: map[string]string{
+				"index.ts": `import { greet } from "./util.js";`, "util.ts": "", "lib/deep.ts": "",
+				".git/HEAD": "", ".env": "SECRET=1", "node_modules/x/index.js": "",
+			},
+			wantEntry:   "index.ts",
+			wantRuntime: "ts",
+			wantFiles:   []string{"index.ts", "lib/deep.ts", "util.ts"},
+		},
+		{
+			name:        "faas.json entry",
+			files:       map[string]string{"faas.json": `{"entry": "./src/app.js"}`, "src/app.js": "", "index.js": ""},
+			wantEntry:   "src/app.js",
+			wantRuntime: "js",
+			wantFiles:   []string{"faas.json", "index.js", "src/app.js"},
+		},
+		{
+			name:        "package.json main",
+			files:       map[string]string{"package.json": `{"main": "server.mjs"}`, "server.mjs": ""},
+			wantEntry:   "server.mjs",
+			wantRuntime: "js",
+			wantFiles:   []string{"package.json", "server.mjs"},
+		},
+		{
+			name:        "entry flag",
+			files:       map[string]string{"faas.json": `{"entry": "a.js"}`, "a.js": "", "main.py": ""},
+			args:        []string{"--entry", "main.py", "--runtime", "exec"},
+			wantEntry:   "main.py",
+			wantRuntime: "exec",
+			wantFiles:   []string{"a.js", "faas.json", "main.py"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, tt.files)
+			metadata, uploaded = nil, nil
+			args := append([]string{"hello", dir}, tt.args...)
+			if err := runDeploy(&cliContext{output: "json"}, args); err != nil {
+				t.Fatal(err)
+			}
+			if metadata["entry"] != tt.wantEntry || metadata["runtime"] != tt.wantRuntime {
+				t.Fatalf("metadata = %v", metadata)
+			}
+			names := make([]string, 0, len(uploaded))
+			for name := range uploaded {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.wantFiles) {
+				t.Fatalf("uploaded %v, want %v", names, tt.wantFiles)
+			}
+		})
+	}
+
+	if err := runDeploy(&cliContext{}, []string{"hello", t.TempDir()}); err == nil {
+		t.Fatal("deploying a directory without an entry succeeded")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const defaultAPIURL = "http://127.0.0.1:8081"
+
+// Profile 一组 API 连接配置
+type Profile struct {
+	URL   string `json:"url"`   // 部署 API 地址
+	Token string `json:"token"` // X-Deploy-Token
+}
+
+// Config 配置文件（默认 ~/.faas/config.json）
+type Config struct {
+	Current  string             `json:"current"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// configPath 配置文件路径，可通过 FAAS_CONFIG 覆盖
+func configPath() string {
+	if p := os.Getenv("FAAS_CONFIG"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".faas", "config.json")
+	}
+	return filepath.Join(home, ".faas", "config.json")
+}
+
+func loadConfig() (*Config, error) {
+	cfg := &Config{Profiles: make(map[string]Profile)}
+	data, err := os.ReadFile(configPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", configPath(), err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]Profile)
+	}
+	return cfg, nil
+}
+
+func saveConfig(cfg *Config) error {
+	path := configPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600) // 包含 token，仅当前用户可读
+}
+
+// resolveProfile 按 --profile > FAAS_PROFILE > current 选择配置，FAAS_API_URL/FAAS_TOKEN 可覆盖
+func resolveProfile(name string) (Profile, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return Profile{}, err
+	}
+	if name == "" {
+		name = os.Getenv("FAAS_PROFILE")
+	}
+	if name == "" {
+		name = cfg.Current
+	}
+
+	var profile Profile
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return Profile{}, fmt.Errorf("profile %q not found in %s", name, configPath())
+		}
+		profile = p
+	}
+	if v := os.Getenv("FAAS_API_URL"); v != "" {
+		profile.URL = v
+	}
+	if v := os.Getenv("FAAS_TOKEN"); v != "" {
+		profile.Token = v
+	}
+	if profile.URL == "" {
+		profile.URL = defaultAPIURL
+	}
+	return profile, nil
+}
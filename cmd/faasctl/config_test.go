@@ -0,0 +1,95 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useConfig 把配置文件指向临时目录并清除会覆盖配置的环境变量
+func useConfig(t *testing.T, cfg *Config) {
+	t.Helper()
+	t.Setenv("FAAS_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	for _, key := range []string{"FAAS_PROFILE", "FAAS_API_URL", "FAAS_TOKEN"} {
+		t.Setenv(key, "")
+	}
+	if cfg != nil {
+		if err := saveConfig(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	cfg := &Config{
+		Current: "dev",
+		Profiles: map[string]Profile{
+			"dev":  {URL: "http://dev:8081", Token: "dev-token"},
+			"prod": {URL: "http://prod:8081", Token: "prod-token"},
+		},
+	}
+	tests := []struct {
+		name    string
+		cfg     *Config
+		flag    string
+		env     map[string]string
+		want    Profile
+		wantErr string
+	}{
+		{name: "no config", want: Profile{URL: defaultAPIURL}},
+		{name: "current profile", cfg: cfg, want: cfg.Profiles["dev"]},
+		{name: "env profile", cfg: cfg, env: map[string]string{"FAAS_PROFILE": "prod"}, want: cfg.Profiles["prod"]},
+		{name: "flag wins over env", cfg: cfg, flag: "dev", env: map[string]string{"FAAS_PROFILE": "prod"}, want: cfg.Profiles["dev"]},
+		{
+			name: "env overrides fields",
+			cfg:  cfg,
+			env:  map[string]string{"FAAS_API_URL": "http://other:9000", "FAAS_TOKEN": "override"},
+			want: Profile{URL: "http://other:9000", Token: "override"},
+		},
+		{name: "unknown profile", cfg: cfg, flag: "staging", wantErr: `profile "staging" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, tt.cfg)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := resolveProfile(tt.flag)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveProfile(%q) = %+v, %v, want %+v", tt.flag, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigCommands(t *testing.T) {
+	useConfig(t, nil)
+	ctx := &cliContext{}
+
+	if err := runConfig(ctx, []string{"set-profile", "prod", "--url", "http://prod:8081", "--token", "secret"}); err != nil {
+		t.Fatalf("set-profile: %v", err)
+	}
+	if err := runConfig(ctx, []string{"use", "prod"}); err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	if err := runConfig(ctx, []string{"use", "missing"}); err == nil {
+		t.Fatal("use of an unknown profile succeeded")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Current != "prod" || cfg.Profiles["prod"] != (Profile{URL: "http://prod:8081", Token: "secret"}) {
+		t.Fatalf("config = %+v", cfg)
+	}
+	if got, err := resolveProfile(""); err != nil || got.Token != "secret" {
+		t.Fatalf("resolveProfile = %+v, %v", got, err)
+	}
+}
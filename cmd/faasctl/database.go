@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// runDatabase 函数私有数据库的迁移、查询、导出与导入
+func runDatabase(ctx *cliContext, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+	fs := newFlagSet(ctx, "db "+sub)
+	output := fs.String("out", "", "file to write the exported database to (export, default: <func>.sqlite)")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) < 1 {
+		return errUsage
+	}
+	funcName := pos[0]
+	path := "/api/v2/functions/" + url.PathEscape(funcName) + "/database"
+	c, err := ctx.client()
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "migrate":
+		if len(pos) < 2 {
+			return errUsage
+		}
+		migrations, err := loadMigrations(pos[1:])
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			Applied []string `json:"applied"`
+		}
+		if err := c.do("POST", path+"/migrations", nil, map[string]any{"migrations": migrations}, &resp); err != nil {
+			return err
+		}
+		if ctx.jsonOutput() {
+			return printJSON(resp)
+		}
+		fmt.Printf("applied %d migrations: %s\n", len(resp.Applied), strings.Join(resp.Applied, ", "))
+		return nil
+	case "migrations":
+		var resp struct {
+			Migrations []struct {
+				Name      string `json:"name"`
+				AppliedAt string `json:"applied_at"`
+			} `json:"migrations"`
+		}
+		if err := c.do("GET", path+"/migrations", nil, nil, &resp); err != nil {
+			return err
+		}
+		if ctx.jsonOutput() {
+			return printJSON(resp)
+		}
+		rows := make([][]string, 0, len(resp.Migrations))
+		for _, m := range resp.Migrations {
+			rows = append(rows, []string{m.Name, m.AppliedAt})
+		}
+		printTable([]string{"NAME", "APPLIED_AT"}, rows)
+		return nil
+	case "query":
+		if len(pos) < 2 {
+			return errUsage
+		}
+		var result struct {
+			Columns      []string `json:"columns"`
+			Rows         [][]any  `json:"rows"`
+			RowsAffected int64    `json:"rows_affected"`
+			LastInsertID int64    `json:"last_insert_id"`
+		}
+		body := map[string]any{"sql": pos[1], "params": queryParams(pos[2:])}
+		if err := c.do("POST", path+"/query", nil, body, &result); err != nil {
+			return err
+		}
+		if ctx.jsonOutput() {
+			return printJSON(result)
+		}
+		if len(result.Columns) == 0 {
+			fmt.Printf("rows affected: %d, last insert id: %d\n", result.RowsAffected, result.LastInsertID)
+			return nil
+		}
+		rows := make([][]string, 0, len(result.Rows))
+		for _, row := range result.Rows {
+			cells := make([]string, len(row))
+			for i, v := range row {
+				cells[i] = formatValue(v)
+			}
+			rows = append(rows, cells)
+		}
+		printTable(result.Columns, rows)
+		return nil
+	case "export":
+		file := *output
+		if file == "" {
+			file = funcName + ".sqlite"
+		}
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		if err := c.raw("GET", path+"/export", "", nil, f); err != nil {
+			f.Close()
+			os.Remove(file)
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("exported database of %s to %s\n", funcName, file)
+		return nil
+	case "import":
+		if len(pos) != 2 {
+			return errUsage
+		}
+		f, err := os.Open(pos[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := c.raw("PUT", path+"/import", "application/vnd.sqlite3", f, nil); err != nil {
+			return err
+		}
+		fmt.Printf("imported %s into the database of %s\n", pos[1], funcName)
+		return nil
+	}
+	return errUsage
+}
+
+// loadMigrations 读取迁移文件：参数为 .sql 文件或目录（目录中的 .sql 文件按文件名排序），迁移名为去掉扩展名的文件名
+func loadMigrations(paths []string) ([]map[string]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.sql"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migration files in %s", strings.Join(paths, ", "))
+	}
+	migrations := make([]map[string]string, 0, len(files))
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		migrations = append(migrations, map[string]string{"name": name, "sql": string(sql)})
+	}
+	return migrations, nil
+}
+
+// queryParams 语句参数：合法的 JSON（数字、true、null、"..."）按 JSON 解析，其余按字符串绑定
+func queryParams(args []string) []any {
+	params := make([]any, len(args))
+	for i, arg := range args {
+		var v any
+		if err := json.Unmarshal([]byte(arg), &v); err == nil {
+			switch v.(type) {
+			case map[string]any, []any:
+				v = arg
+			}
+			params[i] = v
+		} else {
+			params[i] = arg
+		}
+	}
+	return params
+}
@@ -0,0 +1,222 @@
+// faasctl 部署 API 命令行客户端
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cliContext 全局参数
+type cliContext struct {
+	profile string
+	output  string // table / json
+}
+
+func (ctx *cliContext) client() (*apiClient, error) {
+	p, err := resolveProfile(ctx.profile)
+	if err != nil {
+		return nil, err
+	}
+	return newAPIClient(p), nil
+}
+
+func (ctx *cliContext) jsonOutput() bool {
+	return ctx.output == "json"
+}
+
+type command struct {
+	usage string
+	run   func(ctx *cliContext, args []string) error
+}
+
+var commands = map[string]command{
+	"apply":    {"apply -f manifest.yaml [--dry-run]", runApply},
+	"deploy":   {"deploy <func> <file|dir|archive> [--version v] [--alias a] [--entry index.js] [--runtime js|ts|wasm|exec] [--glue glue.js] [--smoke-test /path] [--memory MiB] [--cpu cores] [--pids n] [--open-files n] [--sandbox on|off] [--network host|isolated] [--egress allow|deny|none] [--egress-rule host[:port,...] ...] [--service NAME=func[@alias] ...] [--durable-object BINDING=Class[:RenamedFrom] ...] [--delete-durable-object Class ...] [--database BINDING] [--env K=V ...]", runDeploy},
+	"list":     {"list <func>", runList},
+	"rollback": {"rollback <func> <version> [--alias a]", runRollback},
+	"stop":     {"stop <func> <version>", runStop},
+	"delete":   {"delete <func> [--version v]", runDelete},
+	"logs":     {"logs <func> [--version v] [--since 10m] [--limit n] [-f]", runLogs},
+	"env":      {"env list|set|unset <func> ...", runEnv},
+	"alias":    {"alias list|set|delete <func> ...", runAlias},
+	"db":       {"db migrate <func> <file.sql|dir> ... | migrations <func> | query <func> <sql> [param ...] | export <func> [--out file] | import <func> <file>", runDatabase},
+	"config":   {"config view|use <profile>|set-profile <profile> --url URL [--token T]", runConfig},
+}
+
+// errUsage 参数错误，打印子命令用法
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	ctx := &cliContext{}
+	global := flag.NewFlagSet("faasctl", flag.ContinueOnError)
+	global.StringVar(&ctx.profile, "profile", "", "config profile to use")
+	global.StringVar(&ctx.output, "o", "table", "output format: table or json")
+	global.Usage = usage
+	if err := global.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	args := global.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(ctx, args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "usage: faasctl %s\n", cmd.usage)
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: faasctl [--profile name] [-o table|json] <command> [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+// newFlagSet 子命令参数，同时支持在子命令后指定 -o
+func newFlagSet(ctx *cliContext, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&ctx.output, "o", ctx.output, "output format: table or json")
+	return fs
+}
+
+// parseArgs 解析参数，允许标志与位置参数交错
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// keyValues 可重复的 K=V 参数
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	parts := make([]string, 0, len(kv))
+	for k, v := range kv {
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (kv keyValues) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expect KEY=VALUE, got %q", value)
+	}
+	kv[k] = v
+	return nil
+}
+
+// egressRules 可重复的出站规则参数：host[:port,...]，如 api.example.com:443、10.0.0.0/8、[2001:db8::1]:443
+type egressRules []map[string]any
+
+func (r *egressRules) String() string {
+	return fmt.Sprint(*r)
+}
+
+func (r *egressRules) Set(value string) error {
+	host, ports := value, ""
+	if rest, ok := strings.CutPrefix(value, "["); ok {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return fmt.Errorf("invalid egress rule %q", value)
+		}
+		host, ports = rest[:end], strings.TrimPrefix(rest[end+1:], ":")
+	} else if i := strings.LastIndex(value, ":"); i >= 0 && strings.Count(value, ":") == 1 {
+		host, ports = value[:i], value[i+1:]
+	}
+	if host == "" {
+		return fmt.Errorf("invalid egress rule %q", value)
+	}
+	rule := map[string]any{"host": host}
+	if ports != "" {
+		var list []int
+		for _, p := range strings.Split(ports, ",") {
+			port, err := strconv.Atoi(p)
+			if err != nil {
+				return fmt.Errorf("invalid port %q in egress rule %q", p, value)
+			}
+			list = append(list, port)
+		}
+		rule["ports"] = list
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+// serviceBindings 可重复的服务绑定参数：NAME=function[@alias]
+type serviceBindings []map[string]string
+
+func (s *serviceBindings) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *serviceBindings) Set(value string) error {
+	name, target, ok := strings.Cut(value, "=")
+	if !ok || name == "" || target == "" {
+		return fmt.Errorf("expect NAME=function[@alias], got %q", value)
+	}
+	function, alias, _ := strings.Cut(target, "@")
+	*s = append(*s, map[string]string{"name": name, "function": function, "alias": alias})
+	return nil
+}
+
+// durableObjects 可重复的 Durable Object 参数：BINDING=Class[:RenamedFrom]
+type durableObjects []map[string]string
+
+func (d *durableObjects) String() string {
+	return fmt.Sprint(*d)
+}
+
+func (d *durableObjects) Set(value string) error {
+	binding, class, ok := strings.Cut(value, "=")
+	if !ok || binding == "" || class == "" {
+		return fmt.Errorf("expect BINDING=Class[:RenamedFrom], got %q", value)
+	}
+	class, renamedFrom, _ := strings.Cut(class, ":")
+	*d = append(*d, map[string]string{"binding": binding, "class_name": class, "renamed_from": renamedFrom})
+	return nil
+}
+
+// stringList 可重复的字符串参数
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
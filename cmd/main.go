@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"faas/internal/api"
+	"faas/internal/logs"
+	"faas/internal/metrics"
 	"faas/internal/registry"
-	"github.com/gin-gonic/gin"
+	"faas/internal/tracing"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout 退出时等待进行中的请求与链路导出的最长时间
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	// 隔离运行的实例由平台重新执行自身完成初始化（此时不会返回）
+	registry.SandboxInit()
+
 	// 读取环境变量配置
 	workerdBin := os.Getenv("WORKERD_BIN")
 	if workerdBin == "" {
@@ -24,34 +40,105 @@ func main() {
 		mainPort = "80" // 路由转发端口（子域名访问）
 	}
 
+	// 函数日志轮转配置
+	logs.DefaultOptions = logs.Options{
+		MaxSize:    int64(envInt("LOG_MAX_SIZE_MB", 10)) << 20,
+		MaxBackups: envInt("LOG_MAX_BACKUPS", 5),
+		MaxAge:     time.Duration(envInt("LOG_MAX_AGE_DAYS", 7)) * 24 * time.Hour,
+	}
+
+	// 访问日志输出位置（默认 stdout）
+	if accessLogPath := os.Getenv("ACCESS_LOG"); accessLogPath != "" {
+		accessLogFile, err := os.OpenFile(accessLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("open access log: %v", err)
+		}
+		api.AccessLogger = slog.New(slog.NewJSONHandler(accessLogFile, nil))
+	}
+
+	// 链路追踪（OTLP_ENDPOINT 为空时不导出，仅传播 traceparent）
+	shutdownTracing, err := tracing.Init(context.Background(), os.Getenv("OTLP_ENDPOINT"))
+	if err != nil {
+		log.Fatalf("init tracing: %v", err)
+	}
+
+	// 本地可执行文件运行时（代码直接在宿主机上运行，需显式开启）
+	registry.ExecRuntimeEnabled = os.Getenv("EXEC_RUNTIME") == "true"
+
+	// 实例的默认隔离设置（部署时可按函数覆盖）
+	if mode := os.Getenv("FAAS_SANDBOX"); mode != "" {
+		if mode != registry.SandboxOn && mode != registry.SandboxOff {
+			log.Fatalf("invalid FAAS_SANDBOX %q: must be on or off", mode)
+		}
+		registry.SandboxDefault.Mode = mode
+	}
+	if network := os.Getenv("FAAS_SANDBOX_NETWORK"); network != "" {
+		if network != registry.NetworkHost && network != registry.NetworkIsolated {
+			log.Fatalf("invalid FAAS_SANDBOX_NETWORK %q: must be host or isolated", network)
+		}
+		registry.SandboxDefault.Network = network
+	}
+
+	// 实例 cgroup 的父目录（cgroup v2 不可用时以 rlimit 限制资源）
+	if parent := os.Getenv("FAAS_CGROUP_PARENT"); parent != "" {
+		registry.CgroupParent = parent
+	}
+
 	// 初始化注册表
 	reg := registry.Default(workerdBin)
 	log.Printf("storage dir: %s", reg.StorageDir) // 日志输出存储目录
+	metrics.RegisterInstances(reg.InstanceCounts)
+	reg.SetServiceHandler(api.ServiceHandler(reg)) // 服务绑定经内部监听回到路由处理器
 
-	// 启动部署 API 服务（独立协程）
-	ginEngine := gin.Default()
-	apiGroup := ginEngine.Group("/api")
-	//apiGroup.Use(api.AuthMiddleware()) // 鉴权中间件
-	{
-		apiGroup.GET("/list/:funcName", api.ListVersionsHandler(reg))
-		apiGroup.POST("/deploy/:funcName", api.DeployHandler(reg))
-		apiGroup.POST("/rollback/:funcName", api.RollbackHandler(reg))
-		apiGroup.POST("/stop/:funcName", api.StopHandler(reg))
-		apiGroup.POST("/delete/:funcName", api.DeleteFunctionHandler(reg))
-		apiGroup.POST("/deleteVersion/:funcName", api.DeleteVersionHandler(reg))
-	}
-
+	// 启动部署 API 服务与路由转发服务（主端口），任一服务失败或收到 SIGINT / SIGTERM 时退出
+	apiServer := &http.Server{Addr: ":" + apiPort, Handler: api.NewRouter(reg)}
+	proxyServer := &http.Server{Addr: ":" + mainPort, Handler: http.HandlerFunc(api.ProxyHandler(reg))}
+	serveErr := make(chan error, 2)
 	go func() {
 		log.Printf("deploy API running on :%s", apiPort)
-		if err := ginEngine.Run(":" + apiPort); err != nil {
-			log.Fatalf("api server failed: %v", err)
+		if err := apiServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("api server failed: %w", err)
 		}
 	}()
+	go func() {
+		log.Printf("router proxy running on :%s", mainPort)
+		if err := proxyServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("proxy server failed: %w", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		log.Printf("shutting down")
+	case err := <-serveErr:
+		log.Printf("%v", err)
+		exitCode = 1
+	}
+	stop() // 再次收到信号时直接退出
+
+	// 等待进行中的请求结束，再停止实例并导出剩余的链路数据
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	for _, server := range []*http.Server{apiServer, proxyServer} {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown %s: %v", server.Addr, err)
+		}
+	}
+	if err := reg.Close(); err != nil {
+		log.Printf("close registry: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("shutdown tracing: %v", err)
+	}
+	cancel()
+	os.Exit(exitCode)
+}
 
-	// 启动路由转发服务（主端口）
-	log.Printf("router proxy running on :%s", mainPort)
-	http.HandleFunc("/", api.ProxyHandler(reg))
-	if err := http.ListenAndServe(":"+mainPort, nil); err != nil {
-		log.Fatalf("proxy server failed: %v", err)
+// envInt 读取整数环境变量，未设置或非法时返回默认值
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return v
 	}
+	return def
 }
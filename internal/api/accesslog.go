@@ -0,0 +1,74 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"faas/internal/registry"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// RequestIDHeader 请求 ID 请求/响应头
+const RequestIDHeader = "X-Request-Id"
+
+// AccessLogger 路由转发访问日志（JSON lines，默认输出到 stdout）
+var AccessLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// accessEntry 单次转发请求的访问日志字段
+type accessEntry struct {
+	requestID string
+	function  string
+	version   string
+	alias     string
+	coldStart bool
+	startAt   time.Time
+}
+
+// logAccess 输出一条访问日志
+func logAccess(r *http.Request, rec *statusRecorder, entry *accessEntry) {
+	AccessLogger.LogAttrs(r.Context(), slog.LevelInfo, "access",
+		slog.String("request_id", entry.requestID),
+		slog.String("method", r.Method),
+		slog.String("host", r.Host),
+		slog.String("path", r.URL.Path),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("function", entry.function),
+		slog.String("version", entry.version),
+		slog.String("alias", entry.alias),
+		slog.Int("status", rec.status),
+		slog.Float64("latency_ms", float64(time.Since(entry.startAt).Microseconds())/1000),
+		slog.Int64("bytes", rec.bytes),
+		slog.Bool("cold_start", entry.coldStart),
+		slog.String("caller", r.Header.Get(registry.ServiceCallerHeader)),
+	)
+}
+
+// requestIDFrom 沿用客户端传入的合法请求 ID，否则生成新的
+func requestIDFrom(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, ch := range id {
+		if ch < 0x21 || ch > 0x7e { // 仅允许可见 ASCII 字符
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
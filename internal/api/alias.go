@@ -0,0 +1,80 @@
+package api
+
+import (
+	"faas/internal/registry"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetAliasRequest 设置别名请求体
+type SetAliasRequest struct {
+	Alias   string `json:"alias" binding:"required"`   // 别名
+	Version string `json:"version" binding:"required"` // 目标版本
+}
+
+// DeleteAliasRequest 删除别名请求体
+type DeleteAliasRequest struct {
+	Alias string `json:"alias" binding:"required"` // 别名
+}
+
+// ListAliasesHandler 查询函数别名接口（GET /api/aliases/:funcName）
+func ListAliasesHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		c.JSON(http.StatusOK, gin.H{
+			"funcName": funcName,
+			"aliases":  reg.Aliases(funcName),
+		})
+	}
+}
+
+// SetAliasHandler 设置别名接口（POST /api/alias/:funcName）
+func SetAliasHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		var req SetAliasRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := reg.SetAlias(funcName, req.Alias, req.Version); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":    "success",
+			"funcName":  funcName,
+			"alias":     req.Alias,
+			"version":   req.Version,
+			"accessUrl": fmt.Sprintf("http://%s.%s.func.local", req.Alias, funcName),
+		})
+	}
+}
+
+// DeleteAliasHandler 删除别名接口（POST /api/deleteAlias/:funcName）
+func DeleteAliasHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		var req DeleteAliasRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := reg.DeleteAlias(funcName, req.Alias); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":   "success",
+			"funcName": funcName,
+			"alias":    req.Alias,
+			"message":  "alias deleted successfully",
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"faas/internal/registry"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Manifest 函数声明式描述（POST /api/apply 请求体）
+type Manifest struct {
+	Name           string                        `json:"name" binding:"required"`
+	Runtime        string                        `json:"runtime" binding:"required,oneof=js ts wasm exec"`
+	Entry          string                        `json:"entry"`                                       // 入口文件（由客户端读取后填入 code）
+	Code           string                        `json:"code" binding:"required_unless=Runtime wasm"` // 函数源码（ts 运行时为 TypeScript 源码，wasm 运行时为可选的胶水脚本）
+	Wasm           []byte                        `json:"wasm"`                                        // WebAssembly 二进制（wasm 运行时，base64）
+	Version        string                        `json:"version"`                                     // 版本（为空时代码或配置变化才生成新版本）
+	Env            map[string]string             `json:"env"`                                         // 环境变量
+	Aliases        map[string]string             `json:"aliases"`                                     // 别名 -> 版本（版本为空表示本次 manifest 对应的版本）
+	Schedules      []ManifestSchedule            `json:"schedules"`                                   // 定时触发（声明时为完整期望状态）
+	Limits         *ManifestLimits               `json:"limits"`                                      // 限制
+	SmokeTest      *registry.SmokeTest           `json:"smoke_test"`                                  // 部署新版本时的冒烟测试（可选）
+	Resources      *registry.ResourceLimits      `json:"resources"`                                   // 资源上限（未声明表示不限制）
+	Sandbox        *registry.SandboxConfig       `json:"sandbox"`                                     // 隔离设置（未声明时使用全局默认）
+	Egress         *registry.EgressPolicy        `json:"egress"`                                      // 出站规则（未声明表示不限制）
+	Services       []registry.ServiceBinding     `json:"services" binding:"omitempty,dive"`           // 服务绑定
+	DurableObjects *registry.DurableObjectConfig `json:"durable_objects"`                             // Durable Object 类与迁移
+	Database       *registry.DatabaseConfig      `json:"database"`                                    // 私有 SQLite 数据库
+}
+
+// ManifestSchedule 定时触发声明：按 Cron 以 POST 请求调用 latest 版本的 Path（默认 /）
+type ManifestSchedule struct {
+	Cron string `json:"cron"`
+	Path string `json:"path"`
+}
+
+// ManifestLimits 限制声明，出现时视为完整期望状态（未声明的限流规则会被删除）
+type ManifestLimits struct {
+	RateLimits []RateLimitRequest `json:"rate_limits" binding:"dive"`
+}
+
+// PlanStep 执行计划中的一步
+type PlanStep struct {
+	Action string `json:"action"` // deploy / set_alias / set_rate_limit / delete_rate_limit / set_schedule / delete_schedule
+	Target string `json:"target"`
+	Detail string `json:"detail"`
+}
+
+// ApplyHandler 按 manifest 收敛函数状态（POST /api/apply?dry_run=true）
+func ApplyHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var m Manifest
+		if err := c.ShouldBindJSON(&m); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, exists := m.Aliases["latest"]; exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "alias latest is managed by deploy and cannot be declared"})
+			return
+		}
+		if err := validateNames(m.Name, m.Version); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateWasm(m.Runtime, m.Wasm); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		dryRun := c.Query("dry_run") == "true"
+
+		plan, deployReq, err := planManifest(reg, &m)
+		if _, ok := compileDiagnostics(err); ok {
+			c.JSON(http.StatusUnprocessableEntity, bindErrorBody(err))
+			return
+		}
+		if err != nil {
+			c.JSON(planErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		if !dryRun {
+			if err := applyPlan(c, reg, &m, deployReq); err != nil {
+				body := deployErrorBody(err)
+				body["plan"] = plan
+				body["applied"] = appliedSteps(err)
+				c.JSON(deployErrorStatus(err), body)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":   "success",
+			"funcName": m.Name,
+			"version":  m.Version,
+			"dryRun":   dryRun,
+			"changed":  planChanges(plan),
+			"plan":     plan,
+		})
+	}
+}
+
+// planManifest 对比 manifest 与注册表当前状态，生成执行计划；需要部署时返回部署请求，并回填 m.Version
+func planManifest(reg *registry.Registry, m *Manifest) ([]PlanStep, *DeployRequest, error) {
+	plan := []PlanStep{}
+	var deployReq *DeployRequest
+
+	// 1. 代码、运行时、环境变量：任一变化都需要部署新版本
+	var current *registry.FunctionMetadata
+	var exists bool
+	if m.Version != "" {
+		current, exists = reg.GetByVersion(m.Name, m.Version)
+	} else {
+		current, exists = reg.GetByName(m.Name)
+	}
+	var diff []string
+	if exists {
+		reg.Mu.RLock()
+		diff = diffVersion(current, m)
+		currentVersion := current.Version
+		reg.Mu.RUnlock()
+
+		if len(diff) == 0 {
+			m.Version = currentVersion
+		} else if m.Version != "" {
+			return nil, nil, fmt.Errorf("%w: %s has different %s", registry.ErrVersionExists, m.Version, strings.Join(diff, ", "))
+		}
+	}
+	if !exists || len(diff) > 0 {
+		deployReq = &DeployRequest{Runtime: m.Runtime, Code: m.Code, Wasm: m.Wasm, EnvVars: m.Env, Version: m.Version, SmokeTest: m.SmokeTest, Resources: m.Resources, Sandbox: m.Sandbox, Egress: m.Egress, Services: m.Services, DurableObjects: m.DurableObjects, Database: m.Database}
+		if err := prepareRuntime(deployReq); err != nil {
+			if _, ok := compileDiagnostics(err); ok {
+				return nil, nil, err
+			}
+			return nil, nil, &registry.ValidationError{Message: err.Error()}
+		}
+		// 部署前检查配置，dry run 同样能发现非法的运行时、出站规则等（副本避免生成的版本号写回请求）
+		check := *deployReq
+		if err := reg.Validate(newFunctionMeta(m.Name, &check)); err != nil {
+			return nil, nil, err
+		}
+		detail := "new function"
+		if exists {
+			detail = "changed: " + strings.Join(diff, ", ")
+		} else if _, ok := reg.GetByName(m.Name); ok {
+			detail = "new version"
+		}
+		target := m.Version
+		if target == "" {
+			target = "(generated)"
+		}
+		plan = append(plan, PlanStep{Action: "deploy", Target: target, Detail: detail})
+	}
+
+	// 2. 别名：只管理 manifest 中声明的别名
+	currentAliases := reg.Aliases(m.Name)
+	for _, alias := range sortedKeys(m.Aliases) {
+		target := m.Aliases[alias]
+		if target == "" {
+			if deployReq != nil && m.Version == "" {
+				detail := "-> (deployed version)"
+				if old, ok := currentAliases[alias]; ok {
+					detail = old + " " + detail
+				}
+				plan = append(plan, PlanStep{Action: "set_alias", Target: alias, Detail: detail})
+				continue
+			}
+			target = m.Version
+		} else if target != m.Version {
+			if _, ok := reg.GetByVersion(m.Name, target); !ok {
+				return nil, nil, &registry.ValidationError{Message: fmt.Sprintf("alias %s targets unknown version %s", alias, target)}
+			}
+		}
+		if currentAliases[alias] != target {
+			detail := "-> " + target
+			if old, ok := currentAliases[alias]; ok {
+				detail = old + " -> " + target
+			}
+			plan = append(plan, PlanStep{Action: "set_alias", Target: alias, Detail: detail})
+		}
+	}
+
+	// 3. 限流规则：声明了 limits 时以其为准
+	if m.Limits != nil {
+		existing := make(map[string]registry.RateLimitRule)
+		for _, rule := range reg.ListRateLimits(m.Name) {
+			existing[rule.Alias] = rule
+		}
+		declared := make(map[string]bool)
+		for _, rl := range m.Limits.RateLimits {
+			if _, ok := currentAliases[rl.Alias]; !ok && rl.Alias != "" && rl.Alias != "latest" {
+				if _, ok := m.Aliases[rl.Alias]; !ok {
+					return nil, nil, &registry.ValidationError{Message: fmt.Sprintf("rate limit targets unknown alias %s", rl.Alias)}
+				}
+			}
+			declared[rl.Alias] = true
+			rule, ok := existing[rl.Alias]
+			if ok && rateLimitEqual(rule, rl) {
+				continue
+			}
+			plan = append(plan, PlanStep{Action: "set_rate_limit", Target: rateLimitTarget(rl.Alias),
+				Detail: fmt.Sprintf("rate=%g burst=%d key_by=%s", rl.Rate, rl.Burst, defaultString(rl.KeyBy, registry.RateLimitKeyIP))})
+		}
+		for _, alias := range sortedKeys(existing) {
+			if !declared[alias] {
+				plan = append(plan, PlanStep{Action: "delete_rate_limit", Target: rateLimitTarget(alias)})
+			}
+		}
+	}
+
+	// 4. 定时触发：声明了 schedules 时以其为准
+	if m.Schedules != nil {
+		existing := make(map[string]bool)
+		for _, s := range reg.ListSchedules(m.Name) {
+			existing[s.Key()] = true
+		}
+		declared := make(map[string]bool)
+		for _, s := range m.Schedules {
+			if err := registry.ValidateSchedule(s.Cron, s.Path); err != nil {
+				return nil, nil, err
+			}
+			key := scheduleKey(s)
+			if declared[key] {
+				continue
+			}
+			declared[key] = true
+			if !existing[key] {
+				plan = append(plan, PlanStep{Action: "set_schedule", Target: key})
+			}
+		}
+		for _, key := range sortedKeys(existing) {
+			if !declared[key] {
+				plan = append(plan, PlanStep{Action: "delete_schedule", Target: key})
+			}
+		}
+	}
+
+	return plan, deployReq, nil
+}
+
+// scheduleKey 与 registry.Schedule.Key 相同的规范化标识（调用方已校验）
+func scheduleKey(s ManifestSchedule) string {
+	schedule := registry.Schedule{Cron: strings.Join(strings.Fields(s.Cron), " "), Path: defaultString(s.Path, "/")}
+	return schedule.Key()
+}
+
+// planErrorStatus v1 生成计划失败的状态码：版本号已被不同内容占用为 409，其余同部署失败
+func planErrorStatus(err error) int {
+	if errors.Is(err, registry.ErrVersionExists) {
+		return http.StatusConflict
+	}
+	return deployErrorStatus(err)
+}
+
+// planChanges 计划中是否包含实际变更
+func planChanges(plan []PlanStep) bool {
+	return len(plan) > 0
+}
+
+// applyError 执行计划中途失败，Applied 为失败前已经生效的步骤（不会回滚）
+type applyError struct {
+	Applied []PlanStep
+	Err     error
+}
+
+func (e *applyError) Error() string {
+	if len(e.Applied) == 0 {
+		return e.Err.Error()
+	}
+	done := make([]string, 0, len(e.Applied))
+	for _, step := range e.Applied {
+		done = append(done, step.Action+" "+step.Target)
+	}
+	return fmt.Sprintf("%v (already applied: %s)", e.Err, strings.Join(done, ", "))
+}
+
+func (e *applyError) Unwrap() error {
+	return e.Err
+}
+
+// appliedSteps 返回执行失败前已生效的步骤
+func appliedSteps(err error) []PlanStep {
+	var applyErr *applyError
+	if errors.As(err, &applyErr) && applyErr.Applied != nil {
+		return applyErr.Applied
+	}
+	return []PlanStep{}
+}
+
+// applyPlan 依次执行部署、别名、限流和定时触发变更；中途失败时返回 *applyError，记录已生效的步骤
+func applyPlan(c *gin.Context, reg *registry.Registry, m *Manifest, deployReq *DeployRequest) error {
+	var applied []PlanStep
+	fail := func(err error) error {
+		return &applyError{Applied: applied, Err: err}
+	}
+
+	if deployReq != nil {
+		explicitVersion := deployReq.Version != ""
+		meta := newFunctionMeta(m.Name, deployReq)
+		deployed, _, err := deployVersion(c.Request.Context(), reg, meta, explicitVersion)
+		if err != nil {
+			return fail(fmt.Errorf("deploy: %w", err))
+		}
+		m.Version = deployed.Version
+		applied = append(applied, PlanStep{Action: "deploy", Target: deployed.Version})
+	}
+
+	currentAliases := reg.Aliases(m.Name)
+	for _, alias := range sortedKeys(m.Aliases) {
+		target := defaultString(m.Aliases[alias], m.Version)
+		if currentAliases[alias] == target {
+			continue
+		}
+		if err := reg.SetAlias(m.Name, alias, target); err != nil {
+			return fail(fmt.Errorf("set alias %s: %w", alias, err))
+		}
+		applied = append(applied, PlanStep{Action: "set_alias", Target: alias, Detail: "-> " + target})
+	}
+
+	if m.Limits != nil {
+		existing := make(map[string]registry.RateLimitRule)
+		for _, rule := range reg.ListRateLimits(m.Name) {
+			existing[rule.Alias] = rule
+		}
+		declared := make(map[string]bool)
+		for _, rl := range m.Limits.RateLimits {
+			declared[rl.Alias] = true
+			if rule, ok := existing[rl.Alias]; ok && rateLimitEqual(rule, rl) {
+				continue
+			}
+			rule := &registry.RateLimitRule{FuncName: m.Name, Alias: rl.Alias, Rate: rl.Rate,
+				Burst: rl.Burst, KeyBy: rl.KeyBy, Header: rl.Header}
+			if err := reg.SetRateLimit(rule); err != nil {
+				return fail(fmt.Errorf("set rate limit %s: %w", rateLimitTarget(rl.Alias), err))
+			}
+			applied = append(applied, PlanStep{Action: "set_rate_limit", Target: rateLimitTarget(rl.Alias)})
+		}
+		for _, alias := range sortedKeys(existing) {
+			if !declared[alias] {
+				if err := reg.DeleteRateLimit(m.Name, alias); err != nil {
+					return fail(fmt.Errorf("delete rate limit %s: %w", rateLimitTarget(alias), err))
+				}
+				applied = append(applied, PlanStep{Action: "delete_rate_limit", Target: rateLimitTarget(alias)})
+			}
+		}
+	}
+
+	if m.Schedules != nil {
+		existing := make(map[string]bool)
+		for _, s := range reg.ListSchedules(m.Name) {
+			existing[s.Key()] = true
+		}
+		schedules := make([]registry.Schedule, 0, len(m.Schedules))
+		var steps []PlanStep
+		declared := make(map[string]bool)
+		for _, s := range m.Schedules {
+			schedules = append(schedules, registry.Schedule{Cron: s.Cron, Path: s.Path})
+			key := scheduleKey(s)
+			if !existing[key] && !declared[key] {
+				steps = append(steps, PlanStep{Action: "set_schedule", Target: key})
+			}
+			declared[key] = true
+		}
+		for _, key := range sortedKeys(existing) {
+			if !declared[key] {
+				steps = append(steps, PlanStep{Action: "delete_schedule", Target: key})
+			}
+		}
+		if len(steps) > 0 {
+			if err := reg.SetSchedules(m.Name, schedules); err != nil {
+				return fail(fmt.Errorf("set schedules: %w", err))
+			}
+			applied = append(applied, steps...)
+		}
+	}
+	return nil
+}
+
+// diffVersion 比较已部署版本与 manifest，返回有差异的字段（调用方持有读锁）
+func diffVersion(meta *registry.FunctionMetadata, m *Manifest) []string {
+	var diff []string
+	if meta.Runtime != m.Runtime {
+		diff = append(diff, "runtime")
+	}
+	code := meta.Code
+	if meta.SourceEntry != "" {
+		code = string(meta.Sources[meta.SourceEntry]) // TypeScript 版本与编译前的源码比较
+	}
+	expected := m.Code
+	if m.Runtime == RuntimeWasm && expected == "" {
+		expected = defaultWasmGlue
+	}
+	if code != expected {
+		diff = append(diff, "code")
+	}
+	if !bytes.Equal(meta.Wasm, m.Wasm) {
+		diff = append(diff, "wasm")
+	}
+	var envDiff []string
+	for k, v := range m.Env {
+		if old, ok := meta.EnvVars[k]; !ok {
+			envDiff = append(envDiff, "+"+k)
+		} else if old != v {
+			envDiff = append(envDiff, "~"+k)
+		}
+	}
+	for k := range meta.EnvVars {
+		if _, ok := m.Env[k]; !ok {
+			envDiff = append(envDiff, "-"+k)
+		}
+	}
+	if len(envDiff) > 0 {
+		sort.Strings(envDiff)
+		diff = append(diff, "env("+strings.Join(envDiff, " ")+")")
+	}
+	var resources registry.ResourceLimits
+	if m.Resources != nil {
+		resources = *m.Resources
+	}
+	if meta.Resources != resources {
+		diff = append(diff, "resources")
+	}
+	var sandbox registry.SandboxConfig
+	if m.Sandbox != nil {
+		sandbox = *m.Sandbox
+	}
+	if meta.Sandbox != sandbox {
+		diff = append(diff, "sandbox")
+	}
+	var egress registry.EgressPolicy
+	if m.Egress != nil {
+		egress = *m.Egress
+	}
+	if !meta.Egress.Equal(egress) {
+		diff = append(diff, "egress")
+	}
+	if !slices.Equal(meta.Services, m.Services) {
+		diff = append(diff, "services")
+	}
+	var durable registry.DurableObjectConfig
+	if m.DurableObjects != nil {
+		durable = *m.DurableObjects
+	}
+	if !meta.DurableObjects.Equal(durable) {
+		diff = append(diff, "durable_objects")
+	}
+	var database registry.DatabaseConfig
+	if m.Database != nil {
+		database = *m.Database
+	}
+	if meta.Database != database {
+		diff = append(diff, "database")
+	}
+	return diff
+}
+
+func rateLimitEqual(rule registry.RateLimitRule, rl RateLimitRequest) bool {
+	return rule.Rate == rl.Rate && rule.Burst == rl.Burst &&
+		rule.KeyBy == defaultString(rl.KeyBy, registry.RateLimitKeyIP) && rule.Header == rl.Header
+}
+
+func rateLimitTarget(alias string) string {
+	if alias == "" {
+		return "(function)"
+	}
+	return alias
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
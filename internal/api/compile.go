@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"faas/internal/typescript"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RuntimeTypeScript TypeScript 运行时：部署时在进程内编译为 JavaScript，由 workerd 运行编译结果
+const RuntimeTypeScript = "ts"
+
+// defaultTypeScriptEntry 单文件 TypeScript 部署的源文件名
+const defaultTypeScriptEntry = "index.ts"
+
+// compileTypeScript 编译 TypeScript 部署请求：源文件与源码入口保存到 Sources / SourceEntry，
+// Code / Entry / Modules 替换为编译结果。单文件且没有 import/export 时仍按 Service Worker 脚本运行
+func compileTypeScript(req *DeployRequest) error {
+	files, entry := req.Modules, req.Entry
+	if files == nil {
+		files, entry = map[string][]byte{defaultTypeScriptEntry: []byte(req.Code)}, defaultTypeScriptEntry
+	}
+	out, err := typescript.Build(files, entry)
+	if err != nil {
+		return err
+	}
+
+	req.Sources, req.SourceEntry = files, entry
+	req.Code = string(out.Modules[out.Entry])
+	req.Entry, req.Modules = "", nil
+	if out.Module {
+		req.Entry, req.Modules = out.Entry, out.Modules
+	}
+	return nil
+}
+
+// compileDiagnostics 提取 TypeScript 编译错误的诊断信息
+func compileDiagnostics(err error) ([]typescript.Diagnostic, bool) {
+	var compileErr *typescript.Error
+	if errors.As(err, &compileErr) {
+		return compileErr.Diagnostics, true
+	}
+	return nil, false
+}
+
+// bindErrorBody v1 部署请求解析失败时的响应体，编译错误附带 diagnostics（文件、行、列、信息）
+func bindErrorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	if diagnostics, ok := compileDiagnostics(err); ok {
+		body["diagnostics"] = diagnostics
+	}
+	return body
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"faas/internal/registry"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MigrateDatabaseRequest 执行数据库迁移请求体
+type MigrateDatabaseRequest struct {
+	Migrations []registry.DatabaseMigration `json:"migrations" binding:"required,dive"`
+}
+
+// V2ListMigrationsHandler GET /api/v2/functions/:funcName/database/migrations
+func V2ListMigrationsHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		migrations, err := reg.DatabaseMigrations(c.Request.Context(), c.Param("funcName"))
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"migrations": migrations})
+	}
+}
+
+// V2MigrateDatabaseHandler 按顺序执行尚未执行的迁移（POST /api/v2/functions/:funcName/database/migrations）
+func V2MigrateDatabaseHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MigrateDatabaseRequest
+		if !bindV2(c, &req) {
+			return
+		}
+		applied, err := reg.MigrateDatabase(c.Request.Context(), c.Param("funcName"), req.Migrations)
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"applied": applied})
+	}
+}
+
+// V2QueryDatabaseHandler POST /api/v2/functions/:funcName/database/query
+func V2QueryDatabaseHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var stmt registry.Statement
+		if !bindV2(c, &stmt) {
+			return
+		}
+		result, err := reg.QueryDatabase(c.Request.Context(), c.Param("funcName"), stmt)
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// V2ExportDatabaseHandler 下载数据库快照（GET /api/v2/functions/:funcName/database/export）
+func V2ExportDatabaseHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		c.Header("Content-Type", "application/vnd.sqlite3")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.sqlite"`, funcName))
+		if err := reg.ExportDatabase(c.Request.Context(), funcName, c.Writer); err != nil {
+			if c.Writer.Written() {
+				c.Error(err)
+				return
+			}
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			v2Error(c, err)
+		}
+	}
+}
+
+// V2ImportDatabaseHandler 用请求体中的 SQLite 数据库文件替换函数的数据库（PUT /api/v2/functions/:funcName/database/import）
+func V2ImportDatabaseHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, registry.MaxDatabaseImportSize)
+		if err := reg.ImportDatabase(c.Param("funcName"), body); err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
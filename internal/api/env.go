@@ -0,0 +1,134 @@
+package api
+
+import (
+	"faas/internal/registry"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateEnvRequest 修改环境变量请求体（基于 latest 生成新版本）
+type UpdateEnvRequest struct {
+	Set     map[string]string `json:"set"`     // 新增/覆盖的环境变量
+	Unset   []string          `json:"unset"`   // 删除的环境变量
+	Version string            `json:"version"` // 新版本号（可选）
+	Alias   string            `json:"alias"`   // 别名（可选）
+}
+
+// GetEnvHandler 查询环境变量名接口（GET /api/env/:funcName?version=）
+func GetEnvHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		meta, ok := lookupVersion(c, reg, funcName)
+		if !ok {
+			return
+		}
+
+		reg.Mu.RLock()
+		keys := envKeys(meta.EnvVars)
+		reg.Mu.RUnlock()
+
+		c.JSON(http.StatusOK, gin.H{
+			"funcName": funcName,
+			"version":  meta.Version,
+			"keys":     keys,
+		})
+	}
+}
+
+// UpdateEnvHandler 修改环境变量接口（POST /api/env/:funcName）
+// 环境变量在 workerd 配置中生成，因此修改后以 latest 的代码部署一个新版本
+func UpdateEnvHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		var req UpdateEnvRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateNames(funcName, req.Version); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		explicitVersion := req.Version != ""
+		meta, err := newEnvVersion(reg, funcName, &req)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		deployed, created, err := deployVersion(c.Request.Context(), reg, meta, explicitVersion)
+		if err != nil {
+			c.JSON(deployErrorStatus(err), deployErrorBody(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":       "success",
+			"funcName":     funcName,
+			"subdomain":    deployed.Subdomain,
+			"accessUrl":    "http://" + deployed.Subdomain,
+			"version":      deployed.Version,
+			"alias":        req.Alias,
+			"keys":         envKeys(meta.EnvVars),
+			"deduplicated": !created,
+		})
+	}
+}
+
+// newEnvVersion 基于 latest 的代码与合并后的环境变量生成新版本元数据
+func newEnvVersion(reg *registry.Registry, funcName string, req *UpdateEnvRequest) (*registry.FunctionMetadata, error) {
+	latest, exists := reg.GetByName(funcName)
+	if !exists {
+		return nil, registry.ErrFunctionNotFound
+	}
+
+	// 合并环境变量
+	reg.Mu.RLock()
+	envVars := make(map[string]string, len(latest.EnvVars)+len(req.Set))
+	for k, v := range latest.EnvVars {
+		envVars[k] = v
+	}
+	runtime, code, entry, modules := latest.Runtime, latest.Code, latest.Entry, latest.Modules
+	sources, sourceEntry, wasm := latest.Sources, latest.SourceEntry, latest.Wasm
+	resources, sandbox, egress, services := latest.Resources, latest.Sandbox, latest.Egress, latest.Services
+	durable := registry.DurableObjectConfig{Classes: latest.DurableObjects.Classes} // 迁移已在 latest 生效
+	database := latest.Database
+	reg.Mu.RUnlock()
+	for k, v := range req.Set {
+		envVars[k] = v
+	}
+	for _, k := range req.Unset {
+		delete(envVars, k)
+	}
+
+	return newFunctionMeta(funcName, &DeployRequest{
+		Runtime:        runtime,
+		Code:           code,
+		EnvVars:        envVars,
+		Version:        req.Version,
+		Alias:          req.Alias,
+		Entry:          entry,
+		Modules:        modules,
+		Sources:        sources,
+		SourceEntry:    sourceEntry,
+		Wasm:           wasm,
+		Resources:      &resources,
+		Sandbox:        &sandbox,
+		Egress:         &egress,
+		Services:       services,
+		DurableObjects: &durable,
+		Database:       &database,
+	}), nil
+}
+
+// envKeys 返回排序后的环境变量名（不返回值，避免泄露敏感配置）
+func envKeys(envVars map[string]string) []string {
+	keys := make([]string, 0, len(envVars))
+	for k := range envVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"faas/internal/registry"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ListFunctionsHandler 函数列表接口（GET /api/functions?page=&page_size=&name=&status=&runtime=）
+// name 按子串匹配；status=running 只返回有运行中版本的函数，status=suspended 只返回没有运行中版本的函数
+func ListFunctionsHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := queryFunctions(c, reg)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// queryFunctions 按查询参数过滤并分页
+func queryFunctions(c *gin.Context, reg *registry.Registry) (gin.H, error) {
+	page, err := parsePositive(c.DefaultQuery("page", "1"))
+	if err != nil {
+		return nil, &registry.ValidationError{Message: "invalid page"}
+	}
+	pageSize, err := parsePositive(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if err != nil {
+		return nil, &registry.ValidationError{Message: "invalid page_size"}
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	name, status, runtime := c.Query("name"), c.Query("status"), c.Query("runtime")
+	if status != "" && status != "running" && status != "suspended" {
+		return nil, &registry.ValidationError{Message: "status must be running or suspended"}
+	}
+
+	var filtered []registry.FunctionSummary
+	for _, f := range reg.ListFunctions() {
+		if name != "" && !strings.Contains(f.Name, name) {
+			continue
+		}
+		if runtime != "" && f.Runtime != runtime {
+			continue
+		}
+		if status == "running" && f.Running == 0 || status == "suspended" && f.Running > 0 {
+			continue
+		}
+		filtered = append(filtered, f)
+	}
+
+	total := len(filtered)
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+	items := filtered[start:end]
+	if items == nil {
+		items = []registry.FunctionSummary{}
+	}
+
+	return gin.H{
+		"functions": items,
+		"page":      page,
+		"pageSize":  pageSize,
+		"total":     total,
+	}, nil
+}
+
+// GetFunctionHandler 函数详情接口（GET /api/functions/:funcName）
+func GetFunctionHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		detail, exists := reg.DescribeFunction(c.Param("funcName"))
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "function not found"})
+			return
+		}
+		c.JSON(http.StatusOK, detail)
+	}
+}
+
+// GetCodeHandler 下载版本源码接口（GET /api/functions/:funcName/versions/:version/code?source=true|wasm=true）
+// 默认返回运行的 JavaScript（wasm 版本为胶水脚本）；source=true 时返回 TypeScript 版本编译前的入口源码，
+// wasm=true 时返回 WebAssembly 二进制
+func GetCodeHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName, version := c.Param("funcName"), c.Param("version")
+		if c.Query("source") == "true" {
+			entry, source, exists := reg.GetSource(funcName, version)
+			if !exists {
+				c.JSON(http.StatusNotFound, gin.H{"error": "source not found"})
+				return
+			}
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s%s"`, funcName, version, path.Ext(entry)))
+			c.Data(http.StatusOK, "application/typescript; charset=utf-8", source)
+			return
+		}
+		if c.Query("wasm") == "true" {
+			wasm, exists := reg.GetWasm(funcName, version)
+			if !exists {
+				c.JSON(http.StatusNotFound, gin.H{"error": "wasm not found"})
+				return
+			}
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.wasm"`, funcName, version))
+			c.Data(http.StatusOK, "application/wasm", wasm)
+			return
+		}
+		code, exists := reg.GetCode(funcName, version)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "function version not found"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.js"`, funcName, version))
+		c.Data(http.StatusOK, "application/javascript; charset=utf-8", []byte(code))
+	}
+}
+
+func parsePositive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid number: %s", value)
+	}
+	return n, nil
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"faas/internal/registry"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GCHandler 回收未被任何版本引用的代码 blob（POST /api/gc）
+func GCHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := reg.GCBlobs()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -1,9 +1,14 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"faas/internal/metrics"
 	"faas/internal/registry"
+	"faas/internal/tracing"
 	"faas/internal/util"
 	"fmt"
+	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -12,15 +17,29 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // DeployRequest 部署请求体
 type DeployRequest struct {
-	Runtime string            `json:"runtime" binding:"required,oneof=js"` // 仅支持 JS
-	Code    string            `json:"code" binding:"required"`             // JS 源码
-	EnvVars map[string]string `json:"env_vars"`                            // 环境变量（可选）
-	Version string            `json:"version"`                             // 版本
-	Alias   string            `json:"alias"`                               // 别名（可选）
+	Runtime        string                        `json:"runtime" binding:"required,oneof=js ts wasm exec"` // js、ts（TypeScript，部署时编译）、wasm 或 exec（本地可执行文件）
+	Code           string                        `json:"code" binding:"required_unless=Runtime wasm"`      // JS / TS 源码（wasm 运行时为可选的胶水脚本，exec 运行时为带 shebang 的脚本）
+	Wasm           []byte                        `json:"wasm"`                                             // WebAssembly 二进制（wasm 运行时，JSON 中为 base64）
+	EnvVars        map[string]string             `json:"env_vars"`                                         // 环境变量（可选）
+	Version        string                        `json:"version"`                                          // 版本
+	Alias          string                        `json:"alias"`                                            // 别名（可选）
+	Entry          string                        `json:"entry"`                                            // 入口模块（压缩包部署时可选）
+	SmokeTest      *registry.SmokeTest           `json:"smoke_test"`                                       // 冒烟测试（可选），新版本通过后才切换 latest 与别名
+	Resources      *registry.ResourceLimits      `json:"resources"`                                        // 资源上限（可选）
+	Sandbox        *registry.SandboxConfig       `json:"sandbox"`                                          // 隔离设置（可选，未声明的字段使用全局默认）
+	Egress         *registry.EgressPolicy        `json:"egress"`                                           // 出站规则（可选，未声明表示不限制）
+	Services       []registry.ServiceBinding     `json:"services" binding:"omitempty,dive"`                // 服务绑定（可选）
+	DurableObjects *registry.DurableObjectConfig `json:"durable_objects"`                                  // Durable Object 类与迁移（可选）
+	Database       *registry.DatabaseConfig      `json:"database"`                                         // 私有 SQLite 数据库（可选）
+	Modules        map[string][]byte             `json:"-"`                                                // 压缩包解出的模块集（multipart 部署）
+	Sources        map[string][]byte             `json:"-"`                                                // TypeScript 源文件（编译前）
+	SourceEntry    string                        `json:"-"`                                                // TypeScript 源码入口
 }
 
 // AuthMiddleware 鉴权中间件（硬编码 Token，可扩展为用户系统）
@@ -42,51 +61,148 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// DeployHandler 部署/更新函数接口（POST /api/deploy/:funcName）
+// DeployHandler 部署/更新函数接口（POST /api/deploy/:funcName，JSON 或 multipart 压缩包）
 func DeployHandler(reg *registry.Registry) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		funcName := c.Param("funcName")
+		ctx, span := tracing.StartServer(c.Request, "DeployHandler", attribute.String("faas.function", funcName))
+		defer span.End()
+
 		var req DeployRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := bindDeployRequest(c, &req); err != nil {
+			c.JSON(uploadErrorStatus(err), bindErrorBody(err))
 			return
 		}
-
-		// 若版本为空
-		if req.Version == "" {
-			req.Version = time.Now().Format("20060102150405")
+		if err := validateNames(funcName, req.Version); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
 		// 构建函数元数据
-		subdomain := fmt.Sprintf("%s.%s.func.local", req.Version, funcName)
-		meta := &registry.FunctionMetadata{
-			Name:      funcName,
-			Subdomain: subdomain,
-			Runtime:   req.Runtime,
-			Code:      req.Code,
-			EnvVars:   req.EnvVars,
-			Version:   req.Version,
-			Alias:     req.Alias,
-		}
+		explicitVersion := req.Version != ""
+		meta := newFunctionMeta(funcName, &req)
 
-		// 注册/更新函数
-		if err := reg.RegisterOrUpdate(meta); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// 注册/更新函数（代码与配置未变化时沿用已有版本）
+		span.SetAttributes(attribute.String("faas.version", req.Version))
+		deployed, created, err := deployVersion(ctx, reg, meta, explicitVersion)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			c.JSON(deployErrorStatus(err), deployErrorBody(err))
 			return
 		}
 
 		// 返回成功结果
 		c.JSON(http.StatusOK, gin.H{
-			"status":    "success",
-			"funcName":  funcName,
-			"subdomain": subdomain,
-			"accessUrl": "http://" + subdomain,
-			"version":   req.Version,
-			"alias":     req.Alias,
+			"status":       "success",
+			"funcName":     funcName,
+			"subdomain":    deployed.Subdomain,
+			"accessUrl":    "http://" + deployed.Subdomain,
+			"version":      deployed.Version,
+			"alias":        req.Alias,
+			"deduplicated": !created,
 		})
 	}
 }
 
+// deployVersion 部署新版本。代码与配置和已有版本完全相同时不再创建版本与进程，
+// 而是将 latest（及请求的别名）指向已有版本并返回 created=false；显式指定了不同版本号时仍然创建
+func deployVersion(ctx context.Context, reg *registry.Registry, meta *registry.FunctionMetadata, explicitVersion bool) (*registry.FunctionMetadata, bool, error) {
+	existing, found := reg.FindIdentical(meta)
+	if !found || (explicitVersion && existing.Version != meta.Version) {
+		if err := reg.RegisterOrUpdate(ctx, meta); err != nil {
+			return nil, false, err
+		}
+		return meta, true, nil
+	}
+
+	if latest, ok := reg.GetByName(meta.Name); !ok || latest.Version != existing.Version {
+		alias := ""
+		if err := reg.Rollback(&alias, meta.Name, existing.Version); err != nil {
+			return nil, false, err
+		}
+	}
+	if meta.Alias != "" && meta.Alias != "latest" {
+		if err := reg.SetAlias(meta.Name, meta.Alias, existing.Version); err != nil {
+			return nil, false, err
+		}
+	}
+	return existing, false, nil
+}
+
+// validateNames 校验请求中的函数名与版本号（版本为空表示由服务端生成）
+func validateNames(funcName, version string) error {
+	if err := registry.ValidateName("function name", funcName); err != nil {
+		return err
+	}
+	if version == "" {
+		return nil
+	}
+	return registry.ValidateName("version", version)
+}
+
+// deployErrorStatus v1 部署失败的状态码：参数不合法（如运行时未启用）为 400，
+// 新版本启动失败或未通过冒烟测试为 422，其余为 500
+func deployErrorStatus(err error) int {
+	var validationErr *registry.ValidationError
+	var startErr *registry.StartError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest
+	case errors.As(err, &startErr):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// deployErrorBody v1 部署失败的响应体，启动失败时附带 start（阶段、信息与实例输出）
+func deployErrorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var startErr *registry.StartError
+	if errors.As(err, &startErr) {
+		body["start"] = startErr
+	}
+	return body
+}
+
+// newFunctionMeta 根据部署请求构建函数元数据，版本为空时以时间戳生成
+func newFunctionMeta(funcName string, req *DeployRequest) *registry.FunctionMetadata {
+	if req.Version == "" {
+		req.Version = time.Now().Format("20060102150405")
+	}
+	meta := &registry.FunctionMetadata{
+		Name:        funcName,
+		Subdomain:   fmt.Sprintf("%s.%s.func.local", req.Version, funcName),
+		Runtime:     req.Runtime,
+		Code:        req.Code,
+		EnvVars:     req.EnvVars,
+		Version:     req.Version,
+		Alias:       req.Alias,
+		Entry:       req.Entry,
+		Modules:     req.Modules,
+		Sources:     req.Sources,
+		SourceEntry: req.SourceEntry,
+		Wasm:        req.Wasm,
+		SmokeTest:   req.SmokeTest,
+		Services:    req.Services,
+	}
+	if req.Resources != nil {
+		meta.Resources = *req.Resources
+	}
+	if req.Sandbox != nil {
+		meta.Sandbox = *req.Sandbox
+	}
+	if req.Egress != nil {
+		meta.Egress = *req.Egress
+	}
+	if req.DurableObjects != nil {
+		meta.DurableObjects = *req.DurableObjects
+	}
+	if req.Database != nil {
+		meta.Database = *req.Database
+	}
+	return meta
+}
+
 // RollbackRequest 回滚请求体
 type RollbackRequest struct {
 	Alias   string `json:"alias"`                      // 要修改的别名（如latest）
@@ -214,45 +330,100 @@ func ListVersionsHandler(reg *registry.Registry) gin.HandlerFunc {
 
 // ProxyHandler 路由转发处理器：解析子域名，转发请求到 workerd 进程
 func ProxyHandler(reg *registry.Registry) http.HandlerFunc {
+	route := routeHandler(reg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(registry.ServiceCallerHeader) // 调用方只能由平台的服务绑定设置
+		route(w, r)
+	}
+}
+
+// ServiceHandler 服务绑定请求的处理器（通过 registry.SetServiceHandler 设置），
+// 与路由端口的请求一样限流、唤醒、记录访问日志并沿用请求中的 traceparent
+func ServiceHandler(reg *registry.Registry) http.HandlerFunc {
+	return routeHandler(reg)
+}
+
+// routeHandler 按 Host 查找函数版本并转发
+func routeHandler(reg *registry.Registry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// 生成/沿用请求 ID，转发给 workerd 并在响应中回显
+		requestID := requestIDFrom(r)
+		r.Header.Set(RequestIDHeader, requestID)
+		w.Header().Set(RequestIDHeader, requestID)
+
+		// 记录请求状态码、字节数与耗时
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		w = rec
+		entry := &accessEntry{requestID: requestID, startAt: time.Now()}
+		defer func() { logAccess(r, rec, entry) }()
+
+		// 链路追踪：沿用上游 traceparent，并在转发时传给函数
+		ctx, span := tracing.StartServer(r, "ProxyHandler",
+			attribute.String("http.request.method", r.Method),
+			attribute.String("server.address", r.Host),
+			attribute.String("faas.request_id", requestID))
+		if caller := r.Header.Get(registry.ServiceCallerHeader); caller != "" {
+			span.SetAttributes(attribute.String("faas.caller", caller))
+		}
+		defer func() {
+			span.SetAttributes(
+				attribute.Int("http.response.status_code", rec.status),
+				attribute.Bool("faas.cold_start", entry.coldStart))
+			if rec.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(rec.status))
+			}
+			span.End()
+		}()
+
 		// 提取子域名（如 foo.func.local）
 		subdomain := r.Host
 		if subdomain == "" {
-			http.Error(w, "missing Host header", http.StatusBadRequest)
+			platformError(w, "missing Host header", http.StatusBadRequest)
 			return
 		}
 
 		// 查询函数元数据
-		meta, exists := reg.GetBySubdomain(subdomain)
+		meta, alias, exists := resolveFunction(reg, subdomain)
 		if !exists {
-			// 子域名未找到时，尝试通过别名查询
-			meta, exists = reg.GetByAlias(subdomain)
-			if !exists {
-				// latest 情况
-				meta, exists = reg.GetByName(strings.Split(subdomain, ".")[0])
-				if !exists {
-					http.Error(w, "function not found", http.StatusNotFound)
-					return
-				}
-			}
+			platformError(w, "function not found", http.StatusNotFound)
+			return
+		}
+		entry.function, entry.version, entry.alias = meta.Name, meta.Version, alias
+		span.SetAttributes(
+			attribute.String("faas.function", meta.Name),
+			attribute.String("faas.version", meta.Version),
+			attribute.String("faas.alias", alias))
+		defer func() {
+			metrics.ObserveProxyRequest(meta.Name, meta.Version, alias, rec.status, time.Since(entry.startAt))
+		}()
+
+		// 限流检查（在唤醒之前，避免被拒绝的流量唤醒挂起的版本）
+		if !checkRateLimit(reg, w, r, meta.Name, alias) {
+			return
 		}
 
 		// 检查进程状态并更新访问时间
 		reg.Mu.Lock()
 		if meta.Status == "" || meta.Status == "suspended" {
+			entry.coldStart = true
+			wakeCtx, wakeSpan := tracing.Start(ctx, "WakeUp")
 			// 唤醒进程：重新分配端口
 			freePort, err := util.GetFreePort()
 			if err != nil {
 				reg.Mu.Unlock()
-				http.Error(w, "failed to get free port", http.StatusInternalServerError)
+				tracing.End(wakeSpan, err)
+				platformError(w, "failed to get free port", http.StatusInternalServerError)
 				return
 			}
 			meta.Workerd.Port = freePort
 
 			// 启动进程
-			if err := reg.StartWorkerd(meta); err != nil {
+			err = reg.StartInstance(wakeCtx, meta)
+			tracing.End(wakeSpan, err)
+			if err != nil {
 				reg.Mu.Unlock()
-				http.Error(w, "failed to wake up function", http.StatusInternalServerError)
+				log.Printf("request %s: wake up %s:%s failed: %v", requestID, meta.Name, meta.Version, err)
+				platformError(w, "failed to wake up function", http.StatusInternalServerError)
 				return
 			}
 			meta.Status = "running"
@@ -260,13 +431,73 @@ func ProxyHandler(reg *registry.Registry) http.HandlerFunc {
 		meta.LastAccessed = time.Now()
 		reg.Mu.Unlock()
 
-		// 转发请求到 workerd 进程（本地端口）
-		targetUrl, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", meta.Workerd.Port))
+		// 转发请求到函数实例（本地端口）
+		targetUrl, err := url.Parse("http://" + reg.Address(meta))
 		if err != nil {
-			http.Error(w, "invalid target url", http.StatusInternalServerError)
+			platformError(w, "invalid target url", http.StatusInternalServerError)
 			return
 		}
+		tracing.Inject(ctx, r.Header)
 		proxy := httputil.NewSingleHostReverseProxy(targetUrl)
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			resp.Header.Del(RequestIDHeader) // 已由平台设置，避免重复
+			return nil
+		}
 		proxy.ServeHTTP(w, r)
 	}
 }
+
+// resolveFunction 根据子域名查找函数版本，并返回访问所用的别名（版本子域名访问时为空）
+func resolveFunction(reg *registry.Registry, subdomain string) (*registry.FunctionMetadata, string, bool) {
+	label := strings.Split(subdomain, ".")[0]
+
+	meta, exists := reg.GetBySubdomain(subdomain)
+	if !exists {
+		// 子域名未找到时，尝试通过别名查询
+		meta, exists = reg.GetByAlias(subdomain)
+		if !exists {
+			// latest 情况
+			meta, exists = reg.GetByName(label)
+			if !exists {
+				return nil, "", false
+			}
+			return meta, "latest", true
+		}
+	}
+	if label == meta.Version {
+		return meta, "", true
+	}
+	return meta, label, true
+}
+
+// PlatformErrorHeader 标记由平台（而非函数）返回的错误响应
+const PlatformErrorHeader = "X-Faas-Error"
+
+// platformError 返回平台错误，便于客户端区分函数自身的错误响应
+func platformError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set(PlatformErrorHeader, "true")
+	http.Error(w, message, status)
+}
+
+// statusRecorder 记录响应状态码与字节数
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层 Flusher 等接口
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
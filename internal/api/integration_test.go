@@ -0,0 +1,1538 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"faas/internal/archive"
+	"faas/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeWorkerdBin 由 TestMain 从 testdata/fakeworkerd 编译的 workerd 替身
+var fakeWorkerdBin string
+
+func TestMain(m *testing.M) {
+	registry.SandboxInit() // 隔离运行的测试会以初始化进程的身份重新执行测试二进制
+	gin.SetMode(gin.TestMode)
+
+	dir, err := os.MkdirTemp("", "fakeworkerd")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fakeWorkerdBin = filepath.Join(dir, "workerd")
+	build := exec.Command("go", "build", "-o", fakeWorkerdBin, "./testdata/fakeworkerd")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "build fake workerd:", err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testPlatform 使用 fake workerd 的完整平台：部署 API（gin 引擎）与函数代理
+type testPlatform struct {
+	t     *testing.T
+	dir   string
+	reg   *registry.Registry
+	api   *gin.Engine
+	proxy http.Handler
+}
+
+func newTestPlatform(t *testing.T) *testPlatform {
+	t.Helper()
+	p := &testPlatform{t: t, dir: t.TempDir()}
+	p.open()
+	t.Cleanup(func() { p.reg.Close() })
+	return p
+}
+
+// open 在存储目录上创建注册表，已有数据库时从中恢复
+func (p *testPlatform) open() {
+	p.t.Helper()
+	reg, err := registry.New(fakeWorkerdBin, p.dir)
+	if err != nil {
+		p.t.Fatalf("create registry: %v", err)
+	}
+	reg.SetServiceHandler(ServiceHandler(reg))
+	p.reg, p.api, p.proxy = reg, NewRouter(reg), ProxyHandler(reg)
+}
+
+// restart 关闭注册表（停止全部实例）后在同一存储目录上重新创建，模拟服务重启
+func (p *testPlatform) restart() {
+	p.t.Helper()
+	if err := p.reg.Close(); err != nil {
+		p.t.Fatalf("close registry: %v", err)
+	}
+	p.open()
+}
+
+// call 调用部署 API，body 非 nil 时以 JSON 发送
+func (p *testPlatform) call(method, path string, body any) *httptest.ResponseRecorder {
+	p.t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			p.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	p.api.ServeHTTP(rec, req)
+	return rec
+}
+
+// upload 以 multipart/form-data 调用部署 API：metadata 为 JSON 字段，archive 为压缩包部分
+func (p *testPlatform) upload(funcName string, metadata gin.H, archive []byte) *httptest.ResponseRecorder {
+	p.t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if err := mw.WriteField("metadata", string(meta)); err != nil {
+		p.t.Fatal(err)
+	}
+	part, err := mw.CreateFormFile("archive", "code.zip")
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	part.Write(archive)
+	if err := mw.Close(); err != nil {
+		p.t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/deploy/"+funcName, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	p.api.ServeHTTP(rec, req)
+	return rec
+}
+
+// mustCall 调用部署 API 并要求返回 200
+func (p *testPlatform) mustCall(method, path string, body any) *httptest.ResponseRecorder {
+	p.t.Helper()
+	rec := p.call(method, path, body)
+	if rec.Code != http.StatusOK {
+		p.t.Fatalf("%s %s: status %d: %s", method, path, rec.Code, rec.Body.String())
+	}
+	return rec
+}
+
+// deploy 部署返回固定正文的函数版本
+func (p *testPlatform) deploy(funcName, version, alias, body string) {
+	p.t.Helper()
+	p.mustCall("POST", "/api/deploy/"+funcName, gin.H{
+		"runtime": "js",
+		"code":    workerScript(body),
+		"version": version,
+		"alias":   alias,
+	})
+}
+
+// request 通过代理访问函数
+func (p *testPlatform) request(host string) *httptest.ResponseRecorder {
+	p.t.Helper()
+	req := httptest.NewRequest("GET", "http://"+host+"/", nil)
+	rec := httptest.NewRecorder()
+	p.proxy.ServeHTTP(rec, req)
+	return rec
+}
+
+// expectBody 访问函数并检查响应正文
+func (p *testPlatform) expectBody(host, want string) *httptest.ResponseRecorder {
+	p.t.Helper()
+	rec := p.request(host)
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		p.t.Fatalf("GET %s = %d %q, want 200 %q", host, rec.Code, rec.Body.String(), want)
+	}
+	return rec
+}
+
+// version 读取版本详情
+func (p *testPlatform) version(funcName, version string) registry.VersionInfo {
+	p.t.Helper()
+	rec := p.mustCall("GET", fmt.Sprintf("/api/v2/functions/%s/versions/%s", funcName, version), nil)
+	var info registry.VersionInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		p.t.Fatal(err)
+	}
+	return info
+}
+
+func workerScript(body string) string {
+	return fmt.Sprintf(`addEventListener("fetch", (event) => event.respondWith(new Response(%q)));`, body)
+}
+
+func TestIntegrationDeployAndRoute(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "", "hello v1")
+	p.deploy("hello", "v2", "", "hello v2")
+
+	p.expectBody("v1.hello.func.local", "hello v1")
+	p.expectBody("v2.hello.func.local", "hello v2")
+	p.expectBody("latest.hello.func.local", "hello v2")
+	p.expectBody("hello.func.local", "hello v2")
+
+	if rec := p.request("v3.hello.func.local"); rec.Code != http.StatusNotFound || rec.Header().Get(PlatformErrorHeader) == "" {
+		t.Fatalf("unknown version: status %d, platform error %q", rec.Code, rec.Header().Get(PlatformErrorHeader))
+	}
+	if rec := p.request("missing.func.local"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown function: status %d", rec.Code)
+	}
+
+	// 环境变量作为 text 绑定传给 workerd
+	p.mustCall("POST", "/api/deploy/env", gin.H{
+		"runtime":  "js",
+		"code":     workerScript("greeting={{GREETING}}"),
+		"version":  "v1",
+		"env_vars": map[string]string{"GREETING": `say "hi"`},
+	})
+	p.expectBody("v1.env.func.local", `greeting=say "hi"`)
+}
+
+func TestIntegrationTypeScriptDeploy(t *testing.T) {
+	p := newTestPlatform(t)
+	code := `interface Greeting { text: string }
+const greeting: Greeting = { text: "hi" };
+addEventListener("fetch", (event: FetchEvent) => event.respondWith(new Response("hello ts" as string)));`
+	p.mustCall("POST", "/api/deploy/greet", gin.H{"runtime": "ts", "code": code, "version": "v1"})
+	p.expectBody("latest.greet.func.local", "hello ts")
+
+	// workerd 运行擦除类型后的脚本，源码单独保留
+	meta, ok := p.reg.GetByName("greet")
+	if !ok {
+		t.Fatal("greet not registered")
+	}
+	if meta.Runtime != RuntimeTypeScript || meta.SourceEntry != defaultTypeScriptEntry || meta.Entry != "" {
+		t.Fatalf("runtime %q, source entry %q, entry %q", meta.Runtime, meta.SourceEntry, meta.Entry)
+	}
+	if strings.Contains(meta.Code, "interface") || strings.Contains(meta.Code, ": FetchEvent") || len(meta.Code) != len(code) {
+		t.Fatalf("compiled code = %q", meta.Code)
+	}
+}
+
+// zipArchive 按 名称 -> 内容 生成 zip 压缩包
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// tarGzArchive 按 名称 -> 内容 生成 tar.gz 压缩包
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// moduleScript 返回固定正文的 ES 模块
+func moduleScript(body string) string {
+	return fmt.Sprintf(`export default { fetch: () => new Response(%q) };`, body)
+}
+
+func TestIntegrationArchiveDeploy(t *testing.T) {
+	p := newTestPlatform(t)
+	files := map[string]string{
+		"index.js":     moduleScript("default entry"),
+		"pkg/main.js":  moduleScript("package.json main"),
+		"app/faas.js":  moduleScript("faas.json entry"),
+		"meta.js":      moduleScript("metadata entry"),
+		"lib/util.js":  "export const x = 1;",
+		"package.json": `{"main": "pkg/main.js"}`,
+		"faas.json":    `{"entry": "./app/faas.js"}`,
+	}
+	without := func(names ...string) map[string]string {
+		out := make(map[string]string)
+		for name, content := range files {
+			if !slices.Contains(names, name) {
+				out[name] = content
+			}
+		}
+		return out
+	}
+
+	// 入口：metadata.entry > faas.json 的 entry > package.json 的 main > 默认文件；zip 与 tar.gz 均可
+	tests := []struct {
+		version string
+		entry   string
+		archive []byte
+		want    string
+	}{
+		{"v1", "meta.js", zipArchive(t, files), "metadata entry"},
+		{"v2", "", tarGzArchive(t, files), "faas.json entry"},
+		{"v3", "", zipArchive(t, without("faas.json")), "package.json main"},
+		{"v4", "", tarGzArchive(t, without("faas.json", "package.json")), "default entry"},
+	}
+	for _, tt := range tests {
+		if rec := p.upload("site", gin.H{"runtime": "js", "version": tt.version, "entry": tt.entry}, tt.archive); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.version, rec.Code, rec.Body.String())
+		}
+		p.expectBody(tt.version+".site.func.local", tt.want)
+	}
+	meta, ok := p.reg.GetByVersion("site", "v2")
+	if !ok || meta.Entry != "app/faas.js" || len(meta.Modules) != len(files) {
+		t.Fatalf("v2 entry %q, %d modules", meta.Entry, len(meta.Modules))
+	}
+
+	// 入口无效或压缩包不合法时返回 400
+	for name, data := range map[string][]byte{
+		"missing entry":  zipArchive(t, map[string]string{"lib/util.js": "export const x = 1;"}),
+		"not an archive": []byte("plain text"),
+		"traversal":      zipArchive(t, map[string]string{"../evil.js": moduleScript("x"), "index.js": moduleScript("x")}),
+	} {
+		if rec := p.upload("site", gin.H{"runtime": "js"}, data); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 大小限制：请求体超出上限为 413，解压后的单个文件超出上限为 400
+	oldUpload, oldLimits := MaxUploadSize, archive.DefaultLimits
+	t.Cleanup(func() { MaxUploadSize, archive.DefaultLimits = oldUpload, oldLimits })
+	MaxUploadSize = 4 << 10
+	big := strings.Repeat("x", 8<<10)
+	if rec := p.upload("site", gin.H{"runtime": "js"}, []byte(big)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized request: status %d: %s", rec.Code, rec.Body.String())
+	}
+	archive.DefaultLimits.MaxFileSize = 1 << 10
+	rec := p.upload("site", gin.H{"runtime": "js"}, zipArchive(t, map[string]string{"index.js": moduleScript(big)}))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "exceeds") {
+		t.Fatalf("oversized file: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if detail, _ := p.reg.DescribeFunction("site"); len(detail.Versions) != len(tests) || detail.LatestVersion != "v4" {
+		t.Fatalf("rejected uploads changed the function: %+v", detail)
+	}
+}
+
+func TestIntegrationWasmDeploy(t *testing.T) {
+	p := newTestPlatform(t)
+	// 最小的合法模块：只有文件头，没有任何段
+	module := append([]byte(nil), wasmMagic...)
+	sum := sha256.Sum256(module)
+
+	// 模块与环境变量通过 WASM_MODULE / WASM_ENV 绑定传给胶水脚本
+	p.mustCall("POST", "/api/deploy/mod", gin.H{
+		"runtime":  "wasm",
+		"wasm":     module,
+		"code":     workerScript("module={{WASM_MODULE}} env={{WASM_ENV}}"),
+		"version":  "v1",
+		"env_vars": map[string]string{"GREETING": `say "hi"`},
+	})
+	p.expectBody("v1.mod.func.local", fmt.Sprintf(`module=%x env={"GREETING":"say \"hi\""}`, sum))
+
+	// 未提供胶水脚本时使用默认的 WASI 胶水脚本
+	p.mustCall("POST", "/api/deploy/mod", gin.H{"runtime": "wasm", "wasm": module, "version": "v2"})
+	meta, ok := p.reg.GetByVersion("mod", "v2")
+	if !ok || meta.Code != defaultWasmGlue || meta.WasmHash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("v2 = %+v", meta)
+	}
+	if rec := p.request("v2.mod.func.local"); rec.Code != http.StatusOK {
+		t.Fatalf("default glue: status %d", rec.Code)
+	}
+}
+
+func TestIntegrationExecDeploy(t *testing.T) {
+	registry.ExecRuntimeEnabled = true
+	t.Cleanup(func() { registry.ExecRuntimeEnabled = false })
+	p := newTestPlatform(t)
+
+	// 入口脚本在版本目录的 app/ 下运行，通过 HOST / PORT 监听，环境变量只包含声明的与平台提供的
+	script := fmt.Sprintf("#!/bin/sh\nexec %q exec\n", fakeWorkerdBin)
+	p.mustCall("POST", "/api/deploy/native", gin.H{"runtime": "exec", "code": script, "version": "v1", "env_vars": map[string]string{"GREETING": "hi"}})
+	p.expectBody("v1.native.func.local", "native v1 hi app")
+	p.mustCall("POST", "/api/deploy/native", gin.H{"runtime": "exec", "code": script, "version": "v2"})
+	p.expectBody("latest.native.func.local", "native v2  app")
+
+	meta, ok := p.reg.GetByVersion("native", "v1")
+	if !ok || meta.Workerd.ConfPath != "" || filepath.Base(meta.Workerd.CodePath) != "native" {
+		t.Fatalf("v1 = %+v", meta)
+	}
+	if info, err := os.Stat(meta.Workerd.CodePath); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Fatalf("entry is not executable: %v, %v", info, err)
+	}
+
+	// 挂起后重新唤醒
+	p.mustCall("POST", "/api/stop/native", gin.H{"version": "v1"})
+	p.expectBody("v1.native.func.local", "native v1 hi app")
+}
+
+func TestIntegrationAlias(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "stable", "hello v1")
+	p.deploy("hello", "v2", "", "hello v2")
+	p.expectBody("stable.hello.func.local", "hello v1")
+
+	p.mustCall("POST", "/api/alias/hello", gin.H{"alias": "canary", "version": "v2"})
+	p.expectBody("canary.hello.func.local", "hello v2")
+
+	p.mustCall("POST", "/api/alias/hello", gin.H{"alias": "stable", "version": "v2"})
+	p.expectBody("stable.hello.func.local", "hello v2")
+
+	// latest 由部署与回滚维护，不能通过别名接口修改
+	if rec := p.call("POST", "/api/alias/hello", gin.H{"alias": "latest", "version": "v1"}); rec.Code == http.StatusOK {
+		t.Fatal("setting alias latest succeeded")
+	}
+	p.expectBody("latest.hello.func.local", "hello v2")
+
+	p.mustCall("POST", "/api/deleteAlias/hello", gin.H{"alias": "canary"})
+	if rec := p.request("canary.hello.func.local"); rec.Code != http.StatusNotFound {
+		t.Fatalf("deleted alias: status %d", rec.Code)
+	}
+}
+
+func TestIntegrationRollback(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "", "hello v1")
+	p.deploy("hello", "v2", "", "hello v2")
+
+	p.mustCall("POST", "/api/rollback/hello", gin.H{"version": "v1"})
+	p.expectBody("latest.hello.func.local", "hello v1")
+	p.expectBody("v2.hello.func.local", "hello v2")
+
+	if rec := p.call("POST", "/api/rollback/hello", gin.H{"version": "v9"}); rec.Code == http.StatusOK {
+		t.Fatal("rollback to missing version succeeded")
+	}
+
+	// 重新部署相同代码不创建新版本，latest 指回已有版本
+	rec := p.mustCall("POST", "/api/deploy/hello", gin.H{"runtime": "js", "code": workerScript("hello v2")})
+	var resp struct {
+		Version      string `json:"version"`
+		Deduplicated bool   `json:"deduplicated"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Version != "v2" || !resp.Deduplicated {
+		t.Fatalf("identical deploy = %+v, want deduplicated v2", resp)
+	}
+	p.expectBody("latest.hello.func.local", "hello v2")
+}
+
+func TestIntegrationRateLimit(t *testing.T) {
+	p := newTestPlatform(t)
+	rule := gin.H{"alias": "stable", "rate": 0.001, "burst": 2}
+
+	// 函数或别名不存在时返回 404，不保存规则
+	if rec := p.call("PUT", "/api/v2/functions/hello/ratelimits", rule); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing function: status %d: %s", rec.Code, rec.Body.String())
+	}
+	p.deploy("hello", "v1", "", "hello v1")
+	if rec := p.call("POST", "/api/ratelimit/hello", rule); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing alias: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := p.call("POST", "/api/v2/apply", gin.H{"name": "hello", "runtime": "js", "code": workerScript("hello v1"), "version": "v1",
+		"limits": gin.H{"rate_limits": []gin.H{rule}}}); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("apply with unknown alias: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rules := p.reg.ListRateLimits("hello"); len(rules) != 0 {
+		t.Fatalf("rules = %+v", rules)
+	}
+
+	p.mustCall("POST", "/api/alias/hello", gin.H{"alias": "stable", "version": "v1"})
+	p.mustCall("PUT", "/api/v2/functions/hello/ratelimits", rule)
+	for _, remaining := range []string{"1", "0"} {
+		rec := p.expectBody("stable.hello.func.local", "hello v1")
+		if rec.Header().Get("X-RateLimit-Limit") != "2" || rec.Header().Get("X-RateLimit-Remaining") != remaining {
+			t.Fatalf("quota headers: %v", rec.Header())
+		}
+	}
+	// 规则只作用于声明的别名
+	if rec := p.expectBody("latest.hello.func.local", "hello v1"); rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatalf("latest is rate limited: %v", rec.Header())
+	}
+
+	// 超出配额的请求在唤醒之前被拒绝，挂起的版本保持挂起
+	p.mustCall("POST", "/api/stop/hello", gin.H{"version": "v1"})
+	rec := p.request("stable.hello.func.local")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get(PlatformErrorHeader) == "" {
+		t.Fatalf("over quota: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-RateLimit-Limit") != "2" || rec.Header().Get("X-RateLimit-Remaining") != "0" || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("quota headers: %v", rec.Header())
+	}
+	if info := p.version("hello", "v1"); info.Status != "suspended" {
+		t.Fatalf("rejected request woke the version: status %q", info.Status)
+	}
+}
+
+func TestIntegrationSuspendAndWake(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "", "hello v1")
+	before := p.expectBody("v1.hello.func.local", "hello v1").Header().Get("X-Fake-Pid")
+
+	p.mustCall("POST", "/api/stop/hello", gin.H{"version": "v1"})
+	if info := p.version("hello", "v1"); info.Status != "suspended" || info.Port != 0 {
+		t.Fatalf("after stop: status %q port %d", info.Status, info.Port)
+	}
+	if rec := p.call("POST", "/api/stop/hello", gin.H{"version": "v1"}); rec.Code == http.StatusOK {
+		t.Fatal("stopping a suspended version succeeded")
+	}
+
+	// 请求唤醒挂起的版本：启动新进程
+	after := p.expectBody("v1.hello.func.local", "hello v1").Header().Get("X-Fake-Pid")
+	if after == before {
+		t.Fatalf("woken version served by the old process %s", before)
+	}
+	if info := p.version("hello", "v1"); info.Status != "running" {
+		t.Fatalf("after wake: status %q", info.Status)
+	}
+}
+
+func TestIntegrationRestartFromDB(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "stable", "hello v1")
+	p.deploy("hello", "v2", "", "hello v2")
+	p.deploy("hello", "v3", "", "hello v3")
+	p.mustCall("POST", "/api/alias/hello", gin.H{"alias": "canary", "version": "v3"})
+	p.mustCall("POST", "/api/rollback/hello", gin.H{"version": "v2"})
+	p.mustCall("POST", "/api/stop/hello", gin.H{"version": "v3"}) // 停止不影响 latest
+	p.mustCall("POST", "/api/deploy/other", gin.H{"runtime": "js", "code": workerScript("other"), "version": "v1"})
+	before := p.expectBody("latest.hello.func.local", "hello v2").Header().Get("X-Fake-Pid")
+
+	p.restart()
+
+	// 重启后不预先启动实例，全部版本挂起
+	for _, version := range []string{"v1", "v2", "v3"} {
+		if info := p.version("hello", version); info.Status != "suspended" || info.Port != 0 {
+			t.Fatalf("%s after restart: status %q port %d", version, info.Status, info.Port)
+		}
+	}
+
+	after := p.expectBody("latest.hello.func.local", "hello v2").Header().Get("X-Fake-Pid")
+	if after == before {
+		t.Fatalf("restarted registry reused process %s", before)
+	}
+	p.expectBody("stable.hello.func.local", "hello v1")
+	p.expectBody("canary.hello.func.local", "hello v3")
+	p.expectBody("v3.hello.func.local", "hello v3")
+	p.expectBody("other.func.local", "other")
+}
+
+func TestIntegrationDelete(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "", "hello v1")
+	p.deploy("hello", "v2", "", "hello v2")
+
+	// 删除 latest 指向的版本后 latest 回到剩余的版本
+	p.mustCall("POST", "/api/deleteVersion/hello", gin.H{"version": "v2"})
+	if rec := p.request("v2.hello.func.local"); rec.Code != http.StatusNotFound {
+		t.Fatalf("deleted version: status %d", rec.Code)
+	}
+	p.expectBody("latest.hello.func.local", "hello v1")
+	if _, err := os.Stat(filepath.Join(p.dir, "hello", "v2")); !os.IsNotExist(err) {
+		t.Fatalf("files of deleted version remain: %v", err)
+	}
+
+	// 通过别名接口指向被删除版本的别名一并删除，重新部署同名版本并重启后也不会恢复
+	p.deploy("hello", "v3", "", "hello v3")
+	p.mustCall("POST", "/api/alias/hello", gin.H{"alias": "canary", "version": "v3"})
+	if rec := p.call("DELETE", "/api/v2/functions/hello/versions/v3", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete version: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := p.request("canary.hello.func.local"); rec.Code != http.StatusNotFound {
+		t.Fatalf("alias of deleted version: status %d", rec.Code)
+	}
+	p.deploy("hello", "v3", "", "hello v3")
+	p.restart()
+	if _, ok := p.reg.Aliases("hello")["canary"]; ok {
+		t.Fatal("alias of deleted version restored after restart")
+	}
+
+	p.mustCall("POST", "/api/delete/hello", nil)
+	for _, host := range []string{"v1.hello.func.local", "latest.hello.func.local", "hello.func.local"} {
+		if rec := p.request(host); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s after delete: status %d", host, rec.Code)
+		}
+	}
+	if rec := p.call("GET", "/api/v2/functions/hello", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("describe deleted function: status %d", rec.Code)
+	}
+
+	// 删除后重启不会恢复
+	p.restart()
+	if rec := p.request("hello.func.local"); rec.Code != http.StatusNotFound {
+		t.Fatalf("deleted function restored after restart: status %d", rec.Code)
+	}
+}
+
+func TestIntegrationDeployStartFailure(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "stable", "hello v1")
+
+	rec := p.call("POST", "/api/deploy/hello", gin.H{
+		"runtime": "js",
+		"code":    workerScript("hello v2") + "\nthrow new Error(\"fake:fail\");",
+		"version": "v2",
+		"alias":   "stable",
+	})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("broken deploy: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Start registry.StartError `json:"start"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := registry.StartError{
+		Stage:   registry.StageStart,
+		Message: "service hello: Uncaught Error: fake:fail",
+		File:    "hello.js",
+		Line:    2,
+		Column:  18,
+		Source:  `throw new Error("fake:fail");`,
+	}
+	got := resp.Start
+	if got.Stage != want.Stage || got.Message != want.Message || got.File != want.File || got.Line != want.Line ||
+		got.Column != want.Column || got.Source != want.Source || !strings.Contains(got.Output, "at hello.js:2:18") {
+		t.Fatalf("start error = %+v, want %+v with the instance output", got, want)
+	}
+
+	// 路由不变，失败的版本没有注册
+	p.expectBody("latest.hello.func.local", "hello v1")
+	p.expectBody("stable.hello.func.local", "hello v1")
+	if rec := p.request("v2.hello.func.local"); rec.Code != http.StatusNotFound {
+		t.Fatalf("failed version is routable: status %d", rec.Code)
+	}
+	if _, err := os.Stat(filepath.Join(p.dir, "hello", "v2")); !os.IsNotExist(err) {
+		t.Fatalf("files of failed version remain: %v", err)
+	}
+
+	// v2 接口返回 start_failed，同一版本号修复后可以重新部署
+	rec = p.call("PUT", "/api/v2/functions/hello/versions/v2", gin.H{"runtime": "js", "code": "// fake:fail\n" + workerScript("hello v2")})
+	var v2Resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &v2Resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || v2Resp.Error.Code != CodeStartFailed || v2Resp.Error.Start == nil {
+		t.Fatalf("v2 broken deploy = %d %s", rec.Code, rec.Body.String())
+	}
+	p.deploy("hello", "v2", "", "hello v2")
+	p.expectBody("latest.hello.func.local", "hello v2")
+}
+
+func TestIntegrationDeploySmokeTest(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "stable", "hello v1")
+
+	deploy := func(version, body string, smokeTest gin.H) *httptest.ResponseRecorder {
+		return p.call("POST", "/api/deploy/hello", gin.H{
+			"runtime":    "js",
+			"code":       workerScript(body),
+			"version":    version,
+			"alias":      "stable",
+			"smoke_test": smokeTest,
+		})
+	}
+
+	rec := deploy("v2", "hello v2", gin.H{"path": "/healthz", "expect_body": "healthy"})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("failing smoke test: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Start registry.StartError `json:"start"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Start.Stage != registry.StageSmokeTest {
+		t.Fatalf("start error = %+v, want stage smoke_test", resp.Start)
+	}
+	p.expectBody("latest.hello.func.local", "hello v1")
+	p.expectBody("stable.hello.func.local", "hello v1")
+	if rec := p.request("v2.hello.func.local"); rec.Code != http.StatusNotFound {
+		t.Fatalf("version that failed the smoke test is routable: status %d", rec.Code)
+	}
+
+	if rec := deploy("v2", "hello v2", gin.H{"path": "healthz"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid smoke test: status %d", rec.Code)
+	}
+
+	// 通过冒烟测试后才切换 latest 与别名
+	if rec := deploy("v2", "hello v2 healthy", gin.H{"method": "POST", "path": "/healthz", "body": "{}", "expect_status": 200, "expect_body": "healthy"}); rec.Code != http.StatusOK {
+		t.Fatalf("passing smoke test: status %d: %s", rec.Code, rec.Body.String())
+	}
+	p.expectBody("latest.hello.func.local", "hello v2 healthy")
+	p.expectBody("stable.hello.func.local", "hello v2 healthy")
+	p.expectBody("v1.hello.func.local", "hello v1")
+
+	// 部署时的别名会持久化，重启后仍指向新版本
+	p.restart()
+	p.expectBody("stable.hello.func.local", "hello v2 healthy")
+}
+
+func TestIntegrationLastStartError(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("hello", "v1", "", "hello v1")
+	p.mustCall("POST", "/api/stop/hello", gin.H{"version": "v1"})
+
+	// 换成报告配置错误并退出的 workerd，唤醒失败
+	broken := filepath.Join(t.TempDir(), "workerd")
+	script := "#!/bin/sh\necho \"$2:2:1: error: Parse error.\" >&2\nexit 1\n"
+	if err := os.WriteFile(broken, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	p.reg.RegisterRuntime("js", registry.NewWorkerdRuntime(p.reg, broken))
+	if rec := p.request("v1.hello.func.local"); rec.Code != http.StatusInternalServerError || rec.Header().Get(PlatformErrorHeader) == "" {
+		t.Fatalf("wake with broken workerd: status %d", rec.Code)
+	}
+	rec := p.call("PATCH", "/api/v2/functions/hello/versions/v1", gin.H{"status": "running"})
+	var v2Resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &v2Resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || v2Resp.Error.Code != CodeStartFailed {
+		t.Fatalf("start with broken workerd = %d %s", rec.Code, rec.Body.String())
+	}
+
+	check := func() {
+		t.Helper()
+		got := p.version("hello", "v1").LastStartError
+		if got == nil || got.Message != "hello.capnp:2:1: error: Parse error." || got.File != "hello.capnp" || got.Line != 2 ||
+			got.Source != `using Workerd = import "/workerd/workerd.capnp";` || got.At.IsZero() {
+			t.Fatalf("last start error = %+v", got)
+		}
+	}
+	check()
+
+	// 启动错误随版本持久化，重启后仍可查询；恢复正常后可以再次唤醒
+	p.restart()
+	check()
+	p.expectBody("v1.hello.func.local", "hello v1")
+}
+
+func TestIntegrationResourceLimits(t *testing.T) {
+	p := newTestPlatform(t)
+	deploy := func(version string, resources gin.H) *httptest.ResponseRecorder {
+		return p.call("POST", "/api/deploy/hello", gin.H{
+			"runtime":   "js",
+			"code":      workerScript("hello " + version),
+			"version":   version,
+			"resources": resources,
+		})
+	}
+
+	if rec := deploy("v1", gin.H{"memory_mb": 1}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid resources: status %d", rec.Code)
+	}
+	if rec := deploy("v1", gin.H{"open_files": 256}); rec.Code != http.StatusOK {
+		t.Fatalf("deploy: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// 打开文件数在任何平台（cgroup 或 rlimit）上都以 RLIMIT_NOFILE 生效
+	check := func() {
+		t.Helper()
+		pid := p.expectBody("v1.hello.func.local", "hello v1").Header().Get("X-Fake-Pid")
+		limits, err := os.ReadFile("/proc/" + pid + "/limits")
+		if err != nil {
+			t.Skipf("read process limits: %v", err)
+		}
+		if !regexp.MustCompile(`Max open files\s+256\s+256`).Match(limits) {
+			t.Fatalf("open files limit not applied:\n%s", limits)
+		}
+		if got := p.version("hello", "v1").Resources; got != (registry.ResourceLimits{OpenFiles: 256}) {
+			t.Fatalf("resources = %+v", got)
+		}
+	}
+	check()
+
+	// 限制随版本持久化，重启后唤醒的进程同样受限
+	p.restart()
+	check()
+
+	// 只修改限制会创建新版本
+	var resp struct {
+		Deduplicated bool `json:"deduplicated"`
+	}
+	rec := p.mustCall("POST", "/api/deploy/hello", gin.H{"runtime": "js", "code": workerScript("hello v1"), "resources": gin.H{"open_files": 512}})
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Deduplicated {
+		t.Fatal("deploy with different resources was deduplicated")
+	}
+}
+
+func TestIntegrationSandbox(t *testing.T) {
+	p := newTestPlatform(t)
+	deploy := func(version string, sandbox gin.H) {
+		t.Helper()
+		rec := p.call("POST", "/api/deploy/hello", gin.H{
+			"runtime": "js",
+			"code":    workerScript("hello " + version),
+			"version": version,
+			"sandbox": sandbox,
+		})
+		if rec.Code == http.StatusUnprocessableEntity && strings.Contains(rec.Body.String(), "operation not permitted") {
+			t.Skipf("user namespaces are not available: %s", rec.Body.String())
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("deploy %s: status %d: %s", version, rec.Code, rec.Body.String())
+		}
+	}
+	probe := func(version, path string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://"+version+".hello.func.local/__fake/"+path, nil)
+		rec := httptest.NewRecorder()
+		p.proxy.ServeHTTP(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	// 宿主机上的 TCP 服务，用于检查实例能否访问网络
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	dial := "dial?addr=" + ln.Addr().String()
+
+	deploy("v1", gin.H{"mode": "on"})
+	nsPid := p.expectBody("v1.hello.func.local", "hello v1").Header().Get("X-Fake-Pid")
+	if got := p.version("hello", "v1").Sandbox; got != (registry.SandboxConfig{Mode: registry.SandboxOn, Network: registry.NetworkHost}) {
+		t.Fatalf("sandbox = %+v", got)
+	}
+
+	// 实例是初始化进程的子进程，位于新的 pid 命名空间：宿主机上看到的 NSpid 有两级，内层即实例自己的 PID
+	meta, _ := p.reg.GetByVersion("hello", "v1")
+	statuses, _ := filepath.Glob("/proc/[0-9]*/status")
+	found := false
+	for _, path := range statuses {
+		status, err := os.ReadFile(path)
+		if err != nil || !regexp.MustCompile(fmt.Sprintf(`(?m)^PPid:\s+%d$`, meta.Workerd.Pid)).Match(status) {
+			continue
+		}
+		found = true
+		if ns := regexp.MustCompile(`(?m)^NSpid:\s+\d+\s+(\d+)$`).FindSubmatch(status); ns == nil || string(ns[1]) != nsPid {
+			t.Fatalf("instance (pid %s in the sandbox) is not in a nested pid namespace:\n%s", nsPid, status)
+		}
+	}
+	if !found {
+		t.Fatalf("no child process of the sandbox init %d", meta.Workerd.Pid)
+	}
+
+	// 没有 capability、不能获取新权限、启用了 seccomp 过滤
+	_, status := probe("v1", "status")
+	for _, want := range []string{"CapEff: 0000000000000000", "NoNewPrivs: 1", "Seccomp: 2"} {
+		if !strings.Contains(status, want) {
+			t.Fatalf("status %q does not contain %q", status, want)
+		}
+	}
+
+	// 只能看到只读的版本目录，平台的数据库与其他文件不可见，只有 /tmp 可写
+	versionDir := filepath.Join(p.dir, "hello", "v1")
+	checks := []struct {
+		path string
+		code int
+	}{
+		{"stat?path=" + filepath.Join(versionDir, "hello.js"), http.StatusOK},
+		{"stat?path=" + filepath.Join(p.dir, "faas.db"), http.StatusInternalServerError},
+		{"stat?path=" + fakeWorkerdBin, http.StatusOK},
+		{"stat?path=/etc/shadow", http.StatusInternalServerError},
+		{"write?path=" + filepath.Join(versionDir, "hello.js"), http.StatusInternalServerError},
+		{"write?path=/tmp/scratch", http.StatusOK},
+		{dial, http.StatusOK},
+	}
+	for _, c := range checks {
+		if code, body := probe("v1", c.path); code != c.code {
+			t.Errorf("%s = %d %s, want %d", c.path, code, body, c.code)
+		}
+	}
+
+	// 隔离网络：平台经 unix socket 转发请求，实例无法连接任何地址
+	deploy("v2", gin.H{"mode": "on", "network": "isolated"})
+	p.expectBody("v2.hello.func.local", "hello v2")
+	if code, body := probe("v2", dial); code != http.StatusInternalServerError {
+		t.Fatalf("dial from isolated network = %d %s", code, body)
+	}
+
+	// 隔离网络的实例可以经由出站代理访问规则允许的地址
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "egress ok")
+	}))
+	defer target.Close()
+	p.mustCall("POST", "/api/deploy/hello", gin.H{
+		"runtime": "js",
+		"code":    workerScript("hello v3"),
+		"version": "v3",
+		"sandbox": gin.H{"mode": "on", "network": "isolated"},
+		"egress":  gin.H{"mode": "allow", "rules": []gin.H{{"host": "127.0.0.1"}}},
+	})
+	if code, body := probe("v3", "fetch?url="+target.URL); code != http.StatusOK || body != "egress ok" {
+		t.Fatalf("fetch from isolated network through egress proxy = %d %s", code, body)
+	}
+
+	// 挂起后重新唤醒，以及重启后从数据库恢复时沿用隔离设置
+	p.mustCall("POST", "/api/stop/hello", gin.H{"version": "v2"})
+	p.restart()
+	p.expectBody("v2.hello.func.local", "hello v2")
+	if code, _ := probe("v2", dial); code != http.StatusInternalServerError {
+		t.Fatal("network is not isolated after restart")
+	}
+}
+
+func TestIntegrationEgress(t *testing.T) {
+	p := newTestPlatform(t)
+	deploy := func(version string, egress gin.H) *httptest.ResponseRecorder {
+		return p.call("POST", "/api/deploy/hello", gin.H{
+			"runtime": "js",
+			"code":    workerScript("hello " + version),
+			"version": version,
+			"egress":  egress,
+		})
+	}
+	fetch := func(version, url string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://"+version+".hello.func.local/__fake/fetch?url="+url, nil)
+		rec := httptest.NewRecorder()
+		p.proxy.ServeHTTP(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+	newTarget := func(body string) (*httptest.Server, int) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Faas-Caller-Token") != "" {
+				t.Errorf("caller token leaked to %s", r.Host)
+			}
+			fmt.Fprint(w, body)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, srv.Listener.Addr().(*net.TCPAddr).Port
+	}
+	allowed, allowedPort := newTarget("allowed")
+	other, _ := newTarget("other")
+
+	for _, egress := range []gin.H{
+		{"mode": "allow", "rules": []gin.H{{"host": "http://example.com"}}},
+		{"mode": "none", "rules": []gin.H{{"host": "example.com"}}},
+		{"rules": []gin.H{{"host": "example.com"}}},
+		{"mode": "allow", "rules": []gin.H{{"host": "example.com", "ports": []int{70000}}}},
+	} {
+		if rec := deploy("bad", egress); rec.Code != http.StatusBadRequest {
+			t.Fatalf("deploy with egress %v: status %d: %s", egress, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 白名单：只允许 127.0.0.1 上的指定端口
+	if rec := deploy("v1", gin.H{"mode": "allow", "rules": []gin.H{{"host": "127.0.0.1", "ports": []int{allowedPort}}}}); rec.Code != http.StatusOK {
+		t.Fatalf("deploy: status %d: %s", rec.Code, rec.Body.String())
+	}
+	check := func() {
+		t.Helper()
+		if code, body := fetch("v1", allowed.URL); code != http.StatusOK || body != "allowed" {
+			t.Fatalf("fetch allowed target = %d %s", code, body)
+		}
+		if code, body := fetch("v1", other.URL); code != http.StatusForbidden {
+			t.Fatalf("fetch other port = %d %s, want 403", code, body)
+		}
+	}
+	check()
+	if got := p.version("hello", "v1").Egress; got.Mode != registry.EgressAllow || len(got.Rules) != 1 {
+		t.Fatalf("egress = %+v", got)
+	}
+	logs, err := os.ReadFile(p.reg.LogPath("hello", "v1"))
+	if err != nil || !strings.Contains(string(logs), "egress blocked: GET "+other.URL) {
+		t.Fatalf("blocked request not logged (%v):\n%s", err, logs)
+	}
+
+	// 规则随版本持久化，重启后唤醒的实例同样受限
+	p.restart()
+	check()
+
+	// 黑名单按实际连接的地址匹配 CIDR；none 拒绝所有请求；未声明规则时直接访问
+	cases := []struct {
+		version string
+		egress  gin.H
+		code    int
+	}{
+		{"v2", gin.H{"mode": "deny", "rules": []gin.H{{"host": "127.0.0.0/8"}}}, http.StatusForbidden},
+		{"v3", gin.H{"mode": "deny", "rules": []gin.H{{"host": "*.example.com"}}}, http.StatusOK},
+		{"v4", gin.H{"mode": "none"}, http.StatusForbidden},
+		{"v5", nil, http.StatusOK},
+	}
+	for _, c := range cases {
+		if rec := deploy(c.version, c.egress); rec.Code != http.StatusOK {
+			t.Fatalf("deploy %s: status %d: %s", c.version, rec.Code, rec.Body.String())
+		}
+		if code, body := fetch(c.version, allowed.URL); code != c.code {
+			t.Errorf("%s: fetch = %d %s, want %d", c.version, code, body, c.code)
+		}
+	}
+
+	// 未声明规则的版本不经过出站代理
+	meta, _ := p.reg.GetByVersion("hello", "v5")
+	conf, err := os.ReadFile(meta.Workerd.ConfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(conf), "globalOutbound") {
+		t.Fatalf("config without egress policy routes through the proxy:\n%s", conf)
+	}
+
+	// 出站代理拒绝伪造的调用方标识
+	meta, _ = p.reg.GetByVersion("hello", "v3")
+	if conf, err = os.ReadFile(meta.Workerd.ConfPath); err != nil {
+		t.Fatal(err)
+	}
+	address := regexp.MustCompile(`external = \(\s*address = "([^"]+)"`).FindSubmatch(conf)
+	if address == nil {
+		t.Fatalf("no egress service in config:\n%s", conf)
+	}
+	proxyURL, _ := url.Parse("http://" + string(address[1]))
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	req, _ := http.NewRequest("GET", allowed.URL, nil)
+	req.Header.Set("X-Faas-Caller-Token", "hello:v3:"+strings.Repeat("0", 64))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("forged caller token: status %d", resp.StatusCode)
+	}
+}
+
+func TestIntegrationServiceBindings(t *testing.T) {
+	p := newTestPlatform(t)
+	p.deploy("auth", "v1", "stable", "auth v1")
+	p.deploy("auth", "v2", "", "auth v2")
+	deploy := func(version string, services []gin.H, env gin.H) *httptest.ResponseRecorder {
+		return p.call("POST", "/api/deploy/front", gin.H{
+			"runtime":  "js",
+			"code":     workerScript("front " + version),
+			"version":  version,
+			"services": services,
+			"env_vars": env,
+		})
+	}
+	call := func(binding, path string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://v1.front.func.local/__fake/service?binding="+binding+"&path="+url.QueryEscape(path), nil)
+		rec := httptest.NewRecorder()
+		p.proxy.ServeHTTP(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	for _, c := range []struct {
+		services []gin.H
+		env      gin.H
+	}{
+		{[]gin.H{{"name": "bad-name", "function": "auth"}}, nil},
+		{[]gin.H{{"name": "AUTH", "function": "auth"}, {"name": "AUTH", "function": "auth"}}, nil},
+		{[]gin.H{{"name": "AUTH", "function": "auth"}}, gin.H{"AUTH": "x"}},
+		{[]gin.H{{"name": "AUTH", "function": "auth.func.local"}}, nil},
+		{[]gin.H{{"name": "AUTH"}}, nil},
+	} {
+		if rec := deploy("bad", c.services, c.env); rec.Code != http.StatusBadRequest {
+			t.Fatalf("deploy with services %v: status %d: %s", c.services, rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec := deploy("v1", []gin.H{
+		{"name": "AUTH", "function": "auth"},
+		{"name": "STABLE", "function": "auth", "alias": "stable"},
+		{"name": "PINNED", "function": "auth", "alias": "v2"},
+		{"name": "MISSING", "function": "missing"},
+	}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("deploy: status %d: %s", rec.Code, rec.Body.String())
+	}
+	check := func() {
+		t.Helper()
+		for binding, want := range map[string]string{"AUTH": "auth v2", "STABLE": "auth v1", "PINNED": "auth v2"} {
+			if code, body := call(binding, "/"); code != http.StatusOK || body != want {
+				t.Fatalf("%s = %d %q, want %q", binding, code, body, want)
+			}
+		}
+		// 目标收到平台校验过的调用方
+		if code, body := call("AUTH", "/__fake/header?name=X-Faas-Caller"); code != http.StatusOK || body != "front:v1" {
+			t.Fatalf("caller seen by target = %d %q", code, body)
+		}
+	}
+	check()
+	if code, _ := call("MISSING", "/"); code != http.StatusNotFound {
+		t.Fatalf("binding to missing function: status %d", code)
+	}
+	if code, _ := call("UNKNOWN", "/"); code == http.StatusOK {
+		t.Fatal("undeclared binding succeeded")
+	}
+	if got := p.version("front", "v1").Services; len(got) != 4 || got[1] != (registry.ServiceBinding{Name: "STABLE", Function: "auth", Alias: "stable"}) {
+		t.Fatalf("services = %+v", got)
+	}
+
+	// 挂起的目标版本被唤醒
+	p.mustCall("POST", "/api/stop/auth", gin.H{"version": "v2"})
+	check()
+
+	// 外部请求无法伪造调用方
+	req := httptest.NewRequest("GET", "http://auth.func.local/__fake/header?name=X-Faas-Caller", nil)
+	req.Header.Set("X-Faas-Caller", "front:v1")
+	rec := httptest.NewRecorder()
+	p.proxy.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "" {
+		t.Fatalf("spoofed caller reached the function: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// 绑定随版本持久化，重启后唤醒的实例同样可用
+	p.restart()
+	check()
+}
+
+func TestIntegrationDurableObjects(t *testing.T) {
+	p := newTestPlatform(t)
+	deploy := func(version string, durable gin.H) *httptest.ResponseRecorder {
+		return p.call("POST", "/api/deploy/counter", gin.H{
+			"runtime":         "js",
+			"code":            fmt.Sprintf(`export class Counter {} export class Tally {} export default { fetch() { return new Response("counter %s"); } };`, version),
+			"version":         version,
+			"env_vars":        gin.H{"NAME": "counter"},
+			"durable_objects": durable,
+		})
+	}
+	mustDeploy := func(version string, durable gin.H) {
+		t.Helper()
+		if rec := deploy(version, durable); rec.Code != http.StatusOK {
+			t.Fatalf("deploy %s: status %d: %s", version, rec.Code, rec.Body.String())
+		}
+	}
+	increment := func(version, binding, name string, want int) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://"+version+".counter.func.local/__fake/durable?binding="+binding+"&name="+name, nil)
+		rec := httptest.NewRecorder()
+		p.proxy.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.String() != fmt.Sprint(want) {
+			t.Fatalf("%s %s/%s = %d %s, want %d", version, binding, name, rec.Code, rec.Body.String(), want)
+		}
+	}
+	keys := func() []string {
+		t.Helper()
+		entries, _ := os.ReadDir(filepath.Join(p.dir, ".durable", "counter"))
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	counter := gin.H{"classes": []gin.H{{"binding": "COUNTER", "class_name": "Counter"}}}
+
+	for _, durable := range []gin.H{
+		{"classes": []gin.H{{"binding": "NAME", "class_name": "Counter"}}},
+		{"classes": []gin.H{{"binding": "COUNTER", "class_name": "Counter"}, {"binding": "COUNTER", "class_name": "Tally"}}},
+		{"classes": []gin.H{{"binding": "COUNTER", "class_name": "Counter", "renamed_from": "Missing"}}},
+		{"classes": []gin.H{{"binding": "COUNTER", "class_name": "my-class"}}},
+	} {
+		if rec := deploy("bad", durable); rec.Code != http.StatusBadRequest {
+			t.Fatalf("deploy with durable objects %v: status %d: %s", durable, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 单文件部署以 ES 模块加载，命名空间的数据保存在函数的存储目录
+	mustDeploy("v1", counter)
+	increment("v1", "COUNTER", "a", 1)
+	increment("v1", "COUNTER", "a", 2)
+	meta, _ := p.reg.GetByVersion("counter", "v1")
+	if conf, _ := os.ReadFile(meta.Workerd.ConfPath); !strings.Contains(string(conf), `esModule = embed "counter.js"`) {
+		t.Fatalf("durable object worker is not loaded as an ES module:\n%s", conf)
+	}
+	key := p.version("counter", "v1").DurableObjects[0].Key
+	if got := keys(); len(got) != 1 || got[0] != key {
+		t.Fatalf("durable object dirs = %v, want [%s]", got, key)
+	}
+
+	// 新版本、挂起唤醒与重启后数据保留
+	mustDeploy("v2", counter)
+	increment("v2", "COUNTER", "a", 3)
+	p.mustCall("POST", "/api/stop/counter", gin.H{"version": "v2"})
+	increment("v2", "COUNTER", "a", 4)
+	// 旧布局（<函数>/.durable）的数据在重启时迁移到 .durable/<函数>
+	if err := os.Rename(filepath.Join(p.dir, ".durable", "counter"), filepath.Join(p.dir, "counter", ".durable")); err != nil {
+		t.Fatal(err)
+	}
+	p.restart()
+	increment("v2", "COUNTER", "a", 5)
+
+	// 修改环境变量生成的新版本沿用 latest 的类
+	if rec := p.call("PATCH", "/api/v2/functions/counter/env", gin.H{"set": gin.H{"NAME": "renamed"}, "version": "v3"}); rec.Code != http.StatusCreated {
+		t.Fatalf("patch env: status %d: %s", rec.Code, rec.Body.String())
+	}
+	increment("v3", "COUNTER", "a", 6)
+
+	// 改名：renamed_from 沿用原来的数据
+	mustDeploy("v4", gin.H{"classes": []gin.H{{"binding": "COUNTER", "class_name": "Tally", "renamed_from": "Counter"}}})
+	increment("v4", "COUNTER", "a", 7)
+	if got := p.version("counter", "v4").DurableObjects[0]; got.ClassName != "Tally" || got.Key != key {
+		t.Fatalf("renamed class = %+v, want key %s", got, key)
+	}
+
+	// 删除类必须声明迁移；仍被旧版本引用时数据保留，旧版本删除后回收
+	if rec := deploy("v5", gin.H{"classes": []gin.H{{"binding": "OTHER", "class_name": "Counter"}}}); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Tally") {
+		t.Fatalf("deploy removing a class without migration: status %d: %s", rec.Code, rec.Body.String())
+	}
+	mustDeploy("v5", gin.H{"classes": []gin.H{{"binding": "OTHER", "class_name": "Counter"}}, "deleted": []string{"Tally"}})
+	increment("v5", "OTHER", "a", 1)
+	if got := keys(); len(got) != 2 || !slices.Contains(got, key) {
+		t.Fatalf("durable object dirs = %v, want the deleted class kept for older versions", got)
+	}
+	for _, version := range []string{"v1", "v2", "v3", "v4"} {
+		if rec := p.call("DELETE", "/api/v2/functions/counter/versions/"+version, nil); rec.Code != http.StatusNoContent {
+			t.Fatalf("delete %s: status %d: %s", version, rec.Code, rec.Body.String())
+		}
+	}
+	if got := keys(); len(got) != 1 || got[0] == key {
+		t.Fatalf("durable object dirs = %v, want only the new class", got)
+	}
+
+	// 删除函数时删除全部数据
+	if rec := p.call("DELETE", "/api/v2/functions/counter", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete function: status %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, dir := range []string{filepath.Join(p.dir, "counter"), filepath.Join(p.dir, ".durable", "counter")} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Fatalf("function storage %s remains after delete: %v", dir, err)
+		}
+	}
+}
+
+func TestIntegrationDatabase(t *testing.T) {
+	p := newTestPlatform(t)
+	deploy := func(version string, database gin.H, env gin.H) *httptest.ResponseRecorder {
+		return p.call("POST", "/api/deploy/notes", gin.H{
+			"runtime":  "js",
+			"code":     workerScript("notes " + version),
+			"version":  version,
+			"database": database,
+			"env_vars": env,
+		})
+	}
+	// bind 像 worker 中的 env.DB.fetch() 一样调用数据库绑定
+	bind := func(path string, body any) (int, string) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "http://latest.notes.func.local/__fake/service?binding=DB&path="+path, bytes.NewReader(data))
+		rec := httptest.NewRecorder()
+		p.proxy.ServeHTTP(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+	count := func(want int) {
+		t.Helper()
+		rec := p.mustCall("POST", "/api/v2/functions/notes/database/query", gin.H{"sql": "SELECT COUNT(*) FROM notes"})
+		if got := strings.TrimSpace(rec.Body.String()); !strings.Contains(got, fmt.Sprintf(`"rows":[[%d]]`, want)) {
+			t.Fatalf("count = %s, want %d", got, want)
+		}
+	}
+
+	for _, c := range []struct{ database, env gin.H }{
+		{gin.H{"binding": "bad-name"}, nil},
+		{gin.H{"binding": "DB"}, gin.H{"DB": "x"}},
+	} {
+		if rec := deploy("bad", c.database, c.env); rec.Code != http.StatusBadRequest {
+			t.Fatalf("deploy with database %v: status %d: %s", c.database, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := p.call("POST", "/api/v2/functions/notes/database/query", gin.H{"sql": "SELECT 1"}); rec.Code != http.StatusNotFound {
+		t.Fatalf("query missing function: status %d", rec.Code)
+	}
+	p.deploy("plain", "v1", "", "plain")
+	if rec := p.call("POST", "/api/v2/functions/plain/database/query", gin.H{"sql": "SELECT 1"}); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "no database") {
+		t.Fatalf("query function without database: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := deploy("v1", gin.H{"binding": "DB"}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("deploy: status %d: %s", rec.Code, rec.Body.String())
+	}
+	meta, _ := p.reg.GetByVersion("notes", "v1")
+	if conf, _ := os.ReadFile(meta.Workerd.ConfPath); !strings.Contains(string(conf), `( name = "DB", service = "faas-database" )`) {
+		t.Fatalf("database binding missing from config:\n%s", conf)
+	}
+	if got := p.version("notes", "v1").Database.Binding; got != "DB" {
+		t.Fatalf("version database binding = %q", got)
+	}
+
+	// 迁移按名称只执行一次
+	migrations := []gin.H{{"name": "001_init", "sql": "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL);"}}
+	if rec := p.mustCall("POST", "/api/v2/functions/notes/database/migrations", gin.H{"migrations": migrations}); !strings.Contains(rec.Body.String(), `"applied":["001_init"]`) {
+		t.Fatalf("migrate: %s", rec.Body.String())
+	}
+	migrations = append(migrations, gin.H{"name": "002_index", "sql": "CREATE INDEX notes_body ON notes (body);"})
+	if rec := p.mustCall("POST", "/api/v2/functions/notes/database/migrations", gin.H{"migrations": migrations}); !strings.Contains(rec.Body.String(), `"applied":["002_index"]`) {
+		t.Fatalf("migrate again: %s", rec.Body.String())
+	}
+	if rec := p.call("POST", "/api/v2/functions/notes/database/migrations", gin.H{"migrations": []gin.H{{"name": "003_bad", "sql": "CREATE TABLE"}}}); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "003_bad") {
+		t.Fatalf("failing migration: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := p.mustCall("GET", "/api/v2/functions/notes/database/migrations", nil); !strings.Contains(rec.Body.String(), `"name":"001_init"`) || !strings.Contains(rec.Body.String(), `"name":"002_index"`) || strings.Contains(rec.Body.String(), "003_bad") {
+		t.Fatalf("migrations: %s", rec.Body.String())
+	}
+
+	// worker 通过绑定执行语句
+	if code, body := bind("/exec", gin.H{"sql": "INSERT INTO notes (body) VALUES (?)", "params": []any{"hello"}}); code != http.StatusOK || !strings.Contains(body, `"rows_affected":1,"last_insert_id":1`) {
+		t.Fatalf("exec = %d %s", code, body)
+	}
+	if code, body := bind("/query", gin.H{"sql": "SELECT id, body FROM notes WHERE id = ?", "params": []any{1}}); code != http.StatusOK || !strings.Contains(body, `"columns":["id","body"],"rows":[[1,"hello"]]`) {
+		t.Fatalf("query = %d %s", code, body)
+	}
+	if code, body := bind("/query", gin.H{"sql": "SELEC 1"}); code != http.StatusBadRequest || !strings.Contains(body, "error") {
+		t.Fatalf("invalid query = %d %s", code, body)
+	}
+	// batch 在一个事务中执行，失败时全部回滚
+	if code, body := bind("/batch", gin.H{"statements": []gin.H{
+		{"sql": "INSERT INTO notes (body) VALUES ('a')"},
+		{"sql": "INSERT INTO notes (body) VALUES (NULL)"},
+	}}); code != http.StatusBadRequest || !strings.Contains(body, "statement 2") {
+		t.Fatalf("failing batch = %d %s", code, body)
+	}
+	count(1)
+	if code, body := bind("/batch", gin.H{"statements": []gin.H{
+		{"sql": "INSERT INTO notes (body) VALUES ('a')"},
+		{"sql": "UPDATE notes SET body = body || '!'"},
+	}}); code != http.StatusOK || !strings.Contains(body, `"rows_affected":2`) {
+		t.Fatalf("batch = %d %s", code, body)
+	}
+	count(2)
+
+	// 修改环境变量生成的新版本沿用数据库，重启后数据保留；旧布局（<函数>/.database）在重启时迁移
+	if rec := p.call("PATCH", "/api/v2/functions/notes/env", gin.H{"set": gin.H{"MODE": "x"}, "version": "v2"}); rec.Code != http.StatusCreated {
+		t.Fatalf("patch env: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := p.reg.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(p.dir, ".database", "notes"), filepath.Join(p.dir, "notes", ".database")); err != nil {
+		t.Fatal(err)
+	}
+	p.open()
+	if code, body := bind("/query", gin.H{"sql": "SELECT body FROM notes ORDER BY id"}); code != http.StatusOK || !strings.Contains(body, `"rows":[["hello!"],["a!"]]`) {
+		t.Fatalf("query after restart = %d %s", code, body)
+	}
+
+	// 导出快照后导入，数据回到导出时的状态
+	export := p.mustCall("GET", "/api/v2/functions/notes/database/export", nil)
+	if !strings.HasPrefix(export.Body.String(), "SQLite format 3\x00") {
+		t.Fatalf("export is not a SQLite database: %q", export.Body.String()[:min(32, export.Body.Len())])
+	}
+	snapshot := export.Body.Bytes()
+	bind("/exec", gin.H{"sql": "DELETE FROM notes"})
+	count(0)
+	importDatabase := func(data []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/v2/functions/notes/database/import", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/vnd.sqlite3")
+		rec := httptest.NewRecorder()
+		p.api.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := importDatabase([]byte("not a database")); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("import invalid file: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := importDatabase(snapshot); rec.Code != http.StatusNoContent {
+		t.Fatalf("import: status %d: %s", rec.Code, rec.Body.String())
+	}
+	count(2)
+
+	// 删除函数时删除数据库
+	if rec := p.call("DELETE", "/api/v2/functions/notes", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete function: status %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, dir := range []string{filepath.Join(p.dir, "notes"), filepath.Join(p.dir, ".database", "notes")} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Fatalf("function storage %s remains after delete: %v", dir, err)
+		}
+	}
+}
+
+func TestIntegrationV2ApplyErrors(t *testing.T) {
+	p := newTestPlatform(t)
+
+	// TypeScript 编译失败返回 422 与诊断信息，而不是冲突
+	rec := p.call("POST", "/api/v2/apply", gin.H{"name": "broken", "runtime": "ts", "code": "const greeting: string = \"unterminated;"})
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || resp.Error.Code != CodeCompileFailed || len(resp.Error.Diagnostics) == 0 {
+		t.Fatalf("compile error: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := p.reg.GetByName("broken"); ok {
+		t.Fatal("function deployed despite compile error")
+	}
+
+	// wasm 运行时缺少或携带无效的模块时校验失败
+	for _, wasm := range [][]byte{nil, []byte("not wasm")} {
+		rec := p.call("POST", "/api/v2/apply", gin.H{"name": "mod", "runtime": "wasm", "wasm": wasm})
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusUnprocessableEntity || resp.Error.Code != CodeValidationFailed {
+			t.Fatalf("wasm %q: status %d: %s", wasm, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 非法配置返回 422（dry run 同样检查），只有版本号被不同内容占用时返回 409
+	for _, manifest := range []gin.H{
+		{"name": "bad", "runtime": "js", "code": workerScript("x"), "egress": gin.H{"mode": "bogus"}},
+		{"name": "bad", "runtime": "exec", "code": "#!/bin/sh"},
+		{"name": "bad", "runtime": "js", "code": workerScript("x"), "aliases": gin.H{"stable": "v9"}},
+	} {
+		for _, path := range []string{"/api/v2/apply", "/api/v2/apply?dry_run=true"} {
+			if rec := p.call("POST", path, manifest); rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("%s %v: status %d: %s", path, manifest, rec.Code, rec.Body.String())
+			}
+		}
+		if rec := p.call("POST", "/api/apply", manifest); rec.Code != http.StatusBadRequest {
+			t.Fatalf("v1 apply %v: status %d: %s", manifest, rec.Code, rec.Body.String())
+		}
+	}
+	p.mustCall("POST", "/api/v2/apply", gin.H{"name": "fixed", "runtime": "js", "code": workerScript("a"), "version": "v1"})
+	if rec := p.call("POST", "/api/v2/apply", gin.H{"name": "fixed", "runtime": "js", "code": workerScript("b"), "version": "v1"}); rec.Code != http.StatusConflict {
+		t.Fatalf("changed version: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// 无法解析的 cron 表达式整体拒绝，不部署任何内容
+	manifest := gin.H{"name": "cron", "runtime": "js", "code": workerScript("tick"),
+		"schedules": []gin.H{{"cron": "61 * * * *", "path": "/tick"}}}
+	if rec := p.call("POST", "/api/v2/apply", manifest); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid cron: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := p.call("POST", "/api/apply", manifest); rec.Code != http.StatusBadRequest {
+		t.Fatalf("v1 invalid cron: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := p.reg.GetByName("cron"); ok {
+		t.Fatal("function deployed despite invalid schedule")
+	}
+}
+
+func TestIntegrationApplySchedules(t *testing.T) {
+	p := newTestPlatform(t)
+	manifest := gin.H{"name": "cron", "runtime": "js", "code": workerScript("tick"),
+		"schedules": []gin.H{{"cron": "*/5  * * * *", "path": "/tick"}, {"cron": "@daily"}}}
+
+	var res struct {
+		Plan []PlanStep `json:"plan"`
+	}
+	rec := p.mustCall("POST", "/api/v2/apply", manifest)
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	var targets []string
+	for _, step := range res.Plan {
+		if step.Action == "set_schedule" {
+			targets = append(targets, step.Target)
+		}
+	}
+	if strings.Join(targets, ",") != "*/5 * * * * /tick,@daily /" {
+		t.Fatalf("plan = %+v", res.Plan)
+	}
+	if rec := p.mustCall("POST", "/api/v2/apply?dry_run=true", manifest); strings.Contains(rec.Body.String(), "schedule") {
+		t.Fatalf("unchanged schedules planned again: %s", rec.Body.String())
+	}
+
+	// 到期的任务经路由调用 latest 版本，记录运行结果；重启后仍然保留
+	p.reg.RunSchedules(time.Date(2026, 1, 1, 12, 5, 0, 0, time.Local))
+	p.restart()
+	schedules := p.reg.ListSchedules("cron")
+	if len(schedules) != 2 {
+		t.Fatalf("schedules = %+v", schedules)
+	}
+	if s := schedules[0]; s.Key() != "*/5 * * * * /tick" || s.LastRunAt == nil || s.LastStatus != http.StatusOK || s.LastError != "" {
+		t.Fatalf("due schedule = %+v", s)
+	}
+	if s := schedules[1]; s.LastRunAt != nil {
+		t.Fatalf("schedule ran before it was due: %+v", s)
+	}
+
+	// 空列表删除全部任务；删除函数时一并清理
+	manifest["schedules"] = []gin.H{}
+	if rec := p.mustCall("POST", "/api/v2/apply", manifest); strings.Count(rec.Body.String(), "delete_schedule") != 2 {
+		t.Fatalf("apply without schedules: %s", rec.Body.String())
+	}
+	if schedules := p.reg.ListSchedules("cron"); len(schedules) != 0 {
+		t.Fatalf("schedules after removal = %+v", schedules)
+	}
+	manifest["schedules"] = []gin.H{{"cron": "* * * * *"}}
+	p.mustCall("POST", "/api/v2/apply", manifest)
+	if rec := p.call("DELETE", "/api/v2/functions/cron", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if schedules := p.reg.ListSchedules("cron"); len(schedules) != 0 {
+		t.Fatalf("schedules after delete = %+v", schedules)
+	}
+}
+
+func TestIntegrationRejectsUnsafeNames(t *testing.T) {
+	p := newTestPlatform(t)
+	victim := filepath.Join(filepath.Dir(p.dir), "victim")
+
+	// 版本号与函数名会作为目录名，路径穿越与 . 开头的名称一律拒绝
+	for _, version := range []string{"../../victim", "a/b", ".durable", `v1"`} {
+		rec := p.call("POST", "/api/v2/functions/hello/versions", gin.H{"runtime": "js", "code": workerScript("x"), "version": version})
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("v2 deploy version %q: status %d: %s", version, rec.Code, rec.Body.String())
+		}
+		if rec := p.call("POST", "/api/deploy/hello", gin.H{"runtime": "js", "code": workerScript("x"), "version": version}); rec.Code != http.StatusBadRequest {
+			t.Fatalf("v1 deploy version %q: status %d: %s", version, rec.Code, rec.Body.String())
+		}
+	}
+	for _, path := range []string{"/api/v2/functions/.hidden/versions/v1", "/api/v2/functions/hello/versions/..victim"} {
+		if rec := p.call("PUT", path, gin.H{"runtime": "js", "code": workerScript("x")}); rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("PUT %s: status %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := p.call("POST", "/api/v2/apply", gin.H{"name": "hello", "runtime": "js", "code": workerScript("x"), "version": "../victim"}); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("apply: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if _, err := os.Stat(victim); !os.IsNotExist(err) {
+		t.Fatalf("files written outside storage dir: %v", err)
+	}
+	if _, ok := p.reg.GetByName("hello"); ok {
+		t.Fatal("function registered with an invalid version")
+	}
+}
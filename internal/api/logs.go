@@ -0,0 +1,153 @@
+package api
+
+import (
+	"faas/internal/logs"
+	"faas/internal/registry"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLogLimit = 100   // 默认返回行数
+	maxLogLimit     = 10000 // 单次最多返回行数
+)
+
+// LogsHandler 查询函数日志接口（GET /api/logs/:funcName?version=&since=&limit=）
+func LogsHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		meta, ok := lookupVersion(c, reg, funcName)
+		if !ok {
+			return
+		}
+
+		since, err := parseSince(c.Query("since"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		limit, err := parseLimit(c.Query("limit"), defaultLogLimit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		lines, err := logs.Read(reg.LogPath(funcName, meta.Version), since, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if lines == nil {
+			lines = []logs.Line{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"funcName": funcName,
+			"version":  meta.Version,
+			"lines":    lines,
+		})
+	}
+}
+
+// TailLogsHandler 实时跟踪函数日志接口（GET /api/logs/:funcName/tail?version=&limit=，SSE）
+func TailLogsHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		meta, ok := lookupVersion(c, reg, funcName)
+		if !ok {
+			return
+		}
+		limit, err := parseLimit(c.Query("limit"), 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 先订阅再读取历史，避免两者之间的日志丢失（可能出现少量重复）
+		writer := reg.LogWriter(funcName, meta.Version)
+		ch, cancel := writer.Subscribe()
+		defer cancel()
+
+		var backlog []logs.Line
+		if limit > 0 {
+			if backlog, err = logs.Read(writer.Path(), time.Time{}, limit); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		for _, line := range backlog {
+			c.SSEvent("log", line)
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case line, ok := <-ch:
+				if !ok {
+					return false // 版本被删除
+				}
+				c.SSEvent("log", line)
+				return true
+			case <-heartbeat.C:
+				c.SSEvent("ping", time.Now().UTC().Format(time.RFC3339))
+				return true
+			}
+		})
+	}
+}
+
+// lookupVersion 解析 version 参数（默认 latest），不存在时返回 404
+func lookupVersion(c *gin.Context, reg *registry.Registry, funcName string) (*registry.FunctionMetadata, bool) {
+	var meta *registry.FunctionMetadata
+	var exists bool
+	if version := c.Query("version"); version != "" {
+		meta, exists = reg.GetByVersion(funcName, version)
+	} else {
+		meta, exists = reg.GetByName(funcName)
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "function version not found"})
+		return nil, false
+	}
+	return meta, true
+}
+
+// parseSince 支持 RFC3339 时间或相对时长（如 10m 表示最近 10 分钟）
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since: %s (expect RFC3339 time or duration like 10m)", value)
+}
+
+func parseLimit(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid limit: %s", value)
+	}
+	if limit > maxLogLimit {
+		limit = maxLogLimit
+	}
+	return limit, nil
+}
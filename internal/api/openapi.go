@@ -0,0 +1,331 @@
+package api
+
+import (
+	"faas/internal/logs"
+	"faas/internal/registry"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// obj 描述 gin.H 形式的响应体，字段值为对应类型的零值
+type obj map[string]any
+
+// operation 一个接口的文档描述
+type operation struct {
+	method   string
+	path     string // gin 路由格式（/functions/:funcName）
+	summary  string
+	query    []string
+	request  any    // 请求体类型的零值，nil 表示无请求体
+	binary   string // 请求体为二进制文件时的类型（request 为 ""）
+	upload   bool   // 是否同时接受 multipart 压缩包上传
+	status   int    // 成功状态码
+	response any    // 响应体：类型零值或 obj，nil 表示无响应体
+	content  string // 响应类型，默认 application/json
+}
+
+// success v1 接口的通用成功响应
+var success = obj{"status": "", "funcName": "", "message": ""}
+
+// operations 全部接口的文档，路由变化时需同步更新
+var operations = []operation{
+	{method: "GET", path: "/metrics", summary: "Prometheus 指标", status: 200, response: "", content: "text/plain"},
+	{method: "GET", path: "/api/openapi.json", summary: "OpenAPI 文档", status: 200, response: obj{}},
+
+	// v1
+	{method: "GET", path: "/api/list/:funcName", summary: "列出函数所有版本号", status: 200,
+		response: obj{"funcName": "", "versions": []string{}}},
+	{method: "GET", path: "/api/functions", summary: "分页列出函数", query: []string{"page", "page_size", "name", "status", "runtime"}, status: 200,
+		response: obj{"functions": []registry.FunctionSummary{}, "page": 0, "pageSize": 0, "total": 0}},
+	{method: "GET", path: "/api/functions/:funcName", summary: "查询函数详情", status: 200, response: registry.FunctionDetail{}},
+	{method: "GET", path: "/api/functions/:funcName/versions/:version/code", summary: "下载版本源码（source=true 返回 TypeScript 源码，wasm=true 返回 WebAssembly 二进制）", query: []string{"source", "wasm"}, status: 200,
+		response: "", content: "application/javascript"},
+	{method: "POST", path: "/api/deploy/:funcName", summary: "部署函数", request: DeployRequest{}, upload: true, status: 200,
+		response: obj{"status": "", "funcName": "", "subdomain": "", "accessUrl": "", "version": "", "alias": "", "deduplicated": false}},
+	{method: "POST", path: "/api/rollback/:funcName", summary: "回滚别名到指定版本", request: RollbackRequest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "alias": "", "targetVersion": "", "accessUrl": ""}},
+	{method: "POST", path: "/api/stop/:funcName", summary: "停止版本", request: StopRequest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "version": "", "message": ""}},
+	{method: "POST", path: "/api/delete/:funcName", summary: "删除函数及所有版本", status: 200, response: success},
+	{method: "POST", path: "/api/deleteVersion/:funcName", summary: "删除版本", request: DeleteVersionRequest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "version": "", "message": ""}},
+	{method: "POST", path: "/api/apply", summary: "声明式部署", query: []string{"dry_run"}, request: Manifest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "version": "", "dryRun": false, "changed": false, "plan": []PlanStep{}}},
+	{method: "GET", path: "/api/aliases/:funcName", summary: "列出别名", status: 200,
+		response: obj{"funcName": "", "aliases": map[string]string{}}},
+	{method: "POST", path: "/api/alias/:funcName", summary: "设置别名", request: SetAliasRequest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "alias": "", "version": "", "accessUrl": ""}},
+	{method: "POST", path: "/api/deleteAlias/:funcName", summary: "删除别名", request: DeleteAliasRequest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "alias": "", "message": ""}},
+	{method: "GET", path: "/api/env/:funcName", summary: "查询环境变量名", query: []string{"version"}, status: 200,
+		response: obj{"funcName": "", "version": "", "keys": []string{}}},
+	{method: "POST", path: "/api/env/:funcName", summary: "修改环境变量并部署新版本", request: UpdateEnvRequest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "subdomain": "", "accessUrl": "", "version": "", "alias": "", "keys": []string{}, "deduplicated": false}},
+	{method: "GET", path: "/api/logs/:funcName", summary: "查询函数日志", query: []string{"version", "since", "limit"}, status: 200,
+		response: obj{"funcName": "", "version": "", "lines": []logs.Line{}}},
+	{method: "GET", path: "/api/logs/:funcName/tail", summary: "实时跟踪函数日志（SSE）", query: []string{"version", "limit"}, status: 200,
+		response: "", content: "text/event-stream"},
+	{method: "GET", path: "/api/ratelimit/:funcName", summary: "列出限流规则", status: 200,
+		response: obj{"funcName": "", "rateLimits": []registry.RateLimitRule{}}},
+	{method: "POST", path: "/api/ratelimit/:funcName", summary: "设置限流规则", request: RateLimitRequest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "rateLimit": registry.RateLimitRule{}}},
+	{method: "POST", path: "/api/deleteRateLimit/:funcName", summary: "删除限流规则", request: DeleteRateLimitRequest{}, status: 200,
+		response: obj{"status": "", "funcName": "", "alias": "", "message": ""}},
+	{method: "POST", path: "/api/gc", summary: "回收未被引用的代码 blob", status: 200, response: registry.GCResult{}},
+
+	// v2
+	{method: "GET", path: "/api/v2/functions", summary: "分页列出函数", query: []string{"page", "page_size", "name", "status", "runtime"}, status: 200,
+		response: obj{"functions": []registry.FunctionSummary{}, "page": 0, "pageSize": 0, "total": 0}},
+	{method: "GET", path: "/api/v2/functions/:funcName", summary: "查询函数详情", status: 200, response: registry.FunctionDetail{}},
+	{method: "DELETE", path: "/api/v2/functions/:funcName", summary: "删除函数及所有版本", status: 204},
+	{method: "GET", path: "/api/v2/functions/:funcName/versions", summary: "列出版本", status: 200,
+		response: obj{"versions": []registry.VersionInfo{}}},
+	{method: "POST", path: "/api/v2/functions/:funcName/versions", summary: "部署新版本", request: DeployRequest{}, upload: true, status: 201, response: registry.VersionInfo{}},
+	{method: "GET", path: "/api/v2/functions/:funcName/versions/:version", summary: "查询版本", status: 200, response: registry.VersionInfo{}},
+	{method: "PUT", path: "/api/v2/functions/:funcName/versions/:version", summary: "以指定版本号部署", request: DeployRequest{}, upload: true, status: 201, response: registry.VersionInfo{}},
+	{method: "PATCH", path: "/api/v2/functions/:funcName/versions/:version", summary: "启动/停止版本", request: PatchVersionRequest{}, status: 200, response: registry.VersionInfo{}},
+	{method: "DELETE", path: "/api/v2/functions/:funcName/versions/:version", summary: "删除版本", status: 204},
+	{method: "GET", path: "/api/v2/functions/:funcName/versions/:version/code", summary: "下载版本源码（source=true 返回 TypeScript 源码，wasm=true 返回 WebAssembly 二进制）", query: []string{"source", "wasm"}, status: 200,
+		response: "", content: "application/javascript"},
+	{method: "GET", path: "/api/v2/functions/:funcName/aliases", summary: "列出别名", status: 200,
+		response: obj{"aliases": map[string]string{}}},
+	{method: "PUT", path: "/api/v2/functions/:funcName/aliases/:alias", summary: "设置别名（latest 按回滚处理）", request: PutAliasRequest{}, status: 200,
+		response: obj{"alias": "", "version": "", "accessUrl": ""}},
+	{method: "DELETE", path: "/api/v2/functions/:funcName/aliases/:alias", summary: "删除别名", status: 204},
+	{method: "GET", path: "/api/v2/functions/:funcName/ratelimits", summary: "列出限流规则", status: 200,
+		response: obj{"rateLimits": []registry.RateLimitRule{}}},
+	{method: "PUT", path: "/api/v2/functions/:funcName/ratelimits", summary: "设置限流规则", request: RateLimitRequest{}, status: 200, response: registry.RateLimitRule{}},
+	{method: "DELETE", path: "/api/v2/functions/:funcName/ratelimits", summary: "删除限流规则", query: []string{"alias"}, status: 204},
+	{method: "GET", path: "/api/v2/functions/:funcName/env", summary: "查询环境变量名", query: []string{"version"}, status: 200,
+		response: obj{"version": "", "keys": []string{}}},
+	{method: "PATCH", path: "/api/v2/functions/:funcName/env", summary: "修改环境变量并部署新版本", request: UpdateEnvRequest{}, status: 201, response: registry.VersionInfo{}},
+	{method: "POST", path: "/api/v2/apply", summary: "声明式部署", query: []string{"dry_run"}, request: Manifest{}, status: 200,
+		response: obj{"funcName": "", "version": "", "dryRun": false, "changed": false, "plan": []PlanStep{}}},
+	{method: "GET", path: "/api/v2/functions/:funcName/database/migrations", summary: "列出已执行的数据库迁移", status: 200,
+		response: obj{"migrations": []registry.AppliedMigration{}}},
+	{method: "POST", path: "/api/v2/functions/:funcName/database/migrations", summary: "执行尚未执行的数据库迁移", request: MigrateDatabaseRequest{}, status: 200,
+		response: obj{"applied": []string{}}},
+	{method: "POST", path: "/api/v2/functions/:funcName/database/query", summary: "在函数数据库上执行 SQL 语句", request: registry.Statement{}, status: 200, response: registry.StatementResult{}},
+	{method: "GET", path: "/api/v2/functions/:funcName/database/export", summary: "导出函数数据库（SQLite 文件）", status: 200,
+		response: "", content: "application/vnd.sqlite3"},
+	{method: "PUT", path: "/api/v2/functions/:funcName/database/import", summary: "用 SQLite 文件替换函数数据库", request: "", binary: "application/vnd.sqlite3", status: 204},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPISpec map[string]any
+)
+
+// OpenAPIHandler 返回 OpenAPI 3 文档（GET /api/openapi.json）
+func OpenAPIHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, OpenAPISpec())
+	}
+}
+
+// OpenAPISpec 根据 operations 生成 OpenAPI 3 文档，请求/响应结构由 Go 类型反射得到
+func OpenAPISpec() map[string]any {
+	openAPIOnce.Do(func() {
+		b := &specBuilder{schemas: map[string]any{}}
+		paths := map[string]any{}
+		for _, op := range operations {
+			path := openAPIPath(op.path)
+			item, ok := paths[path].(map[string]any)
+			if !ok {
+				item = map[string]any{}
+				paths[path] = item
+			}
+			item[strings.ToLower(op.method)] = b.operation(op)
+		}
+		openAPISpec = map[string]any{
+			"openapi": "3.0.3",
+			"info": map[string]any{
+				"title":   "FaaS API",
+				"version": "1.0.0",
+			},
+			"paths":      paths,
+			"components": map[string]any{"schemas": b.schemas},
+		}
+	})
+	return openAPISpec
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// openAPIPath 将 gin 路由参数转换为 OpenAPI 格式（:funcName -> {funcName}）
+func openAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+// specBuilder 生成接口描述并收集 components.schemas
+type specBuilder struct {
+	schemas map[string]any
+}
+
+func (b *specBuilder) operation(op operation) map[string]any {
+	var params []any
+	for _, m := range pathParam.FindAllStringSubmatch(op.path, -1) {
+		params = append(params, map[string]any{
+			"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, name := range op.query {
+		params = append(params, map[string]any{
+			"name": name, "in": "query", "schema": map[string]any{"type": "string"},
+		})
+	}
+
+	responses := map[string]any{}
+	ok := map[string]any{"description": http.StatusText(op.status)}
+	if op.response != nil {
+		content := op.content
+		if content == "" {
+			content = "application/json"
+		}
+		ok["content"] = map[string]any{content: map[string]any{"schema": b.value(op.response)}}
+	}
+	responses[strconv.Itoa(op.status)] = ok
+	if op.path != "/metrics" && op.path != "/api/openapi.json" {
+		var errBody any = obj{"error": ""}
+		if strings.HasPrefix(op.path, "/api/v2/") {
+			errBody = ErrorResponse{}
+		}
+		responses["default"] = map[string]any{
+			"description": "错误",
+			"content":     map[string]any{"application/json": map[string]any{"schema": b.value(errBody)}},
+		}
+	}
+
+	result := map[string]any{
+		"summary":   op.summary,
+		"responses": responses,
+	}
+	if params != nil {
+		result["parameters"] = params
+	}
+	if op.request != nil {
+		content := map[string]any{"application/json": map[string]any{"schema": b.value(op.request)}}
+		if op.binary != "" {
+			content = map[string]any{op.binary: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}
+		}
+		if op.upload {
+			content["multipart/form-data"] = map[string]any{"schema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"metadata":   map[string]any{"type": "string", "description": "JSON，字段同 DeployRequest（不含 code / wasm）"},
+					"archive":    map[string]any{"type": "string", "format": "binary", "description": "zip / tar.gz 压缩包（js / ts 运行时）"},
+					"wasm":       map[string]any{"type": "string", "format": "binary", "description": "WebAssembly 二进制（wasm 运行时）"},
+					"glue":       map[string]any{"type": "string", "format": "binary", "description": "JS 胶水脚本（wasm 运行时，可选）"},
+					"executable": map[string]any{"type": "string", "format": "binary", "description": "可执行文件（exec 运行时，代替 archive）"},
+				},
+			}}
+		}
+		result["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+	return result
+}
+
+// value 返回 obj 或类型零值对应的 schema
+func (b *specBuilder) value(v any) map[string]any {
+	if o, ok := v.(obj); ok {
+		props := make(map[string]any, len(o))
+		for name, field := range o {
+			props[name] = b.value(field)
+		}
+		return map[string]any{"type": "object", "properties": props}
+	}
+	return b.schema(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema 由 Go 类型生成 schema，具名结构体放入 components 并返回引用
+func (b *specBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		if _, exists := b.schemas[t.Name()]; !exists {
+			b.schemas[t.Name()] = nil // 先占位，防止递归类型死循环
+			b.schemas[t.Name()] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8: // []byte 在 JSON 中为 base64 字符串
+		return map[string]any{"type": "string", "format": "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// object 按 json/binding 标签生成结构体 schema
+func (b *specBuilder) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	b.fields(t, props, &required)
+	result := map[string]any{"type": "object", "properties": props}
+	if required != nil {
+		result["required"] = required
+	}
+	return result
+}
+
+func (b *specBuilder) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			b.fields(f.Type, props, required) // 嵌入结构体的字段展开到外层
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := b.schema(f.Type)
+		for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+			key, arg, _ := strings.Cut(rule, "=")
+			switch key {
+			case "required":
+				*required = append(*required, name)
+			case "oneof":
+				s["enum"] = strings.Fields(arg)
+			case "gt", "gte", "min":
+				if n, err := strconv.ParseFloat(arg, 64); err == nil {
+					s["minimum"] = n
+					if key == "gt" {
+						s["exclusiveMinimum"] = true
+					}
+				}
+			}
+		}
+		props[name] = s
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := NewRouter(nil) // 只注册路由，不调用处理器
+
+	paths := OpenAPISpec()["paths"].(map[string]any)
+	for _, route := range router.Routes() {
+		item, ok := paths[openAPIPath(route.Path)].(map[string]any)
+		if !ok {
+			t.Errorf("route %s %s missing from OpenAPI spec", route.Method, route.Path)
+			continue
+		}
+		if _, ok := item[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("route %s %s missing from OpenAPI spec", route.Method, route.Path)
+		}
+	}
+
+	// 反过来：文档中的接口必须真实存在
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for _, op := range operations {
+		if !registered[op.method+" "+op.path] {
+			t.Errorf("OpenAPI operation %s %s is not registered", op.method, op.path)
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := NewRouter(nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var spec struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string                  `json:"required"`
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", spec.OpenAPI)
+	}
+
+	deploy, ok := spec.Components.Schemas["DeployRequest"]
+	if !ok {
+		t.Fatal("DeployRequest schema missing")
+	}
+	if strings.Join(deploy.Required, ",") != "runtime" {
+		t.Errorf("DeployRequest required = %v", deploy.Required)
+	}
+	if enum, _ := deploy.Properties["runtime"]["enum"].([]any); fmt.Sprint(enum) != "[js ts wasm exec]" {
+		t.Errorf("DeployRequest runtime enum = %v", deploy.Properties["runtime"]["enum"])
+	}
+	if format := deploy.Properties["wasm"]["format"]; format != "byte" {
+		t.Errorf("DeployRequest wasm format = %v", format)
+	}
+	for _, name := range []string{"RollbackRequest", "StopRequest", "DeleteVersionRequest", "VersionInfo", "ErrorResponse"} {
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Errorf("%s schema missing", name)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"faas/internal/registry"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader 按 API Key 限流时读取的请求头
+const APIKeyHeader = "X-API-Key"
+
+// RateLimitRequest 限流规则请求体
+type RateLimitRequest struct {
+	Alias  string  `json:"alias"`                                              // 别名（为空表示整个函数）
+	Rate   float64 `json:"rate" binding:"required,gt=0"`                       // 每秒请求数
+	Burst  int     `json:"burst" binding:"gte=0"`                              // 突发容量（默认取 rate 向上取整）
+	KeyBy  string  `json:"key_by" binding:"omitempty,oneof=ip api_key header"` // 客户端标识方式（默认 ip）
+	Header string  `json:"header" binding:"required_if=KeyBy header"`          // 自定义请求头
+}
+
+// DeleteRateLimitRequest 删除限流规则请求体
+type DeleteRateLimitRequest struct {
+	Alias string `json:"alias"` // 别名（为空表示整个函数）
+}
+
+// SetRateLimitHandler 设置限流规则接口（POST /api/ratelimit/:funcName）
+func SetRateLimitHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		var req RateLimitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		rule := &registry.RateLimitRule{
+			FuncName: funcName,
+			Alias:    req.Alias,
+			Rate:     req.Rate,
+			Burst:    req.Burst,
+			KeyBy:    req.KeyBy,
+			Header:   req.Header,
+		}
+		if err := reg.SetRateLimit(rule); err != nil {
+			status := deployErrorStatus(err)
+			if errors.Is(err, registry.ErrFunctionNotFound) || errors.Is(err, registry.ErrAliasNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":    "success",
+			"funcName":  funcName,
+			"rateLimit": rule,
+		})
+	}
+}
+
+// ListRateLimitsHandler 查询限流规则接口（GET /api/ratelimit/:funcName）
+func ListRateLimitsHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		rules := reg.ListRateLimits(funcName)
+		if rules == nil {
+			rules = []registry.RateLimitRule{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"funcName":   funcName,
+			"rateLimits": rules,
+		})
+	}
+}
+
+// DeleteRateLimitHandler 删除限流规则接口（POST /api/deleteRateLimit/:funcName）
+func DeleteRateLimitHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		var req DeleteRateLimitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := reg.DeleteRateLimit(funcName, req.Alias); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":   "success",
+			"funcName": funcName,
+			"alias":    req.Alias,
+			"message":  "rate limit deleted successfully",
+		})
+	}
+}
+
+// checkRateLimit 执行限流并写入配额响应头，返回 false 表示请求已被拒绝
+func checkRateLimit(reg *registry.Registry, w http.ResponseWriter, r *http.Request, funcName, alias string) bool {
+	rule, exists := reg.RateLimitFor(funcName, alias)
+	if !exists {
+		return true
+	}
+
+	res := reg.RateLimiter.Allow(rule.Key(), clientKey(r, &rule), rule.Rate, rule.Burst)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+		platformError(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// clientKey 按规则提取客户端标识，请求头缺失时退化为客户端 IP
+func clientKey(r *http.Request, rule *registry.RateLimitRule) string {
+	switch rule.KeyBy {
+	case registry.RateLimitKeyAPIKey:
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			return "key:" + key
+		}
+	case registry.RateLimitKeyHeader:
+		if value := r.Header.Get(rule.Header); value != "" {
+			return "header:" + value
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"faas/internal/metrics"
+	"faas/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewRouter 创建部署 API 服务（/metrics 与 /api 下的全部接口）
+// 新增路由需同步在 openapi.go 中描述，否则 TestOpenAPICoversRoutes 会失败
+func NewRouter(reg *registry.Registry) *gin.Engine {
+	ginEngine := gin.Default()
+	ginEngine.Use(metrics.GinMiddleware())
+	ginEngine.GET("/metrics", metrics.Handler())
+
+	apiGroup := ginEngine.Group("/api")
+	//apiGroup.Use(AuthMiddleware()) // 鉴权中间件
+	{
+		apiGroup.GET("/openapi.json", OpenAPIHandler())
+		apiGroup.GET("/list/:funcName", ListVersionsHandler(reg))
+		apiGroup.GET("/functions", ListFunctionsHandler(reg))
+		apiGroup.GET("/functions/:funcName", GetFunctionHandler(reg))
+		apiGroup.GET("/functions/:funcName/versions/:version/code", GetCodeHandler(reg))
+		apiGroup.POST("/deploy/:funcName", DeployHandler(reg))
+		apiGroup.POST("/rollback/:funcName", RollbackHandler(reg))
+		apiGroup.POST("/stop/:funcName", StopHandler(reg))
+		apiGroup.POST("/delete/:funcName", DeleteFunctionHandler(reg))
+		apiGroup.POST("/deleteVersion/:funcName", DeleteVersionHandler(reg))
+		apiGroup.POST("/apply", ApplyHandler(reg))
+		apiGroup.GET("/aliases/:funcName", ListAliasesHandler(reg))
+		apiGroup.POST("/alias/:funcName", SetAliasHandler(reg))
+		apiGroup.POST("/deleteAlias/:funcName", DeleteAliasHandler(reg))
+		apiGroup.GET("/env/:funcName", GetEnvHandler(reg))
+		apiGroup.POST("/env/:funcName", UpdateEnvHandler(reg))
+		apiGroup.GET("/logs/:funcName", LogsHandler(reg))
+		apiGroup.GET("/logs/:funcName/tail", TailLogsHandler(reg))
+		apiGroup.GET("/ratelimit/:funcName", ListRateLimitsHandler(reg))
+		apiGroup.POST("/ratelimit/:funcName", SetRateLimitHandler(reg))
+		apiGroup.POST("/deleteRateLimit/:funcName", DeleteRateLimitHandler(reg))
+		apiGroup.POST("/gc", GCHandler(reg))
+	}
+
+	// v2：资源化路由与统一错误结构，v1 保持不变
+	v2Group := apiGroup.Group("/v2")
+	{
+		v2Group.GET("/functions", V2ListFunctionsHandler(reg))
+		v2Group.GET("/functions/:funcName", V2GetFunctionHandler(reg))
+		v2Group.DELETE("/functions/:funcName", V2DeleteFunctionHandler(reg))
+		v2Group.GET("/functions/:funcName/versions", V2ListVersionsHandler(reg))
+		v2Group.POST("/functions/:funcName/versions", V2CreateVersionHandler(reg))
+		v2Group.GET("/functions/:funcName/versions/:version", V2GetVersionHandler(reg))
+		v2Group.PUT("/functions/:funcName/versions/:version", V2CreateVersionHandler(reg))
+		v2Group.PATCH("/functions/:funcName/versions/:version", V2PatchVersionHandler(reg))
+		v2Group.DELETE("/functions/:funcName/versions/:version", V2DeleteVersionHandler(reg))
+		v2Group.GET("/functions/:funcName/versions/:version/code", V2GetCodeHandler(reg))
+		v2Group.GET("/functions/:funcName/aliases", V2ListAliasesHandler(reg))
+		v2Group.PUT("/functions/:funcName/aliases/:alias", V2PutAliasHandler(reg))
+		v2Group.DELETE("/functions/:funcName/aliases/:alias", V2DeleteAliasHandler(reg))
+		v2Group.GET("/functions/:funcName/ratelimits", V2ListRateLimitsHandler(reg))
+		v2Group.PUT("/functions/:funcName/ratelimits", V2PutRateLimitHandler(reg))
+		v2Group.DELETE("/functions/:funcName/ratelimits", V2DeleteRateLimitHandler(reg))
+		v2Group.GET("/functions/:funcName/env", V2GetEnvHandler(reg))
+		v2Group.PATCH("/functions/:funcName/env", V2PatchEnvHandler(reg))
+		v2Group.GET("/functions/:funcName/database/migrations", V2ListMigrationsHandler(reg))
+		v2Group.POST("/functions/:funcName/database/migrations", V2MigrateDatabaseHandler(reg))
+		v2Group.POST("/functions/:funcName/database/query", V2QueryDatabaseHandler(reg))
+		v2Group.GET("/functions/:funcName/database/export", V2ExportDatabaseHandler(reg))
+		v2Group.PUT("/functions/:funcName/database/import", V2ImportDatabaseHandler(reg))
+		v2Group.POST("/apply", V2ApplyHandler(reg))
+	}
+
+	return ginEngine
+}
@@ -0,0 +1,332 @@
+// fakeworkerd 测试用的 workerd 替身：读取注册表生成的 capnp 配置，在配置的端口上提供 HTTP 服务。
+//
+// 响应由代码决定：正文为入口脚本中第一个 new Response("...") 的字符串字面量，
+// 其中的 {{NAME}} 替换为同名 text 绑定（环境变量）、json 绑定的 JSON 文本或 wasmModule 绑定模块的 SHA-256
+// （与 workerd 相同，wasmModule 指向的文件不是 WebAssembly 模块时启动失败）；脚本包含 fake:fail 时像 workerd 报告脚本异常一样
+// 向 stderr 输出错误与位置（service <name>: Uncaught Error: ... / at <file>:<line>:<column>）并退出。
+// 响应头 X-Fake-Pid 为进程 PID，X-Fake-Code-Sha256 为入口脚本的 SHA-256。
+//
+// 隔离运行的测试通过 /__fake/ 下的路径检查进程所处的环境：
+// stat?path= 文件是否存在，write?path= 能否创建文件，dial?addr= 能否建立 TCP 连接，
+// status 返回 /proc/self/status 中的 CapEff、NoNewPrivs 与 Seccomp。
+//
+// fetch?url= 像 worker 中的 fetch 一样发起请求，返回目标的状态码与正文：配置了 globalOutbound 时
+// 与 workerd 相同，以代理方式（请求行为完整 URL）发给出站服务并带上注入的请求头，否则直接访问。
+// service?binding=&path= 像 env.<binding>.fetch() 一样调用服务绑定（数据库绑定同样是服务），
+// 转发探测请求的方法与正文；header?name= 返回收到的请求头。
+// durable?binding=&name= 把 Durable Object 命名空间 binding 中名为 name 的对象的计数器加一并返回，
+// 计数器保存在 disk 服务目录下以 uniqueKey 命名的子目录中（与 workerd 的 localDisk 存储位置相同）。
+//
+// fakeworkerd exec 充当 exec 运行时部署的程序：在 $HOST:$PORT 上提供 HTTP 服务，正文为
+// "<FAAS_FUNCTION> <FAAS_VERSION> <GREETING> <工作目录名>"，供测试检查平台传入的环境。
+//
+// 用法：fakeworkerd serve <config.capnp> | fakeworkerd exec
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	servicePattern  = regexp.MustCompile(`name = "([^"]+)",\s*worker`)
+	addressPattern  = regexp.MustCompile(`name = "http",\s*address = "([^"]+)"`)
+	externalPattern = regexp.MustCompile(`external = \(\s*address = "([^"]+)"`)
+	outboundPattern = regexp.MustCompile(`globalOutbound = "([^"]+)"`)
+	headerPattern   = regexp.MustCompile(`\( name = "([^"]+)", value = "([^"]*)" \)`)
+	scriptPattern   = regexp.MustCompile(`(?:serviceWorkerScript|esModule) = embed "([^"]+)"`)
+	textPattern     = regexp.MustCompile(`\( name = "([^"]+)", text = "((?:[^"\\]|\\.)*)" \)`)
+	bodyPattern     = regexp.MustCompile(`new Response\(("(?:[^"\\]|\\.)*")`)
+	jsonPattern     = regexp.MustCompile(`\( name = "([^"]+)", json = ("(?:[^"\\]|\\.)*") \)`)
+	wasmPattern     = regexp.MustCompile(`\( name = "([^"]+)", wasmModule = embed "([^"]+)" \)`)
+)
+
+func main() {
+	var err error
+	switch {
+	case len(os.Args) == 3 && os.Args[1] == "serve":
+		err = serve(os.Args[2])
+	case len(os.Args) == 2 && os.Args[1] == "exec":
+		err = serveExec()
+	default:
+		fmt.Fprintln(os.Stderr, "usage: fakeworkerd serve <config.capnp> | fakeworkerd exec")
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func serve(confPath string) error {
+	conf, err := os.ReadFile(confPath)
+	if err != nil {
+		return err
+	}
+	address := addressPattern.FindSubmatch(conf)
+	if address == nil {
+		return fmt.Errorf("%s: no socket address", confPath)
+	}
+	script := scriptPattern.FindSubmatch(conf) // 多文件部署时入口模块排在首位
+	if script == nil {
+		return fmt.Errorf("%s: no worker script", confPath)
+	}
+	code, err := os.ReadFile(filepath.Join(filepath.Dir(confPath), string(script[1])))
+	if err != nil {
+		return err
+	}
+	if i := strings.Index(string(code), "fake:fail"); i >= 0 {
+		before := string(code[:i])
+		line, column := strings.Count(before, "\n")+1, i-strings.LastIndex(before, "\n")
+		return fmt.Errorf("service %s: Uncaught Error: fake:fail\n  at %s:%d:%d", serviceName(conf), script[1], line, column)
+	}
+
+	body := "ok"
+	if literal := bodyPattern.FindSubmatch(code); literal != nil {
+		if body, err = strconv.Unquote(string(literal[1])); err != nil {
+			return fmt.Errorf("invalid response literal %s: %w", literal[1], err)
+		}
+	}
+	for _, binding := range textPattern.FindAllSubmatch(conf, -1) {
+		value, err := strconv.Unquote(`"` + string(binding[2]) + `"`)
+		if err != nil {
+			return fmt.Errorf("invalid text binding %s: %w", binding[1], err)
+		}
+		body = strings.ReplaceAll(body, "{{"+string(binding[1])+"}}", value)
+	}
+	for _, binding := range jsonPattern.FindAllSubmatch(conf, -1) {
+		value, err := strconv.Unquote(string(binding[2]))
+		if err != nil || !json.Valid([]byte(value)) {
+			return fmt.Errorf("invalid json binding %s: %s", binding[1], binding[2])
+		}
+		body = strings.ReplaceAll(body, "{{"+string(binding[1])+"}}", value)
+	}
+	for _, binding := range wasmPattern.FindAllSubmatch(conf, -1) {
+		module, err := os.ReadFile(filepath.Join(filepath.Dir(confPath), string(binding[2])))
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(module, []byte("\x00asm")) {
+			return fmt.Errorf("wasm binding %s: %s is not a WebAssembly module", binding[1], binding[2])
+		}
+		moduleSum := sha256.Sum256(module)
+		body = strings.ReplaceAll(body, "{{"+string(binding[1])+"}}", hex.EncodeToString(moduleSum[:]))
+	}
+
+	sum := sha256.Sum256(code)
+	pid := strconv.Itoa(os.Getpid())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Fake-Pid", pid)
+		w.Header().Set("X-Fake-Code-Sha256", hex.EncodeToString(sum[:]))
+		fmt.Fprint(w, body)
+	})
+	mux.HandleFunc("/__fake/", probe)
+	mux.HandleFunc("/__fake/fetch", func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("url")
+		if outbound := outboundPattern.FindSubmatch(conf); outbound != nil {
+			fetch(w, http.MethodGet, target, nil, conf, string(outbound[1]))
+			return
+		}
+		fetch(w, http.MethodGet, target, nil, conf, "")
+	})
+	mux.HandleFunc("/__fake/durable", func(w http.ResponseWriter, r *http.Request) {
+		count, err := durableIncrement(conf, r.URL.Query().Get("binding"), r.URL.Query().Get("name"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, count)
+	})
+	mux.HandleFunc("/__fake/service", func(w http.ResponseWriter, r *http.Request) {
+		binding := r.URL.Query().Get("binding")
+		service := regexp.MustCompile(fmt.Sprintf(`\( name = "%s", service = "([^"]+)" \)`, regexp.QuoteMeta(binding))).FindSubmatch(conf)
+		if service == nil {
+			http.Error(w, "no service binding "+binding, http.StatusInternalServerError)
+			return
+		}
+		fetch(w, r.Method, "http://"+binding+r.URL.Query().Get("path"), r.Body, conf, string(service[1]))
+	})
+
+	// workerd 的地址可以是 host:port 或 unix:<path>
+	network, addr := splitAddress(string(address[1]))
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("fakeworkerd listening on %s\n", address[1])
+	return http.Serve(ln, mux)
+}
+
+// serveExec 以 exec 运行时约定的环境变量提供 HTTP 服务
+func serveExec() error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	body := strings.Join([]string{os.Getenv("FAAS_FUNCTION"), os.Getenv("FAAS_VERSION"), os.Getenv("GREETING"), filepath.Base(dir)}, " ")
+	pid := strconv.Itoa(os.Getpid())
+	addr := net.JoinHostPort(os.Getenv("HOST"), os.Getenv("PORT"))
+	fmt.Printf("fakeworkerd exec listening on %s\n", addr)
+	return http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Fake-Pid", pid)
+		fmt.Fprint(w, body)
+	}))
+}
+
+// probe 检查进程所处的环境，操作失败时返回 500 与错误信息
+func probe(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch strings.TrimPrefix(r.URL.Path, "/__fake/") {
+	case "stat":
+		_, err = os.Stat(r.URL.Query().Get("path"))
+	case "write":
+		err = os.WriteFile(r.URL.Query().Get("path"), []byte("fake"), 0644)
+	case "dial":
+		var conn net.Conn
+		if conn, err = net.Dial("tcp", r.URL.Query().Get("addr")); err == nil {
+			conn.Close()
+		}
+	case "header":
+		fmt.Fprint(w, r.Header.Get(r.URL.Query().Get("name")))
+		return
+	case "status":
+		var status []byte
+		if status, err = os.ReadFile("/proc/self/status"); err == nil {
+			for _, line := range strings.Split(string(status), "\n") {
+				if key, _, _ := strings.Cut(line, ":"); key == "CapEff" || key == "NoNewPrivs" || key == "Seccomp" {
+					fmt.Fprintln(w, strings.Join(strings.Fields(line), " "))
+				}
+			}
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+// splitAddress workerd 的地址可以是 host:port 或 unix:<path>
+func splitAddress(address string) (network, addr string) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		return "unix", path
+	}
+	return "tcp", address
+}
+
+// fetch 经配置中名为 service 的外部服务（为空时直接）发起请求，把目标的状态码与正文原样返回
+func fetch(w http.ResponseWriter, method, target string, body io.Reader, conf []byte, service string) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var resp *http.Response
+	if service != "" {
+		resp, err = fetchVia(req, conf, service)
+	} else {
+		resp, err = http.DefaultClient.Do(req)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// fetchVia 把请求发给配置中名为 service 的外部服务：style = proxy 时请求行为完整 URL，否则只有路径
+func fetchVia(req *http.Request, conf []byte, service string) (*http.Response, error) {
+	start := strings.Index(string(conf), fmt.Sprintf(`name = "%s",`, service))
+	if start < 0 {
+		return nil, fmt.Errorf("service %s not found", service)
+	}
+	external := conf[start:]
+	if end := strings.Index(string(external), "]"); end >= 0 {
+		external = external[:end] // 只取该服务的注入请求头
+	}
+	address := externalPattern.FindSubmatch(external)
+	if address == nil {
+		return nil, fmt.Errorf("service %s is not an external service", service)
+	}
+	for _, header := range headerPattern.FindAllSubmatch(external, -1) {
+		req.Header.Set(string(header[1]), string(header[2]))
+	}
+	network, addr := splitAddress(string(address[1]))
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	write := req.Write
+	if strings.Contains(string(external), "style = proxy") {
+		write = req.WriteProxy
+	}
+	if err := write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{resp.Body, conn}
+	return resp, nil
+}
+
+// durableIncrement 找到绑定对应的命名空间与存储目录，把对象的计数器加一
+func durableIncrement(conf []byte, binding, name string) (int, error) {
+	class := regexp.MustCompile(fmt.Sprintf(`\( name = "%s", durableObjectNamespace = "([^"]+)" \)`, regexp.QuoteMeta(binding))).FindSubmatch(conf)
+	if class == nil {
+		return 0, fmt.Errorf("no durable object binding %s", binding)
+	}
+	key := regexp.MustCompile(fmt.Sprintf(`\( className = "%s", uniqueKey = "([^"]+)" \)`, regexp.QuoteMeta(string(class[1])))).FindSubmatch(conf)
+	storage := regexp.MustCompile(`durableObjectStorage = \( localDisk = "([^"]+)" \)`).FindSubmatch(conf)
+	if key == nil || storage == nil {
+		return 0, fmt.Errorf("durable object class %s has no storage", class[1])
+	}
+	disk := regexp.MustCompile(fmt.Sprintf(`name = "%s",\s*disk = \( path = "([^"]+)", writable = true \)`, regexp.QuoteMeta(string(storage[1])))).FindSubmatch(conf)
+	if disk == nil {
+		return 0, fmt.Errorf("no writable disk service %s", storage[1])
+	}
+	path := filepath.Join(string(disk[1]), string(key[1]), name)
+	count := 0
+	if data, err := os.ReadFile(path); err == nil {
+		count, _ = strconv.Atoi(string(data))
+	}
+	count++
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	return count, os.WriteFile(path, []byte(strconv.Itoa(count)), 0644)
+}
+
+// serviceName 配置中的服务名
+func serviceName(conf []byte) string {
+	if match := servicePattern.FindSubmatch(conf); match != nil {
+		return string(match[1])
+	}
+	return "main"
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"faas/internal/archive"
+	"faas/internal/registry"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// MaxUploadSize multipart 部署请求体上限（解压后的限制见 archive.DefaultLimits）
+var MaxUploadSize int64 = 20 << 20
+
+// ArchiveManifestFile 压缩包根目录下用于指定入口的清单文件
+const ArchiveManifestFile = "faas.json"
+
+// defaultEntries 未指定入口时依次尝试的文件
+var defaultEntries = []string{"index.js", "index.mjs", "worker.js", "src/index.js", "src/index.mjs"}
+
+// defaultTypeScriptEntries TypeScript 部署未指定入口时依次尝试的文件
+var defaultTypeScriptEntries = []string{"index.ts", "worker.ts", "src/index.ts", "src/worker.ts"}
+
+// defaultExecEntries exec 部署未指定入口时依次尝试的文件
+var defaultExecEntries = []string{"main", "app", "server", "main.py", "app.py", "server.py"}
+
+// RuntimeExec 本地可执行文件运行时：代码为可执行文件或带 shebang 的脚本，通过环境变量 PORT 监听 HTTP
+const RuntimeExec = registry.ExecRuntimeName
+
+// bindDeployRequest 解析部署请求：multipart/form-data 为压缩包或 wasm 上传，其余按 JSON 解析；
+// 随后按运行时编译 TypeScript 或校验 WebAssembly 二进制
+func bindDeployRequest(c *gin.Context, req *DeployRequest) error {
+	var err error
+	if c.ContentType() == binding.MIMEMultipartPOSTForm {
+		err = bindDeployUpload(c, req)
+	} else {
+		err = c.ShouldBindJSON(req)
+	}
+	if err != nil {
+		return err
+	}
+	return prepareRuntime(req)
+}
+
+// bindDeployUpload 解析 multipart 部署请求：metadata 部分为 JSON（字段同 DeployRequest，不含 code / wasm），
+// archive 部分为 zip / tar.gz 压缩包；wasm 运行时改为 wasm 部分上传二进制、可选的 glue 部分上传胶水脚本；
+// exec 运行时也可以用 executable 部分直接上传单个可执行文件
+func bindDeployUpload(c *gin.Context, req *DeployRequest) error {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxUploadSize)
+	form, err := c.MultipartForm()
+	if err != nil {
+		return err
+	}
+
+	metadata, err := formPart(form, "metadata")
+	if err != nil {
+		return err
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, req); err != nil {
+			return fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+	if req.Code != "" || req.Wasm != nil {
+		return errors.New("code and wasm must not be set in metadata when uploading files")
+	}
+	if req.Runtime == RuntimeWasm {
+		return bindWasmUpload(form, req)
+	}
+	if req.Runtime == RuntimeExec {
+		executable, err := formPart(form, "executable")
+		if err != nil {
+			return err
+		}
+		if executable != nil {
+			req.Code = string(executable)
+			return binding.Validator.ValidateStruct(req)
+		}
+	}
+
+	data, err := formPart(form, "archive")
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return errors.New("missing archive part")
+	}
+	files, err := archive.Extract(data, archive.DefaultLimits)
+	if err != nil {
+		return err
+	}
+
+	entry, err := resolveEntry(files, req.Entry, req.Runtime)
+	if err != nil {
+		return err
+	}
+	req.Entry, req.Code, req.Modules = entry, string(files[entry]), files
+	return binding.Validator.ValidateStruct(req)
+}
+
+// bindWasmUpload 读取 wasm 与 glue 部分
+func bindWasmUpload(form *multipart.Form, req *DeployRequest) error {
+	wasm, err := formPart(form, "wasm")
+	if err != nil {
+		return err
+	}
+	if wasm == nil {
+		return errors.New("missing wasm part")
+	}
+	glue, err := formPart(form, "glue")
+	if err != nil {
+		return err
+	}
+	req.Wasm, req.Code = wasm, string(glue)
+	return binding.Validator.ValidateStruct(req)
+}
+
+// formPart 读取表单字段或文件部分，不存在时返回 nil
+func formPart(form *multipart.Form, name string) ([]byte, error) {
+	if values := form.Value[name]; len(values) > 0 {
+		return []byte(values[0]), nil
+	}
+	headers := form.File[name]
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	f, err := headers[0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// resolveEntry 确定入口模块：metadata.entry > faas.json 的 entry > package.json 的 main > 默认文件
+func resolveEntry(files map[string][]byte, entry, runtime string) (string, error) {
+	candidates, exts := defaultEntries, []string{".js", ".mjs"}
+	switch runtime {
+	case RuntimeTypeScript:
+		candidates, exts = defaultTypeScriptEntries, []string{".ts", ".mts"}
+	case RuntimeExec:
+		candidates, exts = defaultExecEntries, nil // 可执行文件不限扩展名
+	}
+	if entry == "" {
+		entry = manifestEntry(files[ArchiveManifestFile], "entry")
+	}
+	if entry == "" {
+		entry = manifestEntry(files["package.json"], "main")
+	}
+	if entry == "" {
+		for _, name := range candidates {
+			if _, exists := files[name]; exists {
+				entry = name
+				break
+			}
+		}
+		if entry == "" {
+			return "", fmt.Errorf("no entry module found, set entry in metadata or %s", ArchiveManifestFile)
+		}
+	}
+
+	clean, err := archive.CleanPath(strings.TrimPrefix(entry, "./"))
+	if err != nil {
+		return "", err
+	}
+	if _, exists := files[clean]; !exists {
+		return "", fmt.Errorf("entry module %q not found in archive", clean)
+	}
+	if exts != nil && !slices.Contains(exts, path.Ext(clean)) {
+		return "", fmt.Errorf("entry module %q must be a %s file", clean, strings.Join(exts, " or "))
+	}
+	return clean, nil
+}
+
+// manifestEntry 读取 JSON 清单中的入口字段
+func manifestEntry(data []byte, field string) string {
+	if data == nil {
+		return ""
+	}
+	var manifest map[string]any
+	if json.Unmarshal(data, &manifest) != nil {
+		return ""
+	}
+	entry, _ := manifest[field].(string)
+	return entry
+}
+
+// uploadErrorStatus 请求体超出上限时返回 413，TypeScript 编译失败返回 422，其余为 400
+func uploadErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	if _, ok := compileDiagnostics(err); ok {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
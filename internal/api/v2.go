@@ -0,0 +1,485 @@
+package api
+
+import (
+	"errors"
+	"faas/internal/registry"
+	"faas/internal/typescript"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// v2 错误码
+const (
+	CodeBadRequest       = "bad_request"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeValidationFailed = "validation_failed"
+	CodeCompileFailed    = "compile_failed"
+	CodeStartFailed      = "start_failed"      // 新版本启动失败
+	CodeSmokeTestFailed  = "smoke_test_failed" // 新版本未通过冒烟测试
+	CodeQueryFailed      = "query_failed"      // SQL 语句执行失败
+	CodeInternal         = "internal_error"
+)
+
+// ErrorBody v2 统一错误结构
+type ErrorBody struct {
+	Code        string                  `json:"code"`
+	Message     string                  `json:"message"`
+	Details     []FieldError            `json:"details,omitempty"`
+	Diagnostics []typescript.Diagnostic `json:"diagnostics,omitempty"` // TypeScript 编译错误
+	Start       *registry.StartError    `json:"start,omitempty"`       // 新版本启动失败 / 冒烟测试未通过
+	Applied     []PlanStep              `json:"applied,omitempty"`     // apply 中途失败时已生效的步骤
+}
+
+// FieldError 字段校验错误
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+// ErrorResponse v2 错误响应（{"error": {...}}）
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// PatchVersionRequest 修改版本状态请求体
+type PatchVersionRequest struct {
+	Status string `json:"status" binding:"required,oneof=running suspended"`
+}
+
+// PutAliasRequest 设置别名请求体
+type PutAliasRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+// abortV2 以统一结构返回错误
+func abortV2(c *gin.Context, status int, code, message string, details ...FieldError) {
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: ErrorBody{Code: code, Message: message, Details: details}})
+}
+
+// v2Error 将注册表错误映射为 HTTP 状态码
+func v2Error(c *gin.Context, err error) {
+	status, body := v2ErrorBody(err)
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: body})
+}
+
+// v2ErrorBody 返回注册表错误对应的状态码与错误体
+func v2ErrorBody(err error) (int, ErrorBody) {
+	var validationErr *registry.ValidationError
+	var startErr *registry.StartError
+	var queryErr *registry.QueryError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, registry.ErrFunctionNotFound),
+		errors.Is(err, registry.ErrVersionNotFound),
+		errors.Is(err, registry.ErrAliasNotFound),
+		errors.Is(err, registry.ErrRateLimitNotFound),
+		errors.Is(err, registry.ErrDatabaseNotFound):
+		return http.StatusNotFound, ErrorBody{Code: CodeNotFound, Message: err.Error()}
+	case errors.Is(err, registry.ErrVersionExists),
+		errors.Is(err, registry.ErrAlreadyStopped),
+		errors.Is(err, registry.ErrAlreadyRunning):
+		return http.StatusConflict, ErrorBody{Code: CodeConflict, Message: err.Error()}
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity, ErrorBody{Code: CodeValidationFailed, Message: err.Error()}
+	case errors.As(err, &queryErr):
+		return http.StatusBadRequest, ErrorBody{Code: CodeQueryFailed, Message: err.Error()}
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge, ErrorBody{Code: CodePayloadTooLarge, Message: err.Error()}
+	case errors.As(err, &startErr):
+		code := CodeStartFailed
+		if startErr.Stage == registry.StageSmokeTest {
+			code = CodeSmokeTestFailed
+		}
+		return http.StatusUnprocessableEntity, ErrorBody{Code: code, Message: err.Error(), Start: startErr}
+	default:
+		return http.StatusInternalServerError, ErrorBody{Code: CodeInternal, Message: err.Error()}
+	}
+}
+
+// bindV2 解析请求体：格式错误返回 400，字段校验失败返回 422
+func bindV2(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		v2BindError(c, err)
+		return false
+	}
+	return true
+}
+
+// v2BindError 将请求解析错误映射为 400 / 413 / 422（校验失败或 TypeScript 编译失败）
+func v2BindError(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	var tooLarge *http.MaxBytesError
+	diagnostics, compileFailed := compileDiagnostics(err)
+	switch {
+	case errors.As(err, &validationErrs):
+		details := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			details = append(details, FieldError{Field: fe.Namespace(), Rule: fe.Tag()})
+		}
+		abortV2(c, http.StatusUnprocessableEntity, CodeValidationFailed, "request validation failed", details...)
+	case errors.As(err, &tooLarge):
+		abortV2(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error())
+	case compileFailed:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse{Error: ErrorBody{
+			Code: CodeCompileFailed, Message: "typescript compilation failed", Diagnostics: diagnostics,
+		}})
+	case errors.Is(err, io.EOF):
+		abortV2(c, http.StatusBadRequest, CodeBadRequest, "request body is required")
+	default:
+		abortV2(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+	}
+}
+
+// V2ListFunctionsHandler GET /api/v2/functions
+func V2ListFunctionsHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := queryFunctions(c, reg)
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// V2GetFunctionHandler GET /api/v2/functions/:funcName
+func V2GetFunctionHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		detail, exists := reg.DescribeFunction(c.Param("funcName"))
+		if !exists {
+			v2Error(c, registry.ErrFunctionNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, detail)
+	}
+}
+
+// V2DeleteFunctionHandler DELETE /api/v2/functions/:funcName
+func V2DeleteFunctionHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := reg.DeleteFunction(c.Param("funcName")); err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// V2ListVersionsHandler GET /api/v2/functions/:funcName/versions
+func V2ListVersionsHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		detail, exists := reg.DescribeFunction(c.Param("funcName"))
+		if !exists {
+			v2Error(c, registry.ErrFunctionNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"versions": detail.Versions})
+	}
+}
+
+// V2CreateVersionHandler 部署新版本，支持 JSON 或 multipart 压缩包（POST /api/v2/functions/:funcName/versions，
+// PUT /api/v2/functions/:funcName/versions/:version），成功返回 201，内容与已有版本相同时返回该版本及 200
+func V2CreateVersionHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		var req DeployRequest
+		if err := bindDeployRequest(c, &req); err != nil {
+			v2BindError(c, err)
+			return
+		}
+		if version := c.Param("version"); version != "" {
+			if req.Version != "" && req.Version != version {
+				abortV2(c, http.StatusUnprocessableEntity, CodeValidationFailed, "version in body does not match path")
+				return
+			}
+			req.Version = version
+		}
+		if err := validateNames(funcName, req.Version); err != nil {
+			v2Error(c, err)
+			return
+		}
+
+		explicitVersion := req.Version != ""
+		meta := newFunctionMeta(funcName, &req)
+		deployed, created, err := deployVersion(c.Request.Context(), reg, meta, explicitVersion)
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		respondVersion(c, reg, funcName, deployed.Version, created)
+	}
+}
+
+// V2GetVersionHandler GET /api/v2/functions/:funcName/versions/:version
+func V2GetVersionHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, exists := reg.DescribeVersion(c.Param("funcName"), c.Param("version"))
+		if !exists {
+			v2Error(c, registry.ErrVersionNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+// V2PatchVersionHandler 启动/停止版本（PATCH /api/v2/functions/:funcName/versions/:version）
+func V2PatchVersionHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName, version := c.Param("funcName"), c.Param("version")
+		var req PatchVersionRequest
+		if !bindV2(c, &req) {
+			return
+		}
+
+		var err error
+		if req.Status == "suspended" {
+			err = reg.StopFunction(funcName, version)
+		} else {
+			err = reg.StartFunction(c.Request.Context(), funcName, version)
+		}
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		info, _ := reg.DescribeVersion(funcName, version)
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+// V2DeleteVersionHandler DELETE /api/v2/functions/:funcName/versions/:version
+func V2DeleteVersionHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := reg.DeleteFunctionVersion(c.Param("funcName"), c.Param("version")); err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// V2GetCodeHandler GET /api/v2/functions/:funcName/versions/:version/code
+func V2GetCodeHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName, version := c.Param("funcName"), c.Param("version")
+		if _, exists := reg.GetByVersion(funcName, version); !exists {
+			v2Error(c, registry.ErrVersionNotFound)
+			return
+		}
+		if _, _, exists := reg.GetSource(funcName, version); c.Query("source") == "true" && !exists {
+			abortV2(c, http.StatusNotFound, CodeNotFound, "version has no typescript source")
+			return
+		}
+		if _, exists := reg.GetWasm(funcName, version); c.Query("wasm") == "true" && !exists {
+			abortV2(c, http.StatusNotFound, CodeNotFound, "version has no wasm module")
+			return
+		}
+		GetCodeHandler(reg)(c)
+	}
+}
+
+// V2ListAliasesHandler GET /api/v2/functions/:funcName/aliases
+func V2ListAliasesHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		if _, exists := reg.GetByName(funcName); !exists {
+			v2Error(c, registry.ErrFunctionNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"aliases": reg.Aliases(funcName)})
+	}
+}
+
+// V2PutAliasHandler 设置别名（PUT /api/v2/functions/:funcName/aliases/:alias），latest 按回滚处理
+func V2PutAliasHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName, alias := c.Param("funcName"), c.Param("alias")
+		var req PutAliasRequest
+		if !bindV2(c, &req) {
+			return
+		}
+
+		var err error
+		if alias == "latest" {
+			empty := ""
+			err = reg.Rollback(&empty, funcName, req.Version)
+		} else {
+			err = reg.SetAlias(funcName, alias, req.Version)
+		}
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"alias":     alias,
+			"version":   req.Version,
+			"accessUrl": fmt.Sprintf("http://%s.%s.func.local", alias, funcName),
+		})
+	}
+}
+
+// V2DeleteAliasHandler DELETE /api/v2/functions/:funcName/aliases/:alias
+func V2DeleteAliasHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := reg.DeleteAlias(c.Param("funcName"), c.Param("alias")); err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// V2ListRateLimitsHandler GET /api/v2/functions/:funcName/ratelimits
+func V2ListRateLimitsHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules := reg.ListRateLimits(c.Param("funcName"))
+		if rules == nil {
+			rules = []registry.RateLimitRule{}
+		}
+		c.JSON(http.StatusOK, gin.H{"rateLimits": rules})
+	}
+}
+
+// V2PutRateLimitHandler 新增/更新限流规则（PUT /api/v2/functions/:funcName/ratelimits，别名在请求体中）
+func V2PutRateLimitHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RateLimitRequest
+		if !bindV2(c, &req) {
+			return
+		}
+		rule := &registry.RateLimitRule{
+			FuncName: c.Param("funcName"),
+			Alias:    req.Alias,
+			Rate:     req.Rate,
+			Burst:    req.Burst,
+			KeyBy:    req.KeyBy,
+			Header:   req.Header,
+		}
+		if err := reg.SetRateLimit(rule); err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// V2DeleteRateLimitHandler DELETE /api/v2/functions/:funcName/ratelimits?alias=
+func V2DeleteRateLimitHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := reg.DeleteRateLimit(c.Param("funcName"), c.Query("alias")); err != nil {
+			v2Error(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// V2GetEnvHandler GET /api/v2/functions/:funcName/env?version=
+func V2GetEnvHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		var info *registry.VersionInfo
+		var exists bool
+		if version := c.Query("version"); version != "" {
+			info, exists = reg.DescribeVersion(funcName, version)
+		} else if latest, ok := reg.GetByName(funcName); ok {
+			info, exists = reg.DescribeVersion(funcName, latest.Version)
+		}
+		if !exists {
+			v2Error(c, registry.ErrVersionNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"version": info.Version, "keys": info.EnvKeys})
+	}
+}
+
+// V2PatchEnvHandler 修改环境变量并部署新版本（PATCH /api/v2/functions/:funcName/env），成功返回 201（配置未变化时 200）
+func V2PatchEnvHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcName := c.Param("funcName")
+		var req UpdateEnvRequest
+		if !bindV2(c, &req) {
+			return
+		}
+		if err := validateNames(funcName, req.Version); err != nil {
+			v2Error(c, err)
+			return
+		}
+		explicitVersion := req.Version != ""
+		meta, err := newEnvVersion(reg, funcName, &req)
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		deployed, created, err := deployVersion(c.Request.Context(), reg, meta, explicitVersion)
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		respondVersion(c, reg, funcName, deployed.Version, created)
+	}
+}
+
+// V2ApplyHandler POST /api/v2/apply?dry_run=true
+func V2ApplyHandler(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var m Manifest
+		if !bindV2(c, &m) {
+			return
+		}
+		if _, exists := m.Aliases["latest"]; exists {
+			abortV2(c, http.StatusUnprocessableEntity, CodeValidationFailed, "alias latest is managed by deploy and cannot be declared")
+			return
+		}
+		if err := validateNames(m.Name, m.Version); err != nil {
+			v2Error(c, err)
+			return
+		}
+		if err := validateWasm(m.Runtime, m.Wasm); err != nil {
+			abortV2(c, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+			return
+		}
+		dryRun := c.Query("dry_run") == "true"
+
+		plan, deployReq, err := planManifest(reg, &m)
+		if _, ok := compileDiagnostics(err); ok {
+			v2BindError(c, err)
+			return
+		}
+		if err != nil {
+			v2Error(c, err)
+			return
+		}
+		if !dryRun {
+			if err := applyPlan(c, reg, &m, deployReq); err != nil {
+				status, body := v2ErrorBody(err)
+				body.Applied = appliedSteps(err)
+				c.AbortWithStatusJSON(status, ErrorResponse{Error: body})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"funcName": m.Name,
+			"version":  m.Version,
+			"dryRun":   dryRun,
+			"changed":  planChanges(plan),
+			"plan":     plan,
+		})
+	}
+}
+
+// respondVersion 返回版本详情：新建版本为 201，沿用相同内容的已有版本为 200
+func respondVersion(c *gin.Context, reg *registry.Registry, funcName, version string, created bool) {
+	info, _ := reg.DescribeVersion(funcName, version)
+	c.Header("Location", fmt.Sprintf("/api/v2/functions/%s/versions/%s", funcName, version))
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, info)
+}
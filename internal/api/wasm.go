@@ -0,0 +1,256 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+)
+
+// RuntimeWasm WebAssembly 运行时：部署 .wasm 二进制与可选的 JS 胶水脚本，模块以 WASM_MODULE 绑定注入
+const RuntimeWasm = "wasm"
+
+// wasmMagic WebAssembly 二进制文件头（\0asm 与版本号 1）
+var wasmMagic = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+// validateWasm 校验 WebAssembly 二进制：wasm 运行时必须提供合法的二进制模块，其他运行时不能提供
+func validateWasm(runtime string, wasm []byte) error {
+	switch {
+	case runtime != RuntimeWasm && len(wasm) > 0:
+		return errors.New("wasm is only allowed for runtime wasm")
+	case runtime != RuntimeWasm:
+		return nil
+	case len(wasm) == 0:
+		return errors.New("wasm is required for runtime wasm")
+	case !bytes.HasPrefix(wasm, wasmMagic):
+		return errors.New("wasm is not a WebAssembly binary module")
+	}
+	return nil
+}
+
+// prepareRuntime 按运行时处理部署请求：ts 编译为 JavaScript；wasm 校验二进制，未提供胶水脚本时使用 defaultWasmGlue
+func prepareRuntime(req *DeployRequest) error {
+	if err := validateWasm(req.Runtime, req.Wasm); err != nil {
+		return err
+	}
+	switch req.Runtime {
+	case RuntimeTypeScript:
+		return compileTypeScript(req)
+	case RuntimeWasm:
+		if req.Modules != nil {
+			return errors.New("runtime wasm does not accept an archive, upload the wasm binary instead")
+		}
+		if req.Code == "" {
+			req.Code = defaultWasmGlue
+		}
+	}
+	return nil
+}
+
+// defaultWasmGlue 默认胶水脚本（Service Worker 格式）：按 WASI preview1 命令方式运行模块，
+// 支持 GOOS=wasip1 的 Go、TinyGo（-target=wasi）与 Rust（wasm32-wasip1）编译产物
+const defaultWasmGlue = `// faas 默认胶水脚本：以 WASI (preview1) 命令方式运行 WASM_MODULE，每个请求实例化一次。
+// 请求体为 stdin，请求信息与环境变量为 environ（CGI 约定），stdout 为响应（可带 CGI 响应头）
+const encoder = new TextEncoder();
+const decoder = new TextDecoder();
+
+const ESUCCESS = 0, EBADF = 8, EINVAL = 28, ENOSYS = 52, ESPIPE = 70;
+
+class ProcExit {
+  constructor(code) { this.code = code; }
+}
+
+addEventListener("fetch", (event) => event.respondWith(handle(event.request)));
+
+async function handle(request) {
+  const url = new URL(request.url);
+  const env = Object.assign({}, typeof WASM_ENV === "object" ? WASM_ENV : {}, {
+    REQUEST_METHOD: request.method,
+    REQUEST_URI: url.pathname + url.search,
+    PATH_INFO: url.pathname,
+    QUERY_STRING: url.search.slice(1),
+    SERVER_NAME: url.hostname,
+    SERVER_PROTOCOL: "HTTP/1.1",
+  });
+  for (const [name, value] of request.headers) {
+    const key = name.toUpperCase().replace(/-/g, "_");
+    env[key === "CONTENT_TYPE" || key === "CONTENT_LENGTH" ? key : "HTTP_" + key] = value;
+  }
+
+  const stdin = new Uint8Array(await request.arrayBuffer());
+  const wasi = new WASI(["main.wasm"], env, stdin);
+  const instance = await WebAssembly.instantiate(WASM_MODULE, { wasi_snapshot_preview1: wasi.imports() });
+  wasi.memory = instance.exports.memory;
+
+  let code = 0;
+  try {
+    if (typeof instance.exports._start === "function") {
+      instance.exports._start();
+    } else {
+      return new Response("wasm module does not export _start\n", { status: 500 });
+    }
+  } catch (err) {
+    if (!(err instanceof ProcExit)) {
+      console.error("wasm trap:", String(err && err.stack || err));
+      return new Response("wasm trap: " + String(err) + "\n", { status: 500 });
+    }
+    code = err.code;
+  }
+  const stderr = decoder.decode(concat(wasi.stderr));
+  if (stderr) console.error(stderr);
+  if (code !== 0) {
+    return new Response("wasm exited with code " + code + "\n" + stderr, { status: 500 });
+  }
+  return parseOutput(concat(wasi.stdout));
+}
+
+// parseOutput 输出以 "Name: value" 行开头并以空行结束时按 CGI 响应头解析（Status 设置状态码）
+function parseOutput(out) {
+  const limit = Math.min(out.length, 16384);
+  let end = -1, skip = 0;
+  for (let i = 0; i < limit; i++) {
+    if (out[i] === 10 && out[i + 1] === 10) { end = i; skip = 2; break; }
+    if (out[i] === 13 && out[i + 1] === 10 && out[i + 2] === 13 && out[i + 3] === 10) { end = i; skip = 4; break; }
+  }
+  if (end > 0) {
+    const lines = decoder.decode(out.subarray(0, end)).split(/\r?\n/);
+    if (lines.every((line) => /^[A-Za-z0-9-]+:/.test(line))) {
+      const headers = new Headers();
+      let status = 200;
+      for (const line of lines) {
+        const i = line.indexOf(":");
+        const name = line.slice(0, i).trim(), value = line.slice(i + 1).trim();
+        if (name.toLowerCase() === "status") {
+          status = parseInt(value, 10) || 500;
+        } else {
+          headers.append(name, value);
+        }
+      }
+      if (headers.has("location") && status === 200) status = 302;
+      return new Response(out.subarray(end + skip), { status, headers });
+    }
+  }
+  return new Response(out, { headers: { "content-type": "text/plain; charset=utf-8" } });
+}
+
+function concat(chunks) {
+  const out = new Uint8Array(chunks.reduce((n, c) => n + c.length, 0));
+  let offset = 0;
+  for (const c of chunks) {
+    out.set(c, offset);
+    offset += c.length;
+  }
+  return out;
+}
+
+// WASI 只实现无文件系统的最小子集：stdio、args、environ、时钟、随机数与退出，其余调用返回 ENOSYS
+class WASI {
+  constructor(args, env, stdin) {
+    this.args = args.map((a) => encoder.encode(a + "\0"));
+    this.env = Object.entries(env).map(([k, v]) => encoder.encode(k + "=" + v + "\0"));
+    this.stdin = stdin;
+    this.stdinOffset = 0;
+    this.stdout = [];
+    this.stderr = [];
+    this.memory = null;
+  }
+
+  view() { return new DataView(this.memory.buffer); }
+  bytes() { return new Uint8Array(this.memory.buffer); }
+
+  // writeStrings 写入 argv/environ 指针数组与字符串内容
+  writeStrings(list, ptrs, buf) {
+    const view = this.view(), bytes = this.bytes();
+    for (const item of list) {
+      view.setUint32(ptrs, buf, true);
+      bytes.set(item, buf);
+      ptrs += 4;
+      buf += item.length;
+    }
+    return ESUCCESS;
+  }
+
+  writeSizes(list, countPtr, sizePtr) {
+    const view = this.view();
+    view.setUint32(countPtr, list.length, true);
+    view.setUint32(sizePtr, list.reduce((n, item) => n + item.length, 0), true);
+    return ESUCCESS;
+  }
+
+  iovecs(iovs, len) {
+    const view = this.view(), out = [];
+    for (let i = 0; i < len; i++) {
+      out.push([view.getUint32(iovs + i * 8, true), view.getUint32(iovs + i * 8 + 4, true)]);
+    }
+    return out;
+  }
+
+  imports() {
+    const wasi = {
+      args_sizes_get: (c, s) => this.writeSizes(this.args, c, s),
+      args_get: (p, b) => this.writeStrings(this.args, p, b),
+      environ_sizes_get: (c, s) => this.writeSizes(this.env, c, s),
+      environ_get: (p, b) => this.writeStrings(this.env, p, b),
+      fd_write: (fd, iovs, len, nwritten) => {
+        const sink = fd === 1 ? this.stdout : fd === 2 ? this.stderr : null;
+        if (!sink) return EBADF;
+        let n = 0;
+        for (const [ptr, size] of this.iovecs(iovs, len)) {
+          sink.push(this.bytes().slice(ptr, ptr + size));
+          n += size;
+        }
+        this.view().setUint32(nwritten, n, true);
+        return ESUCCESS;
+      },
+      fd_read: (fd, iovs, len, nread) => {
+        if (fd !== 0) return EBADF;
+        let n = 0;
+        for (const [ptr, size] of this.iovecs(iovs, len)) {
+          const chunk = this.stdin.subarray(this.stdinOffset, this.stdinOffset + size);
+          this.bytes().set(chunk, ptr);
+          this.stdinOffset += chunk.length;
+          n += chunk.length;
+          if (chunk.length < size) break;
+        }
+        this.view().setUint32(nread, n, true);
+        return ESUCCESS;
+      },
+      fd_fdstat_get: (fd, ptr) => {
+        if (fd > 2) return EBADF;
+        const view = this.view();
+        view.setUint8(ptr, 2); // character_device
+        view.setUint16(ptr + 2, 0, true);
+        view.setBigUint64(ptr + 8, 0xffffffffffffffffn, true);
+        view.setBigUint64(ptr + 16, 0xffffffffffffffffn, true);
+        return ESUCCESS;
+      },
+      fd_fdstat_set_flags: (fd) => (fd > 2 ? EBADF : ESUCCESS),
+      fd_close: (fd) => (fd > 2 ? EBADF : ESUCCESS),
+      fd_seek: (fd) => (fd > 2 ? EBADF : ESPIPE),
+      fd_prestat_get: () => EBADF, // 没有预打开的目录
+      fd_prestat_dir_name: () => EBADF,
+      clock_res_get: (id, ptr) => {
+        this.view().setBigUint64(ptr, 1000000n, true);
+        return ESUCCESS;
+      },
+      clock_time_get: (id, precision, ptr) => {
+        const now = id === 0 ? BigInt(Date.now()) * 1000000n : BigInt(Math.round(performance.now() * 1e6));
+        this.view().setBigUint64(ptr, now, true);
+        return ESUCCESS;
+      },
+      random_get: (ptr, len) => {
+        for (let i = 0; i < len; i += 65536) {
+          crypto.getRandomValues(this.bytes().subarray(ptr + i, ptr + Math.min(len, i + 65536)));
+        }
+        return ESUCCESS;
+      },
+      sched_yield: () => ESUCCESS,
+      proc_exit: (code) => {
+        throw new ProcExit(code);
+      },
+      poll_oneoff: () => ENOSYS,
+    };
+    return new Proxy(wasi, {
+      get: (target, name) => (name in target ? target[name] : () => ENOSYS),
+    });
+  }
+}
+`
@@ -0,0 +1,179 @@
+// Package archive 解压部署上传的 zip / tar.gz 包，限制大小并防止路径穿越
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Limits 解压限制
+type Limits struct {
+	MaxFiles     int   // 最多文件数
+	MaxFileSize  int64 // 单个文件解压后的最大字节数
+	MaxTotalSize int64 // 所有文件解压后的总字节数
+}
+
+// DefaultLimits 默认解压限制
+var DefaultLimits = Limits{
+	MaxFiles:     1000,
+	MaxFileSize:  10 << 20,
+	MaxTotalSize: 50 << 20,
+}
+
+// ErrUnsupportedFormat 既不是 zip 也不是 tar / tar.gz
+var ErrUnsupportedFormat = errors.New("unsupported archive format, expected zip or tar.gz")
+
+// Extract 解压归档，返回 相对路径 -> 文件内容（按内容识别格式）
+// 目录、__MACOSX 等元数据被忽略；符号链接、硬链接、设备文件等非普通文件直接拒绝
+func Extract(data []byte, limits Limits) (map[string][]byte, error) {
+	e := &extractor{limits: limits, files: make(map[string][]byte)}
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		err = e.zip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			err = e.tar(gz)
+		}
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		err = e.tar(bytes.NewReader(data))
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(e.files) == 0 {
+		return nil, errors.New("archive contains no files")
+	}
+	return stripCommonDir(e.files), nil
+}
+
+type extractor struct {
+	limits Limits
+	files  map[string][]byte
+	total  int64
+}
+
+func (e *extractor) zip(data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("read zip: %w", err)
+	}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		if mode.IsDir() {
+			continue
+		}
+		if !mode.IsRegular() {
+			return fmt.Errorf("%s: only regular files are allowed", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("open %s: %w", f.Name, err)
+		}
+		err = e.add(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+			if err := e.add(hdr.Name, tr); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: only regular files are allowed", hdr.Name)
+		}
+	}
+}
+
+// add 校验路径与大小后记录文件（大小以实际读取为准，不信任头部声明）
+func (e *extractor) add(name string, r io.Reader) error {
+	clean, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+	if ignored(clean) {
+		return nil
+	}
+	if _, exists := e.files[clean]; exists {
+		return fmt.Errorf("%s: duplicate entry", clean)
+	}
+	if len(e.files) >= e.limits.MaxFiles {
+		return fmt.Errorf("archive contains more than %d files", e.limits.MaxFiles)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, e.limits.MaxFileSize+1))
+	if err != nil {
+		return fmt.Errorf("read %s: %w", clean, err)
+	}
+	if int64(len(content)) > e.limits.MaxFileSize {
+		return fmt.Errorf("%s: file exceeds %d bytes", clean, e.limits.MaxFileSize)
+	}
+	e.total += int64(len(content))
+	if e.total > e.limits.MaxTotalSize {
+		return fmt.Errorf("archive exceeds %d bytes when unpacked", e.limits.MaxTotalSize)
+	}
+	e.files[clean] = content
+	return nil
+}
+
+// CleanPath 规范化归档内路径，拒绝绝对路径与 .. 穿越
+func CleanPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.ContainsAny(name, ":\"\x00") {
+		return "", fmt.Errorf("%q: invalid path", name)
+	}
+	clean := path.Clean(name)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%q: path escapes archive root", name)
+	}
+	return clean, nil
+}
+
+// ignored 打包工具附带的元数据文件
+func ignored(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || path.Base(name) == ".DS_Store"
+}
+
+// stripCommonDir 所有文件位于同一个顶层目录时（如 myfunc/index.js）去掉该目录
+func stripCommonDir(files map[string][]byte) map[string][]byte {
+	var prefix string
+	for name := range files {
+		dir, _, found := strings.Cut(name, "/")
+		if !found || (prefix != "" && dir != prefix) {
+			return files
+		}
+		prefix = dir
+	}
+	stripped := make(map[string][]byte, len(files))
+	for name, content := range files {
+		stripped[strings.TrimPrefix(name, prefix+"/")] = content
+	}
+	return stripped
+}
@@ -0,0 +1,202 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// entry 测试归档中的一项，link 非空时为指向 link 的符号链接
+type entry struct {
+	name string
+	body string
+	link string
+}
+
+func buildZip(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		if e.link != "" {
+			hdr.SetMode(fs.ModeSymlink | 0777)
+			body = e.link
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTarGz(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.link != "" {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.link == "" {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	limits := Limits{MaxFiles: 3, MaxFileSize: 16, MaxTotalSize: 24}
+	tests := []struct {
+		name    string
+		entries []entry
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "strips common top-level dir",
+			entries: []entry{{name: "fn/index.js", body: "main"}, {name: "fn/lib/util.js", body: "util"}},
+			want:    map[string]string{"index.js": "main", "lib/util.js": "util"},
+		},
+		{
+			name:    "ignores metadata files",
+			entries: []entry{{name: "index.js", body: "main"}, {name: "__MACOSX/._index.js"}, {name: ".DS_Store"}},
+			want:    map[string]string{"index.js": "main"},
+		},
+		{
+			name:    "rejects parent traversal",
+			entries: []entry{{name: "../evil.js", body: "x"}},
+			wantErr: "escapes archive root",
+		},
+		{
+			name:    "rejects nested traversal",
+			entries: []entry{{name: "lib/../../evil.js", body: "x"}},
+			wantErr: "escapes archive root",
+		},
+		{
+			name:    "rejects absolute path",
+			entries: []entry{{name: "/etc/passwd", body: "x"}},
+			wantErr: "invalid path",
+		},
+		{
+			name:    "rejects windows drive path",
+			entries: []entry{{name: `C:\evil.js`, body: "x"}},
+			wantErr: "invalid path",
+		},
+		{
+			name:    "rejects symlinks",
+			entries: []entry{{name: "index.js", body: "main"}, {name: "passwd", link: "/etc/passwd"}},
+			wantErr: "only regular files are allowed",
+		},
+		{
+			name:    "rejects oversized file",
+			entries: []entry{{name: "big.js", body: strings.Repeat("x", 17)}},
+			wantErr: "file exceeds 16 bytes",
+		},
+		{
+			name:    "rejects oversized total",
+			entries: []entry{{name: "a.js", body: strings.Repeat("a", 16)}, {name: "b.js", body: strings.Repeat("b", 16)}},
+			wantErr: "exceeds 24 bytes when unpacked",
+		},
+		{
+			name:    "rejects too many files",
+			entries: []entry{{name: "a.js"}, {name: "b.js"}, {name: "c.js"}, {name: "d.js"}},
+			wantErr: "more than 3 files",
+		},
+		{
+			name:    "rejects duplicate entries",
+			entries: []entry{{name: "index.js", body: "a"}, {name: "./index.js", body: "b"}},
+			wantErr: "duplicate entry",
+		},
+	}
+
+	formats := map[string]func(*testing.T, []entry) []byte{"zip": buildZip, "tar.gz": buildTarGz}
+	for format, build := range formats {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				files, err := Extract(build(t, tt.entries), limits)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("err = %v, want %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("extract: %v", err)
+				}
+				if len(files) != len(tt.want) {
+					t.Fatalf("files = %v, want %v", files, tt.want)
+				}
+				for name, body := range tt.want {
+					if string(files[name]) != body {
+						t.Fatalf("%s = %q, want %q", name, files[name], body)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestExtractUnsupportedFormat(t *testing.T) {
+	if _, err := Extract([]byte("plain text"), DefaultLimits); err != ErrUnsupportedFormat {
+		t.Fatalf("err = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "index.js", want: "index.js"},
+		{name: "./lib//util.js", want: "lib/util.js"},
+		{name: `lib\util.js`, want: "lib/util.js"},
+		{name: "lib/../index.js", want: "index.js"},
+		{name: "", wantErr: true},
+		{name: ".", wantErr: true},
+		{name: "..", wantErr: true},
+		{name: "../index.js", wantErr: true},
+		{name: `..\index.js`, wantErr: true},
+		{name: "/index.js", wantErr: true},
+		{name: `\index.js`, wantErr: true},
+		{name: "index\x00.js", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := CleanPath(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("CleanPath(%q) = %q, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("CleanPath(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
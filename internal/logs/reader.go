@@ -0,0 +1,60 @@
+package logs
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+)
+
+// Line 一行日志
+type Line struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"line"`
+}
+
+// Read 读取日志（含历史文件），返回 since 之后的最后 limit 行；limit<=0 表示不限
+func Read(path string, since time.Time, limit int) ([]Line, error) {
+	var lines []Line
+	files := append(Backups(path), path)
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := parseLine(scanner.Text())
+			if !since.IsZero() && line.Time.Before(since) {
+				continue
+			}
+			lines = append(lines, line)
+			if limit > 0 && len(lines) > 2*limit {
+				lines = append(lines[:0], lines[len(lines)-limit:]...)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limit > 0 && len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return lines, nil
+}
+
+// parseLine 解析 "时间戳 内容" 格式，无法解析时间戳的行保留原文
+func parseLine(raw string) Line {
+	if idx := strings.IndexByte(raw, ' '); idx > 0 {
+		if t, err := time.Parse(TimeLayout, raw[:idx]); err == nil {
+			return Line{Time: t, Text: raw[idx+1:]}
+		}
+	}
+	return Line{Text: raw}
+}
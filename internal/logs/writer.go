@@ -0,0 +1,213 @@
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeLayout 日志行时间戳格式
+const TimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// Options 日志轮转配置
+type Options struct {
+	MaxSize    int64         // 单个文件最大字节数，超过后轮转
+	MaxBackups int           // 最多保留的历史文件数
+	MaxAge     time.Duration // 历史文件最长保留时间（0 表示不限）
+}
+
+// DefaultOptions 默认轮转配置（可在启动时覆盖）
+var DefaultOptions = Options{
+	MaxSize:    10 << 20,
+	MaxBackups: 5,
+	MaxAge:     7 * 24 * time.Hour,
+}
+
+// Writer 按行写入带时间戳的日志，支持按大小轮转和实时订阅
+type Writer struct {
+	mu      sync.Mutex
+	path    string
+	opts    Options
+	file    *os.File
+	size    int64
+	pending []byte                 // 未以换行结尾的残留内容
+	subs    map[chan Line]struct{} // 实时订阅者
+	now     func() time.Time
+}
+
+// NewWriter 创建日志写入器，文件在首次写入时打开
+func NewWriter(path string, opts Options) *Writer {
+	return &Writer{
+		path: path,
+		opts: opts,
+		subs: make(map[chan Line]struct{}),
+		now:  time.Now,
+	}
+}
+
+// Path 当前日志文件路径
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write 实现 io.Writer，按行加时间戳写入文件并推送给订阅者
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data := append(w.pending, p...)
+	w.pending = nil
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			break
+		}
+		if err := w.writeLineLocked(string(data[:idx])); err != nil {
+			return 0, err
+		}
+		data = data[idx+1:]
+	}
+	if len(data) > 0 {
+		w.pending = append([]byte(nil), data...)
+	}
+	return len(p), nil
+}
+
+func (w *Writer) writeLineLocked(text string) error {
+	line := Line{Time: w.now().UTC(), Text: text}
+	formatted := line.Time.Format(TimeLayout) + " " + text + "\n"
+
+	if w.file == nil {
+		if err := w.openLocked(); err != nil {
+			return err
+		}
+	}
+	if w.opts.MaxSize > 0 && w.size+int64(len(formatted)) > w.opts.MaxSize && w.size > 0 {
+		if err := w.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.WriteString(formatted)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write log: %w", err)
+	}
+
+	for ch := range w.subs {
+		select {
+		case ch <- line:
+		default: // 订阅者消费过慢时丢弃，避免阻塞 workerd 输出
+		}
+	}
+	return nil
+}
+
+func (w *Writer) openLocked() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("create log dir: %w", err)
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat log: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// rotateLocked 将当前文件重命名为 path.<时间戳>，并清理过期历史文件
+func (w *Writer) rotateLocked() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("close log: %w", err)
+	}
+	w.file = nil
+	backup := fmt.Sprintf("%s.%s", w.path, w.now().UTC().Format("20060102T150405.000000"))
+	if err := os.Rename(w.path, backup); err != nil {
+		return fmt.Errorf("rotate log: %w", err)
+	}
+	w.pruneLocked()
+	return w.openLocked()
+}
+
+// pruneLocked 按数量和时间清理历史文件
+func (w *Writer) pruneLocked() {
+	backups := Backups(w.path)
+	cutoff := w.now().Add(-w.opts.MaxAge)
+	for i, backup := range backups {
+		tooMany := w.opts.MaxBackups > 0 && len(backups)-i > w.opts.MaxBackups
+		tooOld := false
+		if w.opts.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && info.ModTime().Before(cutoff) {
+				tooOld = true
+			}
+		}
+		if tooMany || tooOld {
+			os.Remove(backup)
+		}
+	}
+}
+
+// Subscribe 订阅新写入的日志行，返回的函数用于取消订阅
+func (w *Writer) Subscribe() (<-chan Line, func()) {
+	ch := make(chan Line, 256)
+	w.mu.Lock()
+	w.subs[ch] = struct{}{}
+	w.mu.Unlock()
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		// Close 可能已经关闭并移除了该订阅，只有仍在 subs 中时才关闭
+		if _, ok := w.subs[ch]; ok {
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close 写出残留内容并关闭文件
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) > 0 {
+		text := string(w.pending)
+		w.pending = nil
+		if err := w.writeLineLocked(text); err != nil {
+			return err
+		}
+	}
+	for ch := range w.subs {
+		delete(w.subs, ch)
+		close(ch)
+	}
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Backups 返回日志文件的历史文件，按从旧到新排序
+func Backups(path string) []string {
+	matches, _ := filepath.Glob(path + ".*")
+	var backups []string
+	for _, m := range matches {
+		if strings.HasPrefix(filepath.Base(m), filepath.Base(path)+".") {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups) // 时间戳后缀保证字典序即时间序
+	return backups
+}
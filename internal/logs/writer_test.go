@@ -0,0 +1,91 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClock 每次调用前进一秒，保证轮转文件名互不相同
+func fakeClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func TestWriterRotatesAndPrunesByCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fn.log")
+	w := NewWriter(path, Options{MaxSize: 64, MaxBackups: 2})
+	w.now = fakeClock(time.Now())
+	defer w.Close()
+
+	for i := 0; i < 20; i++ {
+		if _, err := fmt.Fprintf(w, "line %02d\n", i); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	if backups := Backups(path); len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2 files", backups)
+	}
+	lines, err := Read(path, time.Time{}, 1)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(lines) != 1 || lines[0].Text != "line 19" {
+		t.Fatalf("last line = %+v, want line 19", lines)
+	}
+}
+
+func TestWriterPrunesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fn.log")
+	stale := path + ".20000101T000000.000000"
+	if err := os.WriteFile(stale, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWriter(path, Options{MaxSize: 16, MaxAge: time.Hour})
+	w.now = fakeClock(time.Now())
+	defer w.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("stale backup still present (err=%v)", err)
+	}
+	if backups := Backups(path); len(backups) == 0 {
+		t.Fatal("fresh backups were pruned")
+	}
+}
+
+func TestWriterCloseWhileSubscribed(t *testing.T) {
+	w := NewWriter(filepath.Join(t.TempDir(), "fn.log"), DefaultOptions)
+	ch, cancel := w.Subscribe()
+
+	if _, err := w.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if line := <-ch; line.Text != "hello" {
+		t.Fatalf("line = %q, want hello", line.Text)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("channel still open after Close")
+	}
+	cancel() // 订阅者随后取消，不能重复关闭通道
+	cancel()
+}
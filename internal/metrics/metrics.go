@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// 路由转发请求数
+	proxyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_proxy_requests_total",
+		Help: "Total number of requests proxied to functions.",
+	}, []string{"function", "version", "alias", "code"})
+
+	// 路由转发耗时
+	proxyDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "faas_proxy_request_duration_seconds",
+		Help:    "Latency of proxied requests, including wake-up time.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"function", "version", "alias"})
+
+	// 冷启动次数
+	coldStarts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_cold_starts_total",
+		Help: "Total number of workerd process starts.",
+	}, []string{"function", "version", "result"})
+
+	// 冷启动耗时
+	coldStartDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "faas_cold_start_duration_seconds",
+		Help:    "Time from spawning workerd until its port is listening.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 3, 5, 10},
+	}, []string{"function", "version"})
+
+	// 超时挂起次数
+	suspends = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_suspends_total",
+		Help: "Total number of versions suspended due to inactivity.",
+	}, []string{"function", "version"})
+
+	// 内存超限被结束次数
+	oomKills = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_oom_kills_total",
+		Help: "Total number of instances killed for exceeding their memory limit.",
+	}, []string{"function", "version"})
+
+	// 被出站规则拒绝的请求数
+	egressBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_egress_blocked_total",
+		Help: "Total number of outbound requests blocked by egress policies.",
+	}, []string{"function", "version"})
+
+	// 控制面 API 调用次数
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_api_requests_total",
+		Help: "Total number of control-plane API calls.",
+	}, []string{"method", "route", "code"})
+)
+
+// ObserveProxyRequest 记录一次转发请求
+func ObserveProxyRequest(function, version, alias string, code int, elapsed time.Duration) {
+	proxyRequests.WithLabelValues(function, version, alias, strconv.Itoa(code)).Inc()
+	proxyDuration.WithLabelValues(function, version, alias).Observe(elapsed.Seconds())
+}
+
+// ObserveColdStart 记录一次 workerd 启动
+func ObserveColdStart(function, version string, elapsed time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	coldStarts.WithLabelValues(function, version, result).Inc()
+	if err == nil {
+		coldStartDuration.WithLabelValues(function, version).Observe(elapsed.Seconds())
+	}
+}
+
+// IncSuspend 记录一次超时挂起
+func IncSuspend(function, version string) {
+	suspends.WithLabelValues(function, version).Inc()
+}
+
+// IncOOMKill 记录一次内存超限被结束
+func IncOOMKill(function, version string) {
+	oomKills.WithLabelValues(function, version).Inc()
+}
+
+// IncEgressBlocked 记录一次被出站规则拒绝的请求
+func IncEgressBlocked(function, version string) {
+	egressBlocked.WithLabelValues(function, version).Inc()
+}
+
+// instanceCollector 按状态统计实例数量（采集时实时读取注册表）
+type instanceCollector struct {
+	desc  *prometheus.Desc
+	count func() map[string]int
+}
+
+func (c *instanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *instanceCollector) Collect(ch chan<- prometheus.Metric) {
+	for status, n := range c.count() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), status)
+	}
+}
+
+// RegisterInstances 注册实例数量采集器，count 返回 状态 -> 实例数
+func RegisterInstances(count func() map[string]int) {
+	prometheus.MustRegister(&instanceCollector{
+		desc: prometheus.NewDesc("faas_instances",
+			"Number of function versions by process status.", []string{"status"}, nil),
+		count: count,
+	})
+}
+
+// GinMiddleware 统计控制面 API 调用
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		apiRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+// Handler Prometheus 文本格式输出（GET /metrics）
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bucket 令牌桶
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	rate     float64
+	burst    int
+	lastUsed time.Time
+}
+
+// Result 限流判断结果
+type Result struct {
+	Allowed    bool          // 是否放行
+	Limit      int           // 桶容量
+	Remaining  int           // 剩余令牌数
+	RetryAfter time.Duration // 被拒绝时建议的重试间隔
+}
+
+// Limiter 令牌桶限流器，按 规则key + 客户端key 维护独立的桶
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	idleTTL time.Duration // 空闲桶回收时间
+	now     func() time.Time
+}
+
+// New 创建限流器
+func New() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		idleTTL: 10 * time.Minute,
+		now:     time.Now,
+	}
+}
+
+// Allow 消耗一个令牌；rate 为每秒补充的令牌数，burst 为桶容量
+func (l *Limiter) Allow(ruleKey, clientKey string, rate float64, burst int) Result {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+		if burst <= 0 {
+			burst = 1
+		}
+	}
+	now := l.now()
+	key := ruleKey + "|" + clientKey
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok || b.rate != rate || b.burst != burst {
+		// 新桶或规则已变更：重新装满
+		b = &bucket{tokens: float64(burst), last: now, rate: rate, burst: burst}
+		l.buckets[key] = b
+	} else {
+		elapsed := now.Sub(b.last).Seconds()
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed*rate)
+		b.last = now
+	}
+	b.lastUsed = now
+
+	res := Result{Limit: burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else if rate > 0 {
+		res.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+
+	if len(l.buckets) > 10000 {
+		l.sweepLocked(now)
+	}
+	return res
+}
+
+// Reset 清除某条规则下的所有桶（规则变更或删除时调用）
+func (l *Limiter) Reset(ruleKey string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prefix := ruleKey + "|"
+	for k := range l.buckets {
+		if strings.HasPrefix(k, prefix) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// 回收长时间未使用的桶，避免客户端 key 无限增长
+func (l *Limiter) sweepLocked(now time.Time) {
+	for k, b := range l.buckets {
+		if now.Sub(b.lastUsed) > l.idleTTL {
+			delete(l.buckets, k)
+		}
+	}
+}
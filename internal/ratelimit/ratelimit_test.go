@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter() (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New()
+	l.now = func() time.Time { return clock.now }
+	return l, clock
+}
+
+func TestAllowBurstAndRefill(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	// 桶初始装满：连续 burst 个请求放行，剩余令牌依次减少
+	for i := 0; i < 3; i++ {
+		res := l.Allow("fn:", "ip:1", 2, 3)
+		if !res.Allowed || res.Limit != 3 || res.Remaining != 2-i {
+			t.Fatalf("request %d: %+v", i, res)
+		}
+	}
+	res := l.Allow("fn:", "ip:1", 2, 3)
+	if res.Allowed || res.Remaining != 0 || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("over burst: %+v", res)
+	}
+
+	// 每秒补充 rate 个令牌，不超过 burst
+	clock.advance(500 * time.Millisecond)
+	if res := l.Allow("fn:", "ip:1", 2, 3); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after 500ms: %+v", res)
+	}
+	clock.advance(time.Hour)
+	if res := l.Allow("fn:", "ip:1", 2, 3); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("after refill: %+v", res)
+	}
+}
+
+func TestAllowKeys(t *testing.T) {
+	l, _ := newTestLimiter()
+	if res := l.Allow("fn:", "ip:1", 1, 1); !res.Allowed {
+		t.Fatalf("first request: %+v", res)
+	}
+	if res := l.Allow("fn:", "ip:1", 1, 1); res.Allowed {
+		t.Fatalf("second request: %+v", res)
+	}
+
+	// 不同客户端、不同规则各自独立
+	if res := l.Allow("fn:", "ip:2", 1, 1); !res.Allowed {
+		t.Fatalf("other client: %+v", res)
+	}
+	if res := l.Allow("fn:stable", "ip:1", 1, 1); !res.Allowed {
+		t.Fatalf("other rule: %+v", res)
+	}
+
+	// 规则参数变化或 Reset 后重新装满
+	if res := l.Allow("fn:", "ip:1", 1, 2); !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("changed rule: %+v", res)
+	}
+	l.Allow("fn:", "ip:1", 1, 2)
+	l.Reset("fn:")
+	if res := l.Allow("fn:", "ip:1", 1, 2); !res.Allowed {
+		t.Fatalf("after reset: %+v", res)
+	}
+	if res := l.Allow("fn:stable", "ip:1", 1, 1); res.Allowed {
+		t.Fatalf("reset cleared another rule: %+v", res)
+	}
+}
+
+func TestAllowDefaultBurst(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want int
+	}{
+		{rate: 2.5, want: 3},
+		{rate: 0.1, want: 1},
+	}
+	for _, tt := range tests {
+		l, _ := newTestLimiter()
+		if res := l.Allow("fn:", "ip:1", tt.rate, 0); res.Limit != tt.want {
+			t.Errorf("rate %g: limit %d, want %d", tt.rate, res.Limit, tt.want)
+		}
+	}
+
+	// rate 为 0 时不补充令牌，也不给出重试间隔
+	l, clock := newTestLimiter()
+	l.Allow("fn:", "ip:1", 0, 1)
+	clock.advance(time.Hour)
+	if res := l.Allow("fn:", "ip:1", 0, 1); res.Allowed || res.RetryAfter != 0 {
+		t.Fatalf("zero rate: %+v", res)
+	}
+}
+
+func TestSweepIdleBuckets(t *testing.T) {
+	l, clock := newTestLimiter()
+	l.Allow("fn:", "ip:idle", 1, 1)
+	clock.advance(l.idleTTL + time.Second)
+	l.sweepLocked(clock.now)
+	if _, ok := l.buckets["fn:|ip:idle"]; ok {
+		t.Fatal("idle bucket was not swept")
+	}
+}
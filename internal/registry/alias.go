@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// FunctionAlias 通过别名接口设置的别名（持久化，重启后覆盖部署时记录的别名）
+type FunctionAlias struct {
+	FuncName  string    `gorm:"primaryKey" json:"func_name"`
+	Alias     string    `gorm:"primaryKey" json:"alias"`
+	Version   string    `gorm:"not null" json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Aliases 返回函数的全部别名（别名 -> 版本），包含 latest
+func (r *Registry) Aliases(funcName string) map[string]string {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+
+	aliases := make(map[string]string)
+	prefix := funcName + ":"
+	for aliasKey, version := range r.aliasMap {
+		if strings.HasPrefix(aliasKey, prefix) {
+			aliases[strings.TrimPrefix(aliasKey, prefix)] = version
+		}
+	}
+	return aliases
+}
+
+// SetAlias 将别名指向指定版本（不影响 latest）
+func (r *Registry) SetAlias(funcName, alias, version string) error {
+	if alias == "latest" {
+		return invalidf("alias latest is managed by deploy and rollback")
+	}
+
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+
+	versionKey := fmt.Sprintf("%s:%s", funcName, version)
+	if _, exists := r.VersionMap[versionKey]; !exists {
+		return ErrVersionNotFound
+	}
+	record := &FunctionAlias{FuncName: funcName, Alias: alias, Version: version}
+	if err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(record).Error; err != nil {
+		return fmt.Errorf("save alias: %w", err)
+	}
+	r.aliasMap[fmt.Sprintf("%s:%s", funcName, alias)] = version
+	r.subdomainMap[r.generateAliasSubdomain(funcName, alias)] = versionKey
+	return nil
+}
+
+// DeleteAlias 删除别名（latest 不可删除）
+func (r *Registry) DeleteAlias(funcName, alias string) error {
+	if alias == "latest" {
+		return invalidf("alias latest cannot be deleted")
+	}
+
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+
+	aliasKey := fmt.Sprintf("%s:%s", funcName, alias)
+	version, exists := r.aliasMap[aliasKey]
+	if !exists {
+		return ErrAliasNotFound
+	}
+	delete(r.aliasMap, aliasKey)
+	delete(r.subdomainMap, r.generateAliasSubdomain(funcName, alias))
+	if err := r.db.Delete(&FunctionAlias{FuncName: funcName, Alias: alias}).Error; err != nil {
+		return fmt.Errorf("delete alias: %w", err)
+	}
+
+	// 清除版本上记录的别名，避免重启后恢复
+	if meta, ok := r.VersionMap[fmt.Sprintf("%s:%s", funcName, version)]; ok && meta.Alias == alias {
+		meta.Alias = ""
+		if err := r.db.Model(meta).UpdateColumn("alias", "").Error; err != nil {
+			return fmt.Errorf("save to db: %w", err)
+		}
+	}
+	return nil
+}
+
+// 从数据库恢复别名（调用方持有锁），指向已不存在版本的别名会被忽略
+func (r *Registry) loadAliasesLocked() error {
+	var records []FunctionAlias
+	if err := r.db.Find(&records).Error; err != nil {
+		return fmt.Errorf("load aliases: %w", err)
+	}
+	for _, record := range records {
+		versionKey := fmt.Sprintf("%s:%s", record.FuncName, record.Version)
+		if _, exists := r.VersionMap[versionKey]; !exists {
+			continue
+		}
+		r.aliasMap[fmt.Sprintf("%s:%s", record.FuncName, record.Alias)] = record.Version
+		r.subdomainMap[r.generateAliasSubdomain(record.FuncName, record.Alias)] = versionKey
+	}
+	return nil
+}
+
+// 删除函数时清理其别名记录（调用方持有锁）
+func (r *Registry) deleteAliasesLocked(funcName string) error {
+	if err := r.db.Where("func_name = ?", funcName).Delete(&FunctionAlias{}).Error; err != nil {
+		return fmt.Errorf("delete aliases: %w", err)
+	}
+	return nil
+}
+
+// 删除版本时清理指向该版本的全部别名及其记录（调用方持有锁）
+func (r *Registry) deleteVersionAliasesLocked(funcName, version string) error {
+	prefix := funcName + ":"
+	for aliasKey, v := range r.aliasMap {
+		if v == version && strings.HasPrefix(aliasKey, prefix) {
+			delete(r.subdomainMap, r.generateAliasSubdomain(funcName, strings.TrimPrefix(aliasKey, prefix)))
+			delete(r.aliasMap, aliasKey)
+		}
+	}
+	if err := r.db.Where("func_name = ? AND version = ?", funcName, version).Delete(&FunctionAlias{}).Error; err != nil {
+		return fmt.Errorf("delete aliases: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// 代码与模块按 SHA-256 存放在 blobs/<前两位>/<hash>，版本只记录 hash，相同内容只存一份
+
+// blobHash 计算内容的 SHA-256
+func blobHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Registry) blobDir() string {
+	return filepath.Join(r.StorageDir, "blobs")
+}
+
+func (r *Registry) blobPath(hash string) string {
+	return filepath.Join(r.blobDir(), hash[:2], hash)
+}
+
+// putBlob 写入内容并返回 hash（已存在则跳过；先写临时文件再重命名，避免读到半个文件）
+func (r *Registry) putBlob(data []byte) (string, error) {
+	hash := blobHash(data)
+	target := r.blobPath(hash)
+	if _, err := os.Stat(target); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("create blob dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), hash+".tmp*")
+	if err != nil {
+		return "", fmt.Errorf("create blob: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("write blob: %w", err)
+	}
+	return hash, nil
+}
+
+func (r *Registry) getBlob(hash string) ([]byte, error) {
+	data, err := os.ReadFile(r.blobPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("read blob %s: %w", hash, err)
+	}
+	return data, nil
+}
+
+// linkBlob 将 blob 硬链接到版本目录（跨文件系统等失败时复制内容）
+func (r *Registry) linkBlob(hash string, data []byte, target string) error {
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if hash != "" && os.Link(r.blobPath(hash), target) == nil {
+		return nil
+	}
+	return os.WriteFile(target, data, 0644)
+}
+
+// hashContent 计算代码、模块、源文件与 WebAssembly 二进制的 hash（不写入存储）
+func hashContent(meta *FunctionMetadata) {
+	meta.CodeHash = blobHash([]byte(meta.Code))
+	meta.ModuleHashes = hashSet(meta.Modules)
+	meta.SourceHashes = hashSet(meta.Sources)
+	meta.WasmHash = ""
+	if len(meta.Wasm) > 0 {
+		meta.WasmHash = blobHash(meta.Wasm)
+	}
+}
+
+func hashSet(files ModuleSet) JSONMap {
+	if len(files) == 0 {
+		return nil
+	}
+	hashes := make(JSONMap, len(files))
+	for name, content := range files {
+		hashes[name] = blobHash(content)
+	}
+	return hashes
+}
+
+// storeContent 将代码、模块、源文件与 WebAssembly 二进制写入 blob 存储并记录 hash
+func (r *Registry) storeContent(meta *FunctionMetadata) error {
+	hashContent(meta)
+	if _, err := r.putBlob([]byte(meta.Code)); err != nil {
+		return err
+	}
+	if len(meta.Wasm) > 0 {
+		if _, err := r.putBlob(meta.Wasm); err != nil {
+			return err
+		}
+	}
+	for _, files := range []ModuleSet{meta.Modules, meta.Sources} {
+		for _, content := range files {
+			if _, err := r.putBlob(content); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadContent 按 hash 从 blob 存储加载代码、模块、源文件与 WebAssembly 二进制
+func (r *Registry) loadContent(meta *FunctionMetadata) error {
+	code, err := r.getBlob(meta.CodeHash)
+	if err != nil {
+		return err
+	}
+	meta.Code = string(code)
+	if meta.WasmHash != "" {
+		if meta.Wasm, err = r.getBlob(meta.WasmHash); err != nil {
+			return err
+		}
+	}
+	if meta.Modules, err = r.loadSet(meta.ModuleHashes); err != nil {
+		return err
+	}
+	meta.Sources, err = r.loadSet(meta.SourceHashes)
+	return err
+}
+
+func (r *Registry) loadSet(hashes JSONMap) (ModuleSet, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	files := make(ModuleSet, len(hashes))
+	for name, hash := range hashes {
+		content, err := r.getBlob(hash)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+// FindIdentical 查找代码与配置（运行时、入口、模块、源文件、WebAssembly 二进制、环境变量）完全相同的已有版本，
+// 有多个时返回最近更新的一个
+func (r *Registry) FindIdentical(meta *FunctionMetadata) (*FunctionMetadata, bool) {
+	hashContent(meta)
+
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+	var found *FunctionMetadata
+	for _, existing := range r.VersionMap {
+		if existing.Name != meta.Name || !sameContent(existing, meta) {
+			continue
+		}
+		if found == nil || existing.UpdatedAt.After(found.UpdatedAt) {
+			found = existing
+		}
+	}
+	return found, found != nil
+}
+
+func sameContent(a, b *FunctionMetadata) bool {
+	return a.Runtime == b.Runtime &&
+		a.Entry == b.Entry &&
+		a.CodeHash == b.CodeHash &&
+		maps.Equal(a.ModuleHashes, b.ModuleHashes) &&
+		a.SourceEntry == b.SourceEntry &&
+		maps.Equal(a.SourceHashes, b.SourceHashes) &&
+		a.WasmHash == b.WasmHash &&
+		maps.Equal(a.EnvVars, b.EnvVars) &&
+		a.Resources == b.Resources &&
+		a.Sandbox == b.Sandbox &&
+		a.Egress.Equal(b.Egress) &&
+		slices.Equal(a.Services, b.Services) &&
+		a.DurableObjects.Equal(b.DurableObjects) &&
+		a.Database == b.Database
+}
+
+// GCResult 垃圾回收结果
+type GCResult struct {
+	Removed    int   `json:"removed"`
+	FreedBytes int64 `json:"freedBytes"`
+}
+
+// GCBlobs 删除没有被任何版本引用的 blob（删除函数/版本时会自动执行）
+func (r *Registry) GCBlobs() (GCResult, error) {
+	// 持有写锁，避免与部署（先写 blob 后写库）交错
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+	return r.gcBlobsLocked()
+}
+
+func (r *Registry) gcBlobsLocked() (GCResult, error) {
+	var metas []*FunctionMetadata
+	if err := r.db.Unscoped().Select("code_hash", "module_hashes", "source_hashes", "wasm_hash").Find(&metas).Error; err != nil {
+		return GCResult{}, fmt.Errorf("load references: %w", err)
+	}
+	for _, meta := range r.pending { // 正在冒烟测试的版本尚未写入数据库
+		metas = append(metas, meta)
+	}
+	referenced := make(map[string]bool)
+	for _, meta := range metas {
+		referenced[meta.CodeHash] = true
+		referenced[meta.WasmHash] = true
+		for _, hash := range meta.ModuleHashes {
+			referenced[hash] = true
+		}
+		for _, hash := range meta.SourceHashes {
+			referenced[hash] = true
+		}
+	}
+
+	var result GCResult
+	err := filepath.WalkDir(r.blobDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || referenced[d.Name()] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		result.Removed++
+		result.FreedBytes += info.Size()
+		return nil
+	})
+	return result, err
+}
+
+// migrateInlineContent 将旧版本直接存放在 code / modules 列中的内容迁移到 blob 存储，并删除旧列
+func (r *Registry) migrateInlineContent() error {
+	migrator := r.db.Migrator()
+	for _, column := range []string{"code", "modules"} {
+		if !migrator.HasColumn(&FunctionMetadata{}, column) {
+			continue
+		}
+		rows, err := r.db.Model(&FunctionMetadata{}).Unscoped().Select("id", column).Rows()
+		if err != nil {
+			return fmt.Errorf("read %s column: %w", column, err)
+		}
+		inline := make(map[uint][]byte)
+		for rows.Next() {
+			var id uint
+			var value []byte
+			if err := rows.Scan(&id, &value); err != nil {
+				rows.Close()
+				return fmt.Errorf("read %s column: %w", column, err)
+			}
+			inline[id] = value
+		}
+		rows.Close()
+
+		for id, value := range inline {
+			var update map[string]any
+			if column == "code" {
+				hash, err := r.putBlob(value)
+				if err != nil {
+					return err
+				}
+				update = map[string]any{"code_hash": hash}
+			} else if len(value) > 0 {
+				var modules ModuleSet
+				if err := json.Unmarshal(value, &modules); err != nil {
+					return fmt.Errorf("decode modules of %d: %w", id, err)
+				}
+				hashes := make(JSONMap, len(modules))
+				for name, content := range modules {
+					if hashes[name], err = r.putBlob(content); err != nil {
+						return err
+					}
+				}
+				update = map[string]any{"module_hashes": hashes}
+			}
+			if update == nil {
+				continue
+			}
+			// UpdateColumns 不更新 UpdatedAt，latest 的判定不受影响
+			if err := r.db.Model(&FunctionMetadata{}).Unscoped().Where("id = ?", id).UpdateColumns(update).Error; err != nil {
+				return fmt.Errorf("migrate %s of %d: %w", column, id, err)
+			}
+		}
+		if err := migrator.DropColumn(&FunctionMetadata{}, column); err != nil {
+			return fmt.Errorf("drop %s column: %w", column, err)
+		}
+	}
+	return nil
+}
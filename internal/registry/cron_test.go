@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	// 2026-03-02 是周一
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		expr  string
+		match []time.Time
+		miss  []time.Time
+	}{
+		{expr: "* * * * *", match: []time.Time{at(2, 0, 0), at(31, 23, 59)}},
+		{expr: "*/15 9-17 * * *", match: []time.Time{at(2, 9, 0), at(2, 17, 45)}, miss: []time.Time{at(2, 9, 10), at(2, 18, 0)}},
+		{expr: "5,10 0 * * *", match: []time.Time{at(2, 0, 5), at(2, 0, 10)}, miss: []time.Time{at(2, 0, 6)}},
+		{expr: "10/20 * * * *", match: []time.Time{at(2, 1, 10), at(2, 1, 50)}, miss: []time.Time{at(2, 1, 0)}},
+		{expr: "0 0 * mar mon-fri", match: []time.Time{at(2, 0, 0), at(6, 0, 0)}, miss: []time.Time{at(7, 0, 0)}},
+		{expr: "0 0 * * 7", match: []time.Time{at(1, 0, 0)}, miss: []time.Time{at(2, 0, 0)}},
+		// 日与周都受限时满足其一即可
+		{expr: "0 0 15 * 1", match: []time.Time{at(2, 0, 0), at(15, 0, 0)}, miss: []time.Time{at(3, 0, 0)}},
+		{expr: "@daily", match: []time.Time{at(3, 0, 0)}, miss: []time.Time{at(3, 0, 1)}},
+		{expr: "@HOURLY", match: []time.Time{at(3, 7, 0)}, miss: []time.Time{at(3, 7, 30)}},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		for _, m := range tt.match {
+			if !spec.matches(m) {
+				t.Errorf("%q does not match %s", tt.expr, m)
+			}
+		}
+		for _, m := range tt.miss {
+			if spec.matches(m) {
+				t.Errorf("%q matches %s", tt.expr, m)
+			}
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@reboot"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded", expr)
+		}
+	}
+}
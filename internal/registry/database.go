@@ -0,0 +1,626 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DatabaseConfig 部署时申请的函数私有 SQLite 数据库。数据库保存在 StorageDir/.database/<函数>/ 下，
+// 所有版本共用；worker 通过以 Binding 为名的 fetch 绑定访问，由平台的内部监听执行语句
+type DatabaseConfig struct {
+	Binding string `json:"binding,omitempty"`
+}
+
+// IsZero 是否未申请数据库
+func (c DatabaseConfig) IsZero() bool {
+	return c.Binding == ""
+}
+
+// Statement 一条 SQL 语句，Params 依次绑定 ? 占位符（字符串、数字、布尔或 null）
+type Statement struct {
+	SQL    string `json:"sql" binding:"required"`
+	Params []any  `json:"params,omitempty"`
+}
+
+// StatementResult 语句的执行结果。RowsAffected 为语句修改的行数，LastInsertID 为连接最近插入的 rowid
+type StatementResult struct {
+	Columns      []string `json:"columns"`
+	Rows         [][]any  `json:"rows"`
+	RowsAffected int64    `json:"rows_affected"`
+	LastInsertID int64    `json:"last_insert_id"`
+}
+
+// DatabaseMigration 一次迁移，按 Name 记录在数据库的 _faas_migrations 表中，已执行的迁移不再执行
+type DatabaseMigration struct {
+	Name string `json:"name" binding:"required"`
+	SQL  string `json:"sql" binding:"required"`
+}
+
+// AppliedMigration 已执行的迁移
+type AppliedMigration struct {
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// QueryError SQL 语句执行失败（语法错误、约束冲突、参数不合法等）
+type QueryError struct {
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return e.Message
+}
+
+// 数据库限制
+const (
+	maxQueryRows          = 10000     // 单条语句返回的最大行数
+	maxDatabaseRequest    = 8 << 20   // 绑定请求体的最大字节数
+	MaxDatabaseImportSize = 256 << 20 // 导入的数据库文件的最大字节数
+)
+
+// databaseServiceName 生成的 workerd 配置中数据库绑定对应的服务名
+const databaseServiceName = "faas-database"
+
+// databaseBindingHeader workerd 在数据库绑定请求中注入的绑定名
+const databaseBindingHeader = "X-Faas-Database"
+
+// sqliteHeader SQLite 数据库文件的前 16 字节
+const sqliteHeader = "SQLite format 3\x00"
+
+// functionDatabase 打开的函数数据库
+type functionDatabase struct {
+	mu sync.RWMutex // 执行语句时持有读锁，关闭（导入、删除函数）时持有写锁
+	db *sql.DB      // 关闭后为 nil
+}
+
+// databaseDir 函数数据库所在目录（所有版本共用），位于版本目录之外
+func (r *Registry) databaseDir(funcName string) string {
+	return filepath.Join(r.StorageDir, ".database", funcName)
+}
+
+// migrateDatabaseDirs 把旧布局下 <函数>/.database 中的数据库移到 databaseDir
+func (r *Registry) migrateDatabaseDirs() error {
+	legacy, err := filepath.Glob(filepath.Join(r.StorageDir, "*", ".database"))
+	if err != nil {
+		return err
+	}
+	for _, old := range legacy {
+		target := r.databaseDir(filepath.Base(filepath.Dir(old)))
+		if old == target {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(old, target); err != nil {
+			return fmt.Errorf("move %s: %w", old, err)
+		}
+	}
+	return nil
+}
+
+// databasePath 函数数据库文件
+func (r *Registry) databasePath(funcName string) string {
+	return filepath.Join(r.databaseDir(funcName), "db.sqlite")
+}
+
+// checkDatabase 检查版本申请的数据库：绑定名不能与其他绑定同名；exec 后端没有 workerd 绑定
+func checkDatabase(meta *FunctionMetadata) error {
+	binding := meta.Database.Binding
+	if binding == "" {
+		return nil
+	}
+	if meta.Runtime == ExecRuntimeName {
+		return invalidf("database binding is not supported by the %s runtime", ExecRuntimeName)
+	}
+	if !bindingNamePattern.MatchString(binding) {
+		return invalidf("invalid database binding name %q", binding)
+	}
+	_, exists := meta.EnvVars[binding]
+	if exists || binding == WasmModuleBinding || binding == WasmEnvBinding ||
+		slices.ContainsFunc(meta.Services, func(b ServiceBinding) bool { return b.Name == binding }) ||
+		slices.ContainsFunc(meta.DurableObjects.Classes, func(c DurableObjectClass) bool { return c.Binding == binding }) {
+		return invalidf("database binding %q conflicts with another binding", binding)
+	}
+	return nil
+}
+
+// databaseBinding 返回数据库绑定的 worker 绑定项，以及指向内部监听的 external 服务
+func (r *Registry) databaseBinding(meta *FunctionMetadata) (binding, service string, err error) {
+	if meta.Database.IsZero() {
+		return "", "", nil
+	}
+	service, err = r.internal.externalService(meta, databaseServiceName, "host", [2]string{databaseBindingHeader, meta.Database.Binding})
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf(`( name = "%s", service = "%s" )`, meta.Database.Binding, databaseServiceName), ",\n" + service, nil
+}
+
+// hasDatabase 函数存在且有版本申请了数据库
+func (r *Registry) hasDatabase(funcName string) error {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+	if r.Latest[funcName] == nil {
+		return ErrFunctionNotFound
+	}
+	for _, meta := range r.VersionMap {
+		if meta.Name == funcName && !meta.Database.IsZero() {
+			return nil
+		}
+	}
+	return ErrDatabaseNotFound
+}
+
+// openSQLite 打开 SQLite 数据库：WAL 模式，事务以 BEGIN IMMEDIATE 开始，锁冲突时等待
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, err
+	}
+	return db.DB()
+}
+
+// withDatabase 在函数的数据库上执行 fn，数据库不存在时创建
+func (r *Registry) withDatabase(funcName string, fn func(db *sql.DB) error) error {
+	r.databaseMu.Lock()
+	d := r.databases[funcName]
+	if d == nil {
+		if err := os.MkdirAll(r.databaseDir(funcName), 0755); err != nil {
+			r.databaseMu.Unlock()
+			return fmt.Errorf("create database dir: %w", err)
+		}
+		db, err := openSQLite(r.databasePath(funcName))
+		if err != nil {
+			r.databaseMu.Unlock()
+			return fmt.Errorf("open database: %w", err)
+		}
+		d = &functionDatabase{db: db}
+		r.databases[funcName] = d
+	}
+	r.databaseMu.Unlock()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.db == nil { // 已被导入或删除函数关闭
+		return ErrDatabaseNotFound
+	}
+	return fn(d.db)
+}
+
+// closeDatabaseLocked 等待执行中的语句结束后关闭函数的数据库（调用方持有 databaseMu）
+func (r *Registry) closeDatabaseLocked(funcName string) {
+	d := r.databases[funcName]
+	if d == nil {
+		return
+	}
+	delete(r.databases, funcName)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.db.Close(); err != nil {
+		fmt.Printf("failed to close database of %s: %v\n", funcName, err)
+	}
+	d.db = nil
+}
+
+// removeDatabase 关闭并删除函数的数据库
+func (r *Registry) removeDatabase(funcName string) {
+	r.databaseMu.Lock()
+	defer r.databaseMu.Unlock()
+	r.closeDatabaseLocked(funcName)
+	if err := os.RemoveAll(r.databaseDir(funcName)); err != nil {
+		fmt.Printf("failed to remove database of %s: %v\n", funcName, err)
+	}
+}
+
+// closeDatabases 关闭所有打开的数据库
+func (r *Registry) closeDatabases() {
+	r.databaseMu.Lock()
+	defer r.databaseMu.Unlock()
+	for name := range r.databases {
+		r.closeDatabaseLocked(name)
+	}
+}
+
+// querier *sql.Conn 与 *sql.Tx 共有的方法，语句与 total_changes() 需在同一连接上执行
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// statementParams 检查并转换参数：JSON 中的整数按整数绑定，不接受数组与对象
+func statementParams(params []any) ([]any, error) {
+	args := make([]any, len(params))
+	for i, p := range params {
+		switch v := p.(type) {
+		case nil, string, bool:
+			args[i] = v
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				args[i] = int64(v)
+			} else {
+				args[i] = v
+			}
+		default:
+			return nil, &QueryError{Message: fmt.Sprintf("param %d: unsupported type %T", i+1, p)}
+		}
+	}
+	return args, nil
+}
+
+// runStatement 执行一条语句并返回结果集，修改的行数由执行前后连接的 total_changes() 得到
+func runStatement(ctx context.Context, q querier, stmt Statement) (*StatementResult, error) {
+	args, err := statementParams(stmt.Params)
+	if err != nil {
+		return nil, err
+	}
+	var before int64
+	if err := q.QueryRowContext(ctx, "SELECT total_changes()").Scan(&before); err != nil {
+		return nil, err
+	}
+	rows, err := q.QueryContext(ctx, stmt.SQL, args...)
+	if err != nil {
+		return nil, &QueryError{Message: err.Error()}
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, &QueryError{Message: err.Error()}
+	}
+	result := &StatementResult{Columns: columns, Rows: [][]any{}}
+	for rows.Next() {
+		if len(result.Rows) == maxQueryRows {
+			return nil, &QueryError{Message: fmt.Sprintf("statement returned more than %d rows", maxQueryRows)}
+		}
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, &QueryError{Message: err.Error()}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &QueryError{Message: err.Error()}
+	}
+	rows.Close()
+
+	var after int64
+	if err := q.QueryRowContext(ctx, "SELECT total_changes(), last_insert_rowid()").Scan(&after, &result.LastInsertID); err != nil {
+		return nil, err
+	}
+	result.RowsAffected = after - before
+	return result, nil
+}
+
+// queryDatabase 在一个连接上执行一条语句
+func (r *Registry) queryDatabase(ctx context.Context, funcName string, stmt Statement) (result *StatementResult, err error) {
+	err = r.withDatabase(funcName, func(db *sql.DB) error {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		result, err = runStatement(ctx, conn, stmt)
+		return err
+	})
+	return result, err
+}
+
+// execDatabase 执行一段 SQL（可以包含多条以分号分隔的语句），不返回结果集
+func (r *Registry) execDatabase(ctx context.Context, funcName string, stmt Statement) (result *StatementResult, err error) {
+	args, err := statementParams(stmt.Params)
+	if err != nil {
+		return nil, err
+	}
+	err = r.withDatabase(funcName, func(db *sql.DB) error {
+		res, err := db.ExecContext(ctx, stmt.SQL, args...)
+		if err != nil {
+			return &QueryError{Message: err.Error()}
+		}
+		result = &StatementResult{Columns: []string{}, Rows: [][]any{}}
+		result.RowsAffected, _ = res.RowsAffected()
+		result.LastInsertID, _ = res.LastInsertId()
+		return nil
+	})
+	return result, err
+}
+
+// batchDatabase 在一个事务中依次执行语句，任一条失败时回滚全部
+func (r *Registry) batchDatabase(ctx context.Context, funcName string, stmts []Statement) (results []*StatementResult, err error) {
+	results = make([]*StatementResult, 0, len(stmts))
+	err = r.withDatabase(funcName, func(db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for i, stmt := range stmts {
+			result, err := runStatement(ctx, tx, stmt)
+			if err != nil {
+				var queryErr *QueryError
+				if errors.As(err, &queryErr) {
+					return &QueryError{Message: fmt.Sprintf("statement %d: %s", i+1, queryErr.Message)}
+				}
+				return err
+			}
+			results = append(results, result)
+		}
+		return tx.Commit()
+	})
+	return results, err
+}
+
+// QueryDatabase 在函数的数据库上执行一条语句（管理接口）
+func (r *Registry) QueryDatabase(ctx context.Context, funcName string, stmt Statement) (*StatementResult, error) {
+	if err := r.hasDatabase(funcName); err != nil {
+		return nil, err
+	}
+	return r.queryDatabase(ctx, funcName, stmt)
+}
+
+const migrationsTable = `CREATE TABLE IF NOT EXISTS _faas_migrations (name TEXT PRIMARY KEY, applied_at TEXT NOT NULL)`
+
+// MigrateDatabase 按顺序执行尚未执行过的迁移，每个迁移在单独的事务中执行并记录。
+// 返回本次执行的迁移名；某个迁移失败时停止，之前的迁移保留
+func (r *Registry) MigrateDatabase(ctx context.Context, funcName string, migrations []DatabaseMigration) (applied []string, err error) {
+	if err := r.hasDatabase(funcName); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(migrations))
+	for _, m := range migrations {
+		if m.Name == "" || seen[m.Name] {
+			return nil, invalidf("migration names must be non-empty and unique: %q", m.Name)
+		}
+		seen[m.Name] = true
+	}
+	applied = []string{}
+	err = r.withDatabase(funcName, func(db *sql.DB) error {
+		if _, err := db.ExecContext(ctx, migrationsTable); err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			done, err := applyMigration(ctx, db, m)
+			if err != nil {
+				return err
+			}
+			if done {
+				applied = append(applied, m.Name)
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		fmt.Printf("applied %d migrations to database of %s\n", len(applied), funcName)
+	}
+	return applied, err
+}
+
+// applyMigration 在事务中执行迁移并记录，已执行过时返回 false
+func applyMigration(ctx context.Context, db *sql.DB, m DatabaseMigration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM _faas_migrations WHERE name = ?", m.Name).Scan(&count); err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return false, &QueryError{Message: fmt.Sprintf("migration %s: %v", m.Name, err)}
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO _faas_migrations (name, applied_at) VALUES (?, ?)", m.Name, time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DatabaseMigrations 列出已执行的迁移（按执行顺序）
+func (r *Registry) DatabaseMigrations(ctx context.Context, funcName string) ([]AppliedMigration, error) {
+	if err := r.hasDatabase(funcName); err != nil {
+		return nil, err
+	}
+	migrations := []AppliedMigration{}
+	err := r.withDatabase(funcName, func(db *sql.DB) error {
+		if _, err := db.ExecContext(ctx, migrationsTable); err != nil {
+			return err
+		}
+		rows, err := db.QueryContext(ctx, "SELECT name, applied_at FROM _faas_migrations ORDER BY applied_at, rowid")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var m AppliedMigration
+			var at string
+			if err := rows.Scan(&m.Name, &at); err != nil {
+				return err
+			}
+			m.AppliedAt, _ = time.Parse(time.RFC3339Nano, at)
+			migrations = append(migrations, m)
+		}
+		return rows.Err()
+	})
+	return migrations, err
+}
+
+// ExportDatabase 把函数数据库的一致快照（VACUUM INTO）写入 w。快照在数据库锁内生成，
+// 写出时已释放锁，下载缓慢的客户端不会阻塞导入与删除
+func (r *Registry) ExportDatabase(ctx context.Context, funcName string, w io.Writer) error {
+	if err := r.hasDatabase(funcName); err != nil {
+		return err
+	}
+	var path string
+	err := r.withDatabase(funcName, func(db *sql.DB) error {
+		f, err := os.CreateTemp(r.databaseDir(funcName), "export-*.sqlite")
+		if err != nil {
+			return fmt.Errorf("create export file: %w", err)
+		}
+		path = f.Name()
+		f.Close()
+		os.Remove(path) // VACUUM INTO 要求目标文件不存在
+		if _, err := db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+			return fmt.Errorf("snapshot database: %w", err)
+		}
+		return nil
+	})
+	if path != "" {
+		defer os.Remove(path)
+	}
+	if err != nil {
+		return err
+	}
+	snapshot, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+	_, err = io.Copy(w, snapshot)
+	return err
+}
+
+// ImportDatabase 用 src 中的 SQLite 数据库文件替换函数的数据库。文件通过完整性检查后才替换，
+// 执行中的语句结束后关闭原数据库
+func (r *Registry) ImportDatabase(funcName string, src io.Reader) error {
+	if err := r.hasDatabase(funcName); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.databaseDir(funcName), 0755); err != nil {
+		return fmt.Errorf("create database dir: %w", err)
+	}
+	f, err := os.CreateTemp(r.databaseDir(funcName), "import-*.sqlite")
+	if err != nil {
+		return fmt.Errorf("create import file: %w", err)
+	}
+	path := f.Name()
+	defer os.Remove(path)
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write import file: %w", err)
+	}
+	if err := checkSQLiteFile(path); err != nil {
+		return err
+	}
+
+	r.databaseMu.Lock()
+	defer r.databaseMu.Unlock()
+	r.closeDatabaseLocked(funcName)
+	target := r.databasePath(funcName)
+	os.Remove(target + "-wal")
+	os.Remove(target + "-shm")
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("replace database: %w", err)
+	}
+	fmt.Printf("imported database of %s\n", funcName)
+	return nil
+}
+
+// checkSQLiteFile 检查文件是完整的 SQLite 数据库
+func checkSQLiteFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(sqliteHeader))
+	_, err = io.ReadFull(f, header)
+	f.Close()
+	if err != nil || !bytes.Equal(header, []byte(sqliteHeader)) {
+		return invalidf("imported file is not a SQLite database")
+	}
+	db, err := openSQLite(path)
+	if err != nil {
+		return invalidf("open imported database: %v", err)
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil || result != "ok" {
+		return invalidf("imported database failed the integrity check: %s%v", result, err)
+	}
+	return nil
+}
+
+// serveDatabase 数据库绑定：POST /query 执行一条语句并返回结果集，POST /exec 执行一段 SQL，
+// POST /batch 在一个事务中执行 {"statements": [...]}。语句错误返回 400 {"error": "..."}
+func (l *internalListener) serveDatabase(w http.ResponseWriter, req *http.Request, c *caller, binding string) {
+	if binding != c.database {
+		http.Error(w, fmt.Sprintf("database binding %q not found", binding), http.StatusNotFound)
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxDatabaseRequest))
+	var result any
+	var err error
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	switch path {
+	case "/query", "/exec":
+		var stmt Statement
+		if err = decoder.Decode(&stmt); err == nil {
+			if path == "/exec" {
+				result, err = l.reg.execDatabase(req.Context(), c.name, stmt)
+			} else {
+				result, err = l.reg.queryDatabase(req.Context(), c.name, stmt)
+			}
+		}
+	case "/batch":
+		var batch struct {
+			Statements []Statement `json:"statements"`
+		}
+		if err = decoder.Decode(&batch); err == nil {
+			var results []*StatementResult
+			results, err = l.reg.batchDatabase(req.Context(), c.name, batch.Statements)
+			result = map[string]any{"results": results}
+		}
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	var queryErr *QueryError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		json.NewEncoder(w).Encode(result)
+		return
+	case errors.As(err, &tooLarge):
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	case errors.As(err, &queryErr), errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, io.EOF):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
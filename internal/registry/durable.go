@@ -0,0 +1,211 @@
+package registry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DurableObjectConfig 部署时声明的 Durable Object 类及迁移。类的数据按函数保存在
+// StorageDir/<函数>/.durable/<Key>/ 下，升级版本、挂起唤醒与重启后保留
+type DurableObjectConfig struct {
+	Classes []DurableObjectClass `json:"classes,omitempty" binding:"omitempty,dive"`
+	Deleted []string             `json:"deleted,omitempty"` // 迁移：删除 latest 中的这些类及其数据
+}
+
+// DurableObjectClass 一个 Durable Object 类：入口模块导出的 ClassName，以 Binding 为名的命名空间绑定注入 env
+type DurableObjectClass struct {
+	Binding     string `json:"binding" binding:"required"`
+	ClassName   string `json:"class_name" binding:"required"`
+	RenamedFrom string `json:"renamed_from,omitempty"` // 迁移：沿用 latest 中该类的数据
+	Key         string `json:"key,omitempty"`          // 数据目录（workerd uniqueKey），由平台分配，改名后不变
+}
+
+// IsZero 是否未声明 Durable Object
+func (c DurableObjectConfig) IsZero() bool {
+	return len(c.Classes) == 0 && len(c.Deleted) == 0
+}
+
+// Equal 两个声明是否相同（不比较平台分配的 Key）
+func (c DurableObjectConfig) Equal(other DurableObjectConfig) bool {
+	return slices.Equal(c.Deleted, other.Deleted) && slices.EqualFunc(c.Classes, other.Classes, func(a, b DurableObjectClass) bool {
+		return a.Binding == b.Binding && a.ClassName == b.ClassName && a.RenamedFrom == b.RenamedFrom
+	})
+}
+
+// class 按类名查找
+func (c DurableObjectConfig) class(name string) (DurableObjectClass, bool) {
+	for _, class := range c.Classes {
+		if class.ClassName == name {
+			return class, true
+		}
+	}
+	return DurableObjectClass{}, false
+}
+
+// durableServiceName 生成的 workerd 配置中 Durable Object 存储（disk 服务）的名称
+const durableServiceName = "faas-durable"
+
+// durableDir 函数的 Durable Object 存储目录（所有版本共用）。放在版本目录（<函数>/<版本>）之外，
+// 删除任何版本都不会波及对象数据
+func (r *Registry) durableDir(funcName string) string {
+	return filepath.Join(r.StorageDir, ".durable", funcName)
+}
+
+// migrateDurableDirs 把旧布局下 <函数>/.durable 中的对象数据移到 durableDir
+func (r *Registry) migrateDurableDirs() error {
+	legacy, err := filepath.Glob(filepath.Join(r.StorageDir, "*", ".durable"))
+	if err != nil {
+		return err
+	}
+	for _, old := range legacy {
+		target := r.durableDir(filepath.Base(filepath.Dir(old)))
+		if old == target {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(old, target); err != nil {
+			return fmt.Errorf("move %s: %w", old, err)
+		}
+	}
+	return nil
+}
+
+// resolveDurableObjectsLocked 检查新版本的 Durable Object 声明并按 latest 分配数据目录（调用方持有锁）：
+// latest 中已有的类与 renamed_from 指向的类沿用原来的 Key，新类分配新的 Key；
+// latest 中的类在新版本中消失时，必须在 deleted 中声明（数据随之删除）或通过 renamed_from 改名
+func (r *Registry) resolveDurableObjectsLocked(meta *FunctionMetadata) error {
+	do := &meta.DurableObjects
+	if do.IsZero() {
+		do.Classes, do.Deleted = nil, nil
+	}
+	do.Classes = slices.Clone(do.Classes) // Key 由平台填写，不修改调用方（如沿用 latest 的声明）的切片
+	var previous DurableObjectConfig
+	if latest := r.Latest[meta.Name]; latest != nil {
+		previous = latest.DurableObjects
+	}
+	if do.IsZero() && len(previous.Classes) == 0 {
+		return nil
+	}
+	if len(do.Classes) > 0 && meta.Runtime != "js" && meta.Runtime != "ts" {
+		return invalidf("durable objects are only supported by the js and ts runtimes")
+	}
+
+	bindings := make(map[string]bool)
+	classes := make(map[string]bool)
+	inherited := make(map[string]bool) // 被新版本沿用的旧类
+	for i := range do.Classes {
+		class := &do.Classes[i]
+		if !bindingNamePattern.MatchString(class.Binding) || !bindingNamePattern.MatchString(class.ClassName) {
+			return invalidf("invalid durable object binding %q or class %q", class.Binding, class.ClassName)
+		}
+		if bindings[class.Binding] || classes[class.ClassName] {
+			return invalidf("duplicate durable object binding %q or class %q", class.Binding, class.ClassName)
+		}
+		bindings[class.Binding], classes[class.ClassName] = true, true
+		if _, exists := meta.EnvVars[class.Binding]; exists || slices.ContainsFunc(meta.Services, func(b ServiceBinding) bool { return b.Name == class.Binding }) {
+			return invalidf("durable object binding %q conflicts with another binding", class.Binding)
+		}
+
+		// 已有的类沿用数据（renamed_from 已在之前的版本生效）；否则按 renamed_from 改名
+		class.Key = ""
+		if old, ok := previous.class(class.ClassName); ok {
+			class.Key = old.Key
+			inherited[old.ClassName] = true
+		} else if class.RenamedFrom != "" {
+			old, ok := previous.class(class.RenamedFrom)
+			if !ok {
+				return invalidf("durable object class %q renamed from %q, which is not in the latest version", class.ClassName, class.RenamedFrom)
+			}
+			if inherited[old.ClassName] {
+				return invalidf("durable object class %q is renamed more than once", old.ClassName)
+			}
+			class.Key = old.Key
+			inherited[old.ClassName] = true
+		}
+		if class.Key == "" {
+			suffix := make([]byte, 4)
+			if _, err := rand.Read(suffix); err != nil {
+				return fmt.Errorf("generate durable object key: %w", err)
+			}
+			class.Key = fmt.Sprintf("%s-%s", class.ClassName, hex.EncodeToString(suffix))
+		}
+	}
+	for _, name := range do.Deleted {
+		if classes[name] {
+			return invalidf("durable object class %q is both declared and deleted", name)
+		}
+	}
+	for _, old := range previous.Classes {
+		if !inherited[old.ClassName] && !slices.Contains(do.Deleted, old.ClassName) {
+			return invalidf("durable object class %q was removed; list it in durable_objects.deleted to delete its data, or set renamed_from to keep it", old.ClassName)
+		}
+	}
+	return nil
+}
+
+// pruneDurableLocked 删除没有被函数的任何版本（包括正在冒烟测试的版本）引用的 Durable Object 数据（调用方持有锁）
+func (r *Registry) pruneDurableLocked(funcName string) {
+	entries, err := os.ReadDir(r.durableDir(funcName))
+	if err != nil {
+		return
+	}
+	referenced := make(map[string]bool)
+	for _, versions := range []map[string]*FunctionMetadata{r.VersionMap, r.pending} {
+		for _, meta := range versions {
+			if meta.Name != funcName {
+				continue
+			}
+			for _, class := range meta.DurableObjects.Classes {
+				referenced[class.Key] = true
+			}
+		}
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !referenced[entry.Name()] {
+			fmt.Printf("removing durable object data %s of %s\n", entry.Name(), funcName)
+			if err := os.RemoveAll(filepath.Join(r.durableDir(funcName), entry.Name())); err != nil {
+				fmt.Printf("failed to remove durable object data: %v\n", err)
+			}
+		}
+	}
+}
+
+// durableObjectConfig 返回 worker 的命名空间绑定、durableObjectNamespaces / durableObjectStorage 设置与 disk 服务
+func (r *Registry) durableObjectConfig(meta *FunctionMetadata) (bindings, worker, service string, err error) {
+	classes := meta.DurableObjects.Classes
+	if len(classes) == 0 {
+		return "", "", "", nil
+	}
+	dir, err := filepath.Abs(r.durableDir(meta.Name))
+	if err != nil {
+		return "", "", "", fmt.Errorf("resolve durable object dir: %w", err)
+	}
+	var items, namespaces []string
+	for _, class := range classes {
+		if class.Key == "" {
+			return "", "", "", errors.New("durable object class " + class.ClassName + " has no storage key")
+		}
+		if err := os.MkdirAll(filepath.Join(dir, class.Key), 0755); err != nil {
+			return "", "", "", fmt.Errorf("create durable object dir: %w", err)
+		}
+		items = append(items, fmt.Sprintf(`( name = "%s", durableObjectNamespace = "%s" )`, class.Binding, class.ClassName))
+		namespaces = append(namespaces, fmt.Sprintf(`( className = "%s", uniqueKey = "%s" )`, class.ClassName, class.Key))
+	}
+	worker = fmt.Sprintf(`
+        durableObjectNamespaces = [ %s ],
+        durableObjectStorage = ( localDisk = "%s" ),`, strings.Join(namespaces, ", "), durableServiceName)
+	service = fmt.Sprintf(`,
+    (
+      name = "%s",
+      disk = ( path = "%s", writable = true )
+    )`, durableServiceName, dir)
+	return strings.Join(items, ",\n"), worker, service, nil
+}
@@ -0,0 +1,244 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"faas/internal/metrics"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 出站规则模式
+const (
+	EgressAllow = "allow" // 白名单：只允许匹配规则的目标
+	EgressDeny  = "deny"  // 黑名单：禁止匹配规则的目标
+	EgressNone  = "none"  // 禁止所有出站请求
+)
+
+// EgressPolicy 函数发起 fetch 的出站规则，Mode 为空表示不限制（workerd 直接访问网络）。
+// 声明后 workerd 的所有出站请求都经由平台的出站代理，由代理按规则放行或拒绝
+type EgressPolicy struct {
+	Mode  string       `json:"mode,omitempty" binding:"omitempty,oneof=allow deny none"`
+	Rules []EgressRule `json:"rules,omitempty" binding:"omitempty,dive"`
+}
+
+// EgressRule 一条出站规则：Host 为域名（*.example.com 匹配所有子域名）、IP 或 CIDR，Ports 为空时匹配所有端口
+type EgressRule struct {
+	Host  string `json:"host" binding:"required"`
+	Ports []int  `json:"ports,omitempty" binding:"omitempty,dive,min=1,max=65535"`
+}
+
+// IsZero 是否未声明出站规则
+func (p EgressPolicy) IsZero() bool {
+	return p.Mode == "" && len(p.Rules) == 0
+}
+
+// Equal 两个出站规则是否相同
+func (p EgressPolicy) Equal(other EgressPolicy) bool {
+	return p.Mode == other.Mode && slices.EqualFunc(p.Rules, other.Rules, func(a, b EgressRule) bool {
+		return a.Host == b.Host && slices.Equal(a.Ports, b.Ports)
+	})
+}
+
+// allows 是否允许连接 host（请求中的主机名）解析出的 ip 的 port 端口
+func (p EgressPolicy) allows(host string, ip net.IP, port int) bool {
+	switch p.Mode {
+	case EgressNone:
+		return false
+	case EgressAllow:
+		return p.match(host, ip, port)
+	case EgressDeny:
+		return !p.match(host, ip, port)
+	}
+	return true
+}
+
+func (p EgressPolicy) match(host string, ip net.IP, port int) bool {
+	for _, rule := range p.Rules {
+		if rule.matches(host, ip, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 域名规则按请求中的主机名匹配，IP 与 CIDR 规则按实际连接的地址匹配
+func (r EgressRule) matches(host string, ip net.IP, port int) bool {
+	if len(r.Ports) > 0 && !slices.Contains(r.Ports, port) {
+		return false
+	}
+	if _, network, err := net.ParseCIDR(r.Host); err == nil {
+		return network.Contains(ip)
+	}
+	if ruleIP := net.ParseIP(r.Host); ruleIP != nil {
+		return ruleIP.Equal(ip)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if suffix, ok := strings.CutPrefix(strings.ToLower(r.Host), "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == strings.ToLower(r.Host)
+}
+
+// checkEgress 检查版本的出站规则：只有 workerd 后端的出站请求能经由平台代理
+func checkEgress(meta *FunctionMetadata) error {
+	p := meta.Egress
+	if p.IsZero() {
+		return nil
+	}
+	if meta.Runtime == ExecRuntimeName {
+		return invalidf("egress policy is not supported by the %s runtime", ExecRuntimeName)
+	}
+	switch p.Mode {
+	case EgressAllow, EgressDeny:
+	case EgressNone:
+		if len(p.Rules) > 0 {
+			return invalidf("egress mode %s does not take rules", EgressNone)
+		}
+	default:
+		return invalidf("egress mode must be one of %s, %s, %s", EgressAllow, EgressDeny, EgressNone)
+	}
+	for _, rule := range p.Rules {
+		if err := checkEgressHost(rule.Host); err != nil {
+			return err
+		}
+		for _, port := range rule.Ports {
+			if port < 1 || port > 65535 {
+				return invalidf("invalid egress port %d", port)
+			}
+		}
+	}
+	return nil
+}
+
+// checkEgressHost 规则的 Host 必须是 IP、CIDR 或（可带 *. 前缀的）域名，不含协议与端口
+func checkEgressHost(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(host); err == nil {
+		return nil
+	}
+	name := strings.TrimPrefix(host, "*.")
+	if name == "" || len(name) > 253 {
+		return invalidf("invalid egress host %q", host)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return invalidf("invalid egress host %q", host)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return invalidf("invalid egress host %q", host)
+			}
+		}
+	}
+	return nil
+}
+
+// egressServiceName 生成的 workerd 配置中出站代理服务的名称
+const egressServiceName = "faas-egress"
+
+type egressPolicyKey struct{}
+
+// egressBlockedError 出站规则拒绝的连接
+type egressBlockedError struct {
+	host string
+	port int
+}
+
+func (e *egressBlockedError) Error() string {
+	return fmt.Sprintf("egress to %s blocked by policy", net.JoinHostPort(e.host, strconv.Itoa(e.port)))
+}
+
+// newEgressForwarder 出站代理：workerd 以代理方式（请求行为完整 URL，包括 https）把函数发起的请求发到内部监听，
+// 按调用方版本的出站规则检查实际连接的地址，拒绝的请求返回 403 并记录到函数日志
+func (l *internalListener) newEgressForwarder() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {},
+		Transport: &http.Transport{
+			DialContext:         egressDial,
+			DisableKeepAlives:   true, // 连接只在建立时检查规则，不能复用给其他版本
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		ErrorHandler: l.egressFailed,
+	}
+}
+
+// serveEgress 按调用方的出站规则转发请求
+func (l *internalListener) serveEgress(w http.ResponseWriter, req *http.Request, c *caller) {
+	if req.Method == http.MethodConnect || !req.URL.IsAbs() {
+		http.Error(w, "egress: expected a proxy request with an absolute URL", http.StatusBadRequest)
+		return
+	}
+	ctx := context.WithValue(req.Context(), egressPolicyKey{}, c)
+	l.egress.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// egressDial 解析目标地址，只连接规则允许的 IP（按实际连接的地址检查，避免通过 DNS 绕过 CIDR 规则）
+func egressDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	policy := ctx.Value(egressPolicyKey{}).(*caller).egress
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in %s", addr)
+	}
+	var blocked error = &egressBlockedError{host: host, port: port}
+	if policy.Mode == EgressNone {
+		return nil, blocked
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var dialer net.Dialer
+	lastErr := blocked
+	for _, ip := range addrs {
+		if !policy.allows(host, ip.IP, port) {
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), portText))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// egressFailed 被规则拒绝的请求返回 403，记录到函数日志并计数；其他错误返回 502
+func (l *internalListener) egressFailed(w http.ResponseWriter, req *http.Request, err error) {
+	var blocked *egressBlockedError
+	if !errors.As(err, &blocked) {
+		http.Error(w, "egress: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	c := req.Context().Value(egressPolicyKey{}).(*caller)
+	metrics.IncEgressBlocked(c.name, c.version)
+	line := fmt.Sprintf("egress blocked: %s %s (%s policy)", req.Method, req.URL.Redacted(), c.egress.Mode)
+	fmt.Printf("%s:%s %s\n", c.name, c.version, line)
+	fmt.Fprintln(l.reg.LogWriter(c.name, c.version), line)
+	http.Error(w, blocked.Error(), http.StatusForbidden)
+}
+
+// egressService 版本声明了出站规则时，返回 workerd 配置中的出站代理服务与 worker 的 globalOutbound 设置
+func (r *Registry) egressService(meta *FunctionMetadata) (service, outbound string, err error) {
+	if meta.Egress.IsZero() {
+		return "", "", nil
+	}
+	service, err = r.internal.externalService(meta, egressServiceName, "proxy")
+	if err != nil {
+		return "", "", err
+	}
+	outbound = fmt.Sprintf("\n        globalOutbound = \"%s\",", egressServiceName)
+	return ",\n" + service, outbound, nil
+}
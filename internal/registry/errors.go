@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// 注册表错误，API 层据此映射 HTTP 状态码
+var (
+	ErrFunctionNotFound  = errors.New("function not found")
+	ErrVersionNotFound   = errors.New("function version not found")
+	ErrAliasNotFound     = errors.New("alias not found")
+	ErrRateLimitNotFound = errors.New("rate limit not found")
+	ErrVersionExists     = errors.New("function version already exists")
+	ErrAlreadyStopped    = errors.New("function has been stopped")
+	ErrAlreadyRunning    = errors.New("function is already running")
+	ErrDatabaseNotFound  = errors.New("function has no database")
+)
+
+// ValidationError 参数不合法
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// namePattern 函数名与版本号的合法格式。二者用作存储目录名并写入 workerd 配置，
+// 因此不允许路径分隔符、引号与空白；也不允许以 . 开头（与 .durable、.database 等内部目录区分）
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ValidateName 校验函数名或版本号，kind 为错误信息中的名称（如 "function name"）
+func ValidateName(kind, value string) error {
+	if !namePattern.MatchString(value) {
+		return invalidf("invalid %s %q: must start with a letter or digit and contain only letters, digits, '-' and '_'", kind, value)
+	}
+	return nil
+}
+
+// invalidf 构造 ValidationError
+func invalidf(format string, args ...any) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+// 新版本部署失败的阶段
+const (
+	StageStart     = "start"      // 实例启动失败
+	StageSmokeTest = "smoke_test" // 冒烟测试未通过
+)
+
+// StartError 实例启动失败或新版本未通过冒烟测试。能从实例输出中解析出错误位置时，
+// File / Line / Column 为出错的文件（相对版本目录）与位置，Source 为该行源码
+type StartError struct {
+	Stage   string    `json:"stage"`
+	Message string    `json:"message"`
+	File    string    `json:"file,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Column  int       `json:"column,omitempty"`
+	Source  string    `json:"source,omitempty"`
+	Output  string    `json:"output,omitempty"` // 实例的输出（stderr 与 stdout，保留末尾部分）
+	At      time.Time `json:"at"`
+}
+
+func (e *StartError) Error() string {
+	if e.File != "" && e.Line > 0 {
+		return fmt.Sprintf("%s failed: %s:%d: %s", e.Stage, e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s failed: %s", e.Stage, e.Message)
+}
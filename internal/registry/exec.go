@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ExecRuntimeName 本地可执行文件后端的运行时名称
+const ExecRuntimeName = "exec"
+
+// ExecRuntimeEnabled 是否启用本地可执行文件后端（部署的代码直接在宿主机上运行，默认关闭）
+var ExecRuntimeEnabled = false
+
+// ExecRuntime 运行本地可执行文件的后端（Go 二进制、带 shebang 的 Python 脚本等）：
+// 进程通过环境变量 PORT 获得监听端口，自行在 127.0.0.1 上提供 HTTP 服务
+type ExecRuntime struct {
+	reg *Registry
+}
+
+// NewExecRuntime 创建本地可执行文件后端
+func NewExecRuntime(reg *Registry) *ExecRuntime {
+	return &ExecRuntime{reg: reg}
+}
+
+// Prepare 将代码（多文件部署为整个模块集）写入版本目录的 app/ 下，入口文件设为可执行
+func (e *ExecRuntime) Prepare(meta *FunctionMetadata) error {
+	appDir, err := e.appDir(meta)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(appDir); err != nil {
+		return fmt.Errorf("clean app dir: %w", err)
+	}
+
+	files, entry := meta.Modules, meta.Entry
+	if len(files) == 0 {
+		files, entry = ModuleSet{meta.Name: []byte(meta.Code)}, meta.Name
+	}
+	if _, exists := files[entry]; !exists {
+		return fmt.Errorf("entry %q not found", entry)
+	}
+	for name, content := range files {
+		target := filepath.Join(appDir, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, appDir+string(filepath.Separator)) {
+			return fmt.Errorf("invalid file path %q", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("create app dir: %w", err)
+		}
+		// 不使用 blob 硬链接：修改权限会影响共享同一 blob 的其他版本
+		mode := os.FileMode(0644)
+		if name == entry {
+			mode = 0755
+		}
+		if err := os.WriteFile(target, content, mode); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	meta.Workerd.CodePath = filepath.Join(appDir, filepath.FromSlash(entry))
+	meta.Workerd.ConfPath = ""
+	meta.Workerd.LogPath = e.reg.LogPath(meta.Name, meta.Version)
+	return nil
+}
+
+// Start 在 app/ 目录下运行入口文件，环境变量只包含 PATH、函数环境变量与平台提供的 PORT 等
+func (e *ExecRuntime) Start(ctx context.Context, meta *FunctionMetadata) error {
+	appDir, err := e.appDir(meta)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(meta.Workerd.CodePath)
+	cmd.Dir = appDir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	for key, value := range meta.EnvVars {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Env = append(cmd.Env,
+		"HOST=127.0.0.1",
+		"PORT="+strconv.Itoa(meta.Workerd.Port),
+		"FAAS_FUNCTION="+meta.Name,
+		"FAAS_VERSION="+meta.Version,
+	)
+	return e.reg.startProcess(ctx, meta, cmd)
+}
+
+// appDir 版本的程序目录（绝对路径，进程的工作目录会切换到这里）
+func (e *ExecRuntime) appDir(meta *FunctionMetadata) (string, error) {
+	dir, err := filepath.Abs(filepath.Join(e.reg.versionDir(meta.Name, meta.Version), "app"))
+	if err != nil {
+		return "", fmt.Errorf("resolve app dir: %w", err)
+	}
+	return dir, nil
+}
+
+func (e *ExecRuntime) Stop(meta *FunctionMetadata) error {
+	return stopProcess(meta)
+}
+
+func (e *ExecRuntime) Health(meta *FunctionMetadata) error {
+	return processHealth(meta)
+}
+
+func (e *ExecRuntime) Address(meta *FunctionMetadata) string {
+	return processAddress(meta)
+}
@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FunctionSummary 函数概要（列表接口）
+type FunctionSummary struct {
+	Name          string    `json:"name"`
+	LatestVersion string    `json:"latest_version"`
+	Runtime       string    `json:"runtime"`
+	Versions      int       `json:"versions"`
+	Running       int       `json:"running"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// VersionInfo 版本详情
+type VersionInfo struct {
+	Version        string               `json:"version"`
+	Runtime        string               `json:"runtime"`
+	Subdomain      string               `json:"subdomain"`
+	Status         string               `json:"status"`
+	Port           int                  `json:"port"`
+	LastAccessed   time.Time            `json:"last_accessed"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	Aliases        []string             `json:"aliases"`
+	EnvKeys        []string             `json:"env_keys"`
+	CodeSize       int                  `json:"code_size"`
+	CodeSHA256     string               `json:"code_sha256"`
+	SourceEntry    string               `json:"source_entry,omitempty"` // TypeScript 版本的源码入口
+	WasmSize       int                  `json:"wasm_size,omitempty"`    // wasm 版本的 WebAssembly 二进制大小
+	WasmSHA256     string               `json:"wasm_sha256,omitempty"`
+	LastStartError *StartError          `json:"last_start_error,omitempty"` // 最近一次启动失败
+	Resources      ResourceLimits       `json:"resources"`                  // 资源上限（零值表示不限制）
+	OOMKills       int                  `json:"oom_kills,omitempty"`        // 因内存超限被结束的次数
+	LastOOMAt      *time.Time           `json:"last_oom_at,omitempty"`
+	Sandbox        SandboxConfig        `json:"sandbox"`                   // 生效的隔离设置（未声明的字段为全局默认）
+	Egress         EgressPolicy         `json:"egress"`                    // 出站规则（为空表示不限制）
+	Services       []ServiceBinding     `json:"services,omitempty"`        // 服务绑定
+	DurableObjects []DurableObjectClass `json:"durable_objects,omitempty"` // Durable Object 类（Key 为数据目录）
+	Database       DatabaseConfig       `json:"database"`                  // 私有 SQLite 数据库（为空表示未申请）
+}
+
+// FunctionDetail 函数详情
+type FunctionDetail struct {
+	Name          string            `json:"name"`
+	LatestVersion string            `json:"latest_version"`
+	Aliases       map[string]string `json:"aliases"`
+	Versions      []VersionInfo     `json:"versions"`
+	Schedules     []Schedule        `json:"schedules"` // 定时任务
+}
+
+// ListFunctions 列出所有函数概要，按函数名排序
+func (r *Registry) ListFunctions() []FunctionSummary {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+
+	byName := make(map[string]*FunctionSummary)
+	for _, meta := range r.VersionMap {
+		s, exists := byName[meta.Name]
+		if !exists {
+			s = &FunctionSummary{Name: meta.Name, CreatedAt: meta.CreatedAt}
+			byName[meta.Name] = s
+		}
+		s.Versions++
+		if meta.Status == "running" {
+			s.Running++
+		}
+		if meta.CreatedAt.Before(s.CreatedAt) {
+			s.CreatedAt = meta.CreatedAt
+		}
+		if meta.UpdatedAt.After(s.UpdatedAt) {
+			s.UpdatedAt = meta.UpdatedAt
+		}
+	}
+
+	summaries := make([]FunctionSummary, 0, len(byName))
+	for name, s := range byName {
+		if latest, ok := r.Latest[name]; ok && latest != nil {
+			s.LatestVersion = latest.Version
+			s.Runtime = latest.Runtime
+		}
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries
+}
+
+// DescribeFunction 返回函数全部版本的详情，版本按创建时间排序
+func (r *Registry) DescribeFunction(funcName string) (*FunctionDetail, bool) {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+
+	detail := &FunctionDetail{Name: funcName, Aliases: make(map[string]string)}
+	aliasesByVersion := make(map[string][]string)
+	prefix := funcName + ":"
+	for aliasKey, version := range r.aliasMap {
+		if strings.HasPrefix(aliasKey, prefix) {
+			alias := strings.TrimPrefix(aliasKey, prefix)
+			detail.Aliases[alias] = version
+			aliasesByVersion[version] = append(aliasesByVersion[version], alias)
+		}
+	}
+
+	for _, meta := range r.VersionMap {
+		if meta.Name == funcName {
+			detail.Versions = append(detail.Versions, versionInfo(meta, aliasesByVersion[meta.Version]))
+		}
+	}
+	if len(detail.Versions) == 0 {
+		return nil, false
+	}
+	sort.Slice(detail.Versions, func(i, j int) bool {
+		return detail.Versions[i].CreatedAt.Before(detail.Versions[j].CreatedAt)
+	})
+	if latest, ok := r.Latest[funcName]; ok && latest != nil {
+		detail.LatestVersion = latest.Version
+	}
+	detail.Schedules = r.listSchedulesLocked(funcName)
+	return detail, true
+}
+
+// DescribeVersion 返回单个版本详情
+func (r *Registry) DescribeVersion(funcName, version string) (*VersionInfo, bool) {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+
+	meta, exists := r.VersionMap[fmt.Sprintf("%s:%s", funcName, version)]
+	if !exists {
+		return nil, false
+	}
+	var aliases []string
+	prefix := funcName + ":"
+	for aliasKey, v := range r.aliasMap {
+		if v == version && strings.HasPrefix(aliasKey, prefix) {
+			aliases = append(aliases, strings.TrimPrefix(aliasKey, prefix))
+		}
+	}
+	info := versionInfo(meta, aliases)
+	return &info, true
+}
+
+// versionInfo 构建版本详情（调用方持有读锁）
+func versionInfo(meta *FunctionMetadata, aliases []string) VersionInfo {
+	sort.Strings(aliases)
+	if aliases == nil {
+		aliases = []string{}
+	}
+	envKeys := make([]string, 0, len(meta.EnvVars))
+	for k := range meta.EnvVars {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+
+	return VersionInfo{
+		Version:        meta.Version,
+		Runtime:        meta.Runtime,
+		Subdomain:      meta.Subdomain,
+		Status:         meta.Status,
+		Port:           meta.Workerd.Port,
+		LastAccessed:   meta.LastAccessed,
+		CreatedAt:      meta.CreatedAt,
+		UpdatedAt:      meta.UpdatedAt,
+		Aliases:        aliases,
+		EnvKeys:        envKeys,
+		CodeSize:       len(meta.Code),
+		CodeSHA256:     meta.CodeHash,
+		SourceEntry:    meta.SourceEntry,
+		WasmSize:       len(meta.Wasm),
+		WasmSHA256:     meta.WasmHash,
+		LastStartError: meta.LastStartError,
+		Resources:      meta.Resources,
+		OOMKills:       meta.OOMKills,
+		LastOOMAt:      meta.LastOOMAt,
+		Sandbox:        meta.Sandbox.resolve(),
+		Egress:         meta.Egress,
+		Services:       meta.Services,
+		DurableObjects: meta.DurableObjects.Classes,
+		Database:       meta.Database,
+	}
+}
+
+// GetCode 返回版本源码
+func (r *Registry) GetCode(funcName, version string) (string, bool) {
+	meta, exists := r.GetByVersion(funcName, version)
+	if !exists {
+		return "", false
+	}
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+	return meta.Code, true
+}
+
+// GetSource 返回版本编译前的入口源码（TypeScript 部署），没有源码时返回 false
+func (r *Registry) GetSource(funcName, version string) (string, []byte, bool) {
+	meta, exists := r.GetByVersion(funcName, version)
+	if !exists {
+		return "", nil, false
+	}
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+	source, ok := meta.Sources[meta.SourceEntry]
+	return meta.SourceEntry, source, ok
+}
+
+// GetWasm 返回版本的 WebAssembly 二进制，非 wasm 版本返回 false
+func (r *Registry) GetWasm(funcName, version string) ([]byte, bool) {
+	meta, exists := r.GetByVersion(funcName, version)
+	if !exists {
+		return nil, false
+	}
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+	return meta.Wasm, len(meta.Wasm) > 0
+}
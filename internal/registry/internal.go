@@ -0,0 +1,167 @@
+package registry
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// callerTokenHeader workerd 在发往平台内部监听的请求中注入的调用方标识：<函数>:<版本>:<签名>。
+// 函数代码看不到注入的请求头，也无法伪造其他版本的签名
+const callerTokenHeader = "X-Faas-Caller-Token"
+
+// internalListener 平台的内部监听，接收 workerd 通过外部服务发来的请求：出站代理（globalOutbound）、服务绑定与数据库绑定。
+// 监听 127.0.0.1 的随机端口，以及存储目录下的 unix socket（供隔离网络的实例使用）
+type internalListener struct {
+	reg      *Registry
+	secret   []byte // 调用方标识的签名密钥（每次启动平台随机生成）
+	address  string // TCP 监听地址
+	socket   string // unix socket 路径，无法监听时为空
+	servers  []*http.Server
+	egress   *httputil.ReverseProxy
+	services http.Handler // 服务绑定请求的处理器（由 SetServiceHandler 设置）
+}
+
+// caller 已校验的调用方版本，以及请求时的出站规则与服务绑定
+type caller struct {
+	name, version string
+	egress        EgressPolicy
+	services      []ServiceBinding
+	database      string // 数据库绑定名
+}
+
+// startInternalListener 启动内部监听
+func (r *Registry) startInternalListener() (*internalListener, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate caller secret: %w", err)
+	}
+	l := &internalListener{reg: r, secret: secret}
+	l.egress = l.newEgressForwarder()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listen internal: %w", err)
+	}
+	l.address = ln.Addr().String()
+	l.serve(ln)
+
+	// 隔离网络的实例通过只读挂载的 socket 目录访问
+	if socket, err := filepath.Abs(filepath.Join(r.StorageDir, ".internal", "internal.sock")); err != nil || len(socket) >= 108 {
+		fmt.Printf("internal socket is unavailable under %s\n", r.StorageDir)
+	} else if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		fmt.Printf("create internal socket dir: %v\n", err)
+	} else {
+		os.Remove(socket)
+		if ln, err := net.Listen("unix", socket); err != nil {
+			fmt.Printf("listen internal socket: %v\n", err)
+		} else {
+			l.socket = socket
+			l.serve(ln)
+		}
+	}
+	return l, nil
+}
+
+func (l *internalListener) serve(ln net.Listener) {
+	srv := &http.Server{Handler: l, ReadHeaderTimeout: 10 * time.Second}
+	l.servers = append(l.servers, srv)
+	go srv.Serve(ln)
+}
+
+func (l *internalListener) close() {
+	for _, srv := range l.servers {
+		srv.Close()
+	}
+}
+
+// token 版本的调用方标识
+func (l *internalListener) token(name, version string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(name + ":" + version))
+	return fmt.Sprintf("%s:%s:%s", name, version, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// caller 校验调用方标识并取出版本当前的设置（包括正在冒烟测试的版本）
+func (l *internalListener) caller(token string) (*caller, bool) {
+	i := strings.LastIndex(token, ":")
+	if i < 0 {
+		return nil, false
+	}
+	name, version, ok := strings.Cut(token[:i], ":")
+	if !ok || !hmac.Equal([]byte(l.token(name, version)), []byte(token)) {
+		return nil, false
+	}
+	l.reg.Mu.RLock()
+	defer l.reg.Mu.RUnlock()
+	key := fmt.Sprintf("%s:%s", name, version)
+	meta := l.reg.VersionMap[key]
+	if meta == nil {
+		meta = l.reg.pending[key]
+	}
+	if meta == nil {
+		return nil, false
+	}
+	return &caller{name: name, version: version, egress: meta.Egress, services: meta.Services, database: meta.Database.Binding}, true
+}
+
+// ServeHTTP 带有绑定名的请求交给服务绑定或数据库绑定，其余为出站请求
+func (l *internalListener) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c, ok := l.caller(req.Header.Get(callerTokenHeader))
+	if !ok {
+		http.Error(w, "unknown caller", http.StatusForbidden)
+		return
+	}
+	req.Header.Del(callerTokenHeader)
+	if binding := req.Header.Get(serviceBindingHeader); binding != "" {
+		req.Header.Del(serviceBindingHeader)
+		l.serveBinding(w, req, c, binding)
+		return
+	}
+	if binding := req.Header.Get(databaseBindingHeader); binding != "" {
+		req.Header.Del(databaseBindingHeader)
+		l.serveDatabase(w, req, c, binding)
+		return
+	}
+	l.serveEgress(w, req, c)
+}
+
+// externalService 生成指向内部监听的 workerd external 服务，请求中注入调用方标识与 headers（名称、值）
+func (l *internalListener) externalService(meta *FunctionMetadata, name, style string, headers ...[2]string) (string, error) {
+	address := l.address
+	if meta.Sandbox.IsolateNetwork() {
+		if l.socket == "" {
+			return "", errors.New("internal socket is unavailable for network-isolated instances")
+		}
+		address = "unix:" + l.socket
+	}
+	inject := []string{fmt.Sprintf(`( name = "%s", value = "%s" )`, callerTokenHeader, l.token(meta.Name, meta.Version))}
+	for _, h := range headers {
+		inject = append(inject, fmt.Sprintf(`( name = "%s", value = "%s" )`, h[0], h[1]))
+	}
+	return fmt.Sprintf(`    (
+      name = "%s",
+      external = (
+        address = "%s",
+        http = (
+          style = %s,
+          injectRequestHeaders = [ %s ]
+        )
+      )
+    )`, name, address, style, strings.Join(inject, ", ")), nil
+}
+
+// usesInternal 版本的实例是否需要访问内部监听
+func usesInternal(meta *FunctionMetadata) bool {
+	return !meta.Egress.IsZero() || len(meta.Services) > 0 || !meta.Database.IsZero()
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"faas/internal/metrics"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CgroupParent 实例 cgroup（v2）的父目录，每个实例进程放入其下独立的子 cgroup。
+// 目录不可用（非 cgroup v2、没有权限等）时退回到 rlimit
+var CgroupParent = "/sys/fs/cgroup/faas"
+
+// ResourceLimits 部署时声明的实例资源上限，零值表示不限制
+type ResourceLimits struct {
+	MemoryMB  int     `json:"memory_mb,omitempty" binding:"omitempty,min=16"`  // 内存上限（MiB）
+	CPU       float64 `json:"cpu,omitempty" binding:"omitempty,gt=0,max=64"`   // CPU 配额（核数，如 0.5）
+	Pids      int     `json:"pids,omitempty" binding:"omitempty,min=1"`        // 进程 / 线程数上限
+	OpenFiles int     `json:"open_files,omitempty" binding:"omitempty,min=16"` // 打开文件数上限
+}
+
+// IsZero 是否未声明任何限制
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// String 限制的简短描述，如 memory=128MiB cpu=0.5
+func (l ResourceLimits) String() string {
+	var parts []string
+	if l.MemoryMB > 0 {
+		parts = append(parts, fmt.Sprintf("memory=%dMiB", l.MemoryMB))
+	}
+	if l.CPU > 0 {
+		parts = append(parts, fmt.Sprintf("cpu=%g", l.CPU))
+	}
+	if l.Pids > 0 {
+		parts = append(parts, fmt.Sprintf("pids=%d", l.Pids))
+	}
+	if l.OpenFiles > 0 {
+		parts = append(parts, fmt.Sprintf("open_files=%d", l.OpenFiles))
+	}
+	return strings.Join(parts, " ")
+}
+
+// recordOOMLocked 记录一次内存超限被结束（调用方持有锁）
+func (r *Registry) recordOOMLocked(meta *FunctionMetadata) {
+	now := time.Now()
+	meta.OOMKills++
+	meta.LastOOMAt = &now
+	metrics.IncOOMKill(meta.Name, meta.Version)
+	fmt.Printf("%s:%s was killed for exceeding its memory limit (%d MiB)\n", meta.Name, meta.Version, meta.Resources.MemoryMB)
+	if meta.ID == 0 {
+		return // 新版本尚未保存
+	}
+	if err := r.db.Model(meta).UpdateColumns(map[string]any{"oom_kills": meta.OOMKills, "last_oom_at": now}).Error; err != nil {
+		fmt.Printf("failed to save oom kill of %s:%s: %v\n", meta.Name, meta.Version, err)
+	}
+}
@@ -0,0 +1,200 @@
+//go:build linux
+
+package registry
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupControllers 实例 cgroup 需要的控制器
+var cgroupControllers = []string{"memory", "cpu", "pids"}
+
+// cgroupPeriod cpu.max 的周期（微秒）
+const cgroupPeriod = 100000
+
+var (
+	cgroupOnce sync.Once
+	cgroupErr  error // cgroup v2 不可用的原因，nil 表示可用
+)
+
+// cgroupAvailable 检查 cgroup v2 是否可用：创建 CgroupParent 并为其开启所需控制器（只检查一次）
+func cgroupAvailable() error {
+	cgroupOnce.Do(func() {
+		cgroupErr = setupCgroupParent(CgroupParent)
+		if cgroupErr != nil {
+			fmt.Printf("cgroup v2 unavailable, falling back to rlimit: %v\n", cgroupErr)
+		}
+	})
+	return cgroupErr
+}
+
+func setupCgroupParent(parent string) error {
+	root := filepath.Dir(parent)
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("%s is not a cgroup v2 hierarchy", root)
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("create %s: %w", parent, err)
+	}
+	// 父 cgroup 与 CgroupParent 都需要把控制器下放给子 cgroup
+	for _, dir := range []string{root, parent} {
+		for _, controller := range cgroupControllers {
+			if err := writeCgroupFile(dir, "cgroup.subtree_control", "+"+controller); err != nil {
+				return fmt.Errorf("enable %s controller: %w", controller, err)
+			}
+		}
+	}
+	return nil
+}
+
+// processLimits 一次实例启动的资源限制：cgroup 为空时以 rlimit 限制
+type processLimits struct {
+	limits ResourceLimits
+	cgroup string // 实例的 cgroup 目录
+	fd     int    // cgroup 目录的文件描述符（进程创建时直接放入该 cgroup）
+}
+
+// prepareLimits 为即将启动的进程准备资源限制：cgroup v2 可用时创建实例 cgroup 并写入上限，
+// 进程在 clone 时直接放入其中；未声明限制时返回 nil
+func prepareLimits(meta *FunctionMetadata, cmd *exec.Cmd) (*processLimits, error) {
+	if meta.Resources.IsZero() {
+		return nil, nil
+	}
+	l := &processLimits{limits: meta.Resources, fd: -1}
+	if cgroupAvailable() != nil {
+		return l, nil
+	}
+
+	dir := filepath.Join(CgroupParent, fmt.Sprintf("%s-%s", meta.Name, meta.Version))
+	if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("create cgroup: %w", err)
+	}
+	if err := writeCgroupLimits(dir, meta.Resources); err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+	fd, err := syscall.Open(dir, syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		os.Remove(dir)
+		return nil, fmt.Errorf("open cgroup: %w", err)
+	}
+	l.cgroup, l.fd = dir, fd
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = fd
+	return l, nil
+}
+
+// writeCgroupLimits 写入内存（不允许使用 swap，超限时结束整个 cgroup）、CPU 配额与进程数上限
+func writeCgroupLimits(dir string, limits ResourceLimits) error {
+	files := map[string]string{}
+	if limits.MemoryMB > 0 {
+		files["memory.max"] = strconv.Itoa(limits.MemoryMB << 20)
+		files["memory.oom.group"] = "1"
+	}
+	if limits.CPU > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", int(limits.CPU*cgroupPeriod), cgroupPeriod)
+	}
+	if limits.Pids > 0 {
+		files["pids.max"] = strconv.Itoa(limits.Pids)
+	}
+	for name, value := range files {
+		if err := writeCgroupFile(dir, name, value); err != nil {
+			return fmt.Errorf("set %s: %w", name, err)
+		}
+	}
+	if limits.MemoryMB > 0 {
+		writeCgroupFile(dir, "memory.swap.max", "0") // 未开启 swap 记账时不存在
+	}
+	return nil
+}
+
+// started 进程启动后设置 rlimit：打开文件数总是以 RLIMIT_NOFILE 限制；
+// 没有 cgroup 时内存以 RLIMIT_AS 近似（V8 会预留较大的虚拟地址空间，上限过小会导致 workerd 无法启动），
+// CPU 配额与进程数无法按进程限制，只打印提示
+func (l *processLimits) started(pid int) error {
+	if l == nil {
+		return nil
+	}
+	if l.fd >= 0 {
+		syscall.Close(l.fd)
+		l.fd = -1
+	}
+	if l.limits.OpenFiles > 0 {
+		if err := prlimit(pid, unix.RLIMIT_NOFILE, uint64(l.limits.OpenFiles)); err != nil {
+			return fmt.Errorf("limit open files: %w", err)
+		}
+	}
+	if l.cgroup != "" {
+		return nil
+	}
+	if l.limits.MemoryMB > 0 {
+		if err := prlimit(pid, unix.RLIMIT_AS, uint64(l.limits.MemoryMB)<<20); err != nil {
+			return fmt.Errorf("limit memory: %w", err)
+		}
+	}
+	if l.limits.CPU > 0 || l.limits.Pids > 0 {
+		fmt.Printf("process %d: cpu and pids limits require cgroup v2, not enforced\n", pid)
+	}
+	return nil
+}
+
+func prlimit(pid, resource int, value uint64) error {
+	return unix.Prlimit(pid, resource, &unix.Rlimit{Cur: value, Max: value}, nil)
+}
+
+// release 进程退出后回收 cgroup，返回进程是否因内存超限被结束
+func (l *processLimits) release() (oomKilled bool) {
+	if l == nil {
+		return false
+	}
+	if l.fd >= 0 {
+		syscall.Close(l.fd)
+		l.fd = -1
+	}
+	if l.cgroup == "" {
+		return false
+	}
+	oomKilled = cgroupEvent(l.cgroup, "memory.events", "oom_group_kill") > 0 ||
+		cgroupEvent(l.cgroup, "memory.events", "oom_kill") > 0
+	if err := os.Remove(l.cgroup); err != nil {
+		fmt.Printf("failed to remove cgroup %s: %v\n", l.cgroup, err)
+	}
+	return oomKilled
+}
+
+// cgroupEvent 读取 cgroup 事件文件中的计数（如 memory.events 的 oom_kill）
+func cgroupEvent(dir, file, key string) int {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), " ")
+		if ok && name == key {
+			n, _ := strconv.Atoi(value)
+			return n
+		}
+	}
+	return 0
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package registry
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// processLimits 非 Linux 平台不支持资源限制
+type processLimits struct{}
+
+// prepareLimits 非 Linux 平台只提示声明的限制不会生效
+func prepareLimits(meta *FunctionMetadata, cmd *exec.Cmd) (*processLimits, error) {
+	if !meta.Resources.IsZero() {
+		fmt.Printf("%s:%s: resource limits (%s) are only enforced on Linux\n", meta.Name, meta.Version, meta.Resources)
+	}
+	return nil, nil
+}
+
+func (l *processLimits) started(pid int) error {
+	return nil
+}
+
+func (l *processLimits) release() bool {
+	return false
+}
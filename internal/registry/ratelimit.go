@@ -0,0 +1,149 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 限流客户端标识方式
+const (
+	RateLimitKeyIP     = "ip"      // 按客户端 IP
+	RateLimitKeyAPIKey = "api_key" // 按 X-API-Key 请求头
+	RateLimitKeyHeader = "header"  // 按自定义请求头
+)
+
+// RateLimitRule 限流规则（函数级或别名级）
+type RateLimitRule struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	FuncName  string    `gorm:"uniqueIndex:idx_ratelimit_target;not null" json:"func_name"`
+	Alias     string    `gorm:"uniqueIndex:idx_ratelimit_target" json:"alias"` // 为空表示作用于整个函数
+	Rate      float64   `gorm:"not null" json:"rate"`                          // 每秒补充令牌数
+	Burst     int       `json:"burst"`                                         // 桶容量
+	KeyBy     string    `gorm:"not null" json:"key_by"`                        // ip / api_key / header
+	Header    string    `json:"header"`                                        // key_by=header 时使用的请求头
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Key 规则唯一标识（funcName:alias）
+func (rule *RateLimitRule) Key() string {
+	return fmt.Sprintf("%s:%s", rule.FuncName, rule.Alias)
+}
+
+// SetRateLimit 新增或更新限流规则，函数或别名不存在时返回 ErrFunctionNotFound / ErrAliasNotFound
+func (r *Registry) SetRateLimit(rule *RateLimitRule) error {
+	if rule.Rate <= 0 {
+		return invalidf("rate must be positive")
+	}
+	switch rule.KeyBy {
+	case "":
+		rule.KeyBy = RateLimitKeyIP
+	case RateLimitKeyIP, RateLimitKeyAPIKey:
+	case RateLimitKeyHeader:
+		if rule.Header == "" {
+			return invalidf("header is required when key_by is header")
+		}
+	default:
+		return invalidf("unsupported key_by: %s", rule.KeyBy)
+	}
+
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+
+	if _, exists := r.Latest[rule.FuncName]; !exists {
+		return ErrFunctionNotFound
+	}
+	if rule.Alias != "" {
+		if _, exists := r.aliasMap[fmt.Sprintf("%s:%s", rule.FuncName, rule.Alias)]; !exists {
+			return fmt.Errorf("%w: %s", ErrAliasNotFound, rule.Alias)
+		}
+	}
+
+	key := rule.Key()
+	if old, exists := r.rateLimits[key]; exists {
+		rule.ID = old.ID
+		rule.CreatedAt = old.CreatedAt
+	}
+	if err := r.db.Save(rule).Error; err != nil {
+		return fmt.Errorf("save rate limit: %w", err)
+	}
+	r.rateLimits[key] = rule
+	r.RateLimiter.Reset(key)
+	return nil
+}
+
+// DeleteRateLimit 删除限流规则
+func (r *Registry) DeleteRateLimit(funcName, alias string) error {
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+
+	key := fmt.Sprintf("%s:%s", funcName, alias)
+	rule, exists := r.rateLimits[key]
+	if !exists {
+		return ErrRateLimitNotFound
+	}
+	if err := r.db.Delete(rule).Error; err != nil {
+		return fmt.Errorf("delete rate limit: %w", err)
+	}
+	delete(r.rateLimits, key)
+	r.RateLimiter.Reset(key)
+	return nil
+}
+
+// ListRateLimits 列出函数的全部限流规则
+func (r *Registry) ListRateLimits(funcName string) []RateLimitRule {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+
+	var rules []RateLimitRule
+	for _, rule := range r.rateLimits {
+		if rule.FuncName == funcName {
+			rules = append(rules, *rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Alias < rules[j].Alias })
+	return rules
+}
+
+// RateLimitFor 查找生效的限流规则：别名级优先，其次函数级
+func (r *Registry) RateLimitFor(funcName, alias string) (RateLimitRule, bool) {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+
+	if alias != "" {
+		if rule, ok := r.rateLimits[fmt.Sprintf("%s:%s", funcName, alias)]; ok {
+			return *rule, true
+		}
+	}
+	if rule, ok := r.rateLimits[fmt.Sprintf("%s:", funcName)]; ok {
+		return *rule, true
+	}
+	return RateLimitRule{}, false
+}
+
+// 删除函数时清理其限流规则（调用方持有锁）
+func (r *Registry) deleteRateLimitsLocked(funcName string) error {
+	if err := r.db.Where("func_name = ?", funcName).Delete(&RateLimitRule{}).Error; err != nil {
+		return fmt.Errorf("delete rate limits: %w", err)
+	}
+	for key, rule := range r.rateLimits {
+		if rule.FuncName == funcName {
+			delete(r.rateLimits, key)
+			r.RateLimiter.Reset(key)
+		}
+	}
+	return nil
+}
+
+// 从数据库加载限流规则（调用方持有锁）
+func (r *Registry) loadRateLimitsLocked() error {
+	var rules []*RateLimitRule
+	if err := r.db.Find(&rules).Error; err != nil {
+		return fmt.Errorf("load rate limits: %w", err)
+	}
+	for _, rule := range rules {
+		r.rateLimits[rule.Key()] = rule
+	}
+	return nil
+}
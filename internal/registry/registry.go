@@ -1,26 +1,29 @@
 package registry
 
 import (
-	"bytes"
+	"context"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"faas/internal/logs"
+	"faas/internal/metrics"
+	"faas/internal/ratelimit"
+	"faas/internal/tracing"
 	"faas/internal/util"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// WorkerdConfig workerd 进程配置
+// WorkerdConfig 实例进程配置（字段名沿用 workerd，所有运行后端共用）
 type WorkerdConfig struct {
 	Port     int    `json:"port"`
 	ConfPath string `json:"conf_path"`
@@ -31,17 +34,39 @@ type WorkerdConfig struct {
 
 // FunctionMetadata 函数元数据
 type FunctionMetadata struct {
-	gorm.Model                 // 内置字段：ID, CreatedAt, UpdatedAt, DeletedAt
-	Name         string        `gorm:"index;not null" json:"name"` // 函数名
-	Subdomain    string        `gorm:"uniqueIndex;not null" json:"subdomain"`
-	Runtime      string        `gorm:"not null" json:"runtime"`
-	Code         string        `gorm:"type:text;not null" json:"code"`         // 存储函数代码
-	EnvVars      JSONMap       `gorm:"type:text;default:'{}'" json:"env_vars"` // 环境变量（JSON存储）
-	Version      string        `gorm:"index;not null" json:"version"`          // 版本号（必填）
-	Alias        string        `json:"alias"`
-	Workerd      WorkerdConfig `gorm:"type:json;default:'{}'" json:"workerd"` // 嵌套结构体，会被展开为WorkerdPort, WorkerdConfPath等字段
-	Status       string        `json:"status"`                                // 进程状态: running/suspended
-	LastAccessed time.Time     `json:"last_accessed"`                         // 最后访问时间
+	gorm.Model                         // 内置字段：ID, CreatedAt, UpdatedAt, DeletedAt
+	Name           string              `gorm:"index;not null" json:"name"` // 函数名
+	Subdomain      string              `gorm:"uniqueIndex;not null" json:"subdomain"`
+	Runtime        string              `gorm:"not null" json:"runtime"`
+	Code           string              `gorm:"-" json:"code"`                          // 函数代码（存放在 blob 存储中，按 CodeHash 加载）
+	CodeHash       string              `gorm:"index" json:"code_hash"`                 // 代码 SHA-256
+	EnvVars        JSONMap             `gorm:"type:text;default:'{}'" json:"env_vars"` // 环境变量（JSON存储）
+	Version        string              `gorm:"index;not null" json:"version"`          // 版本号（必填）
+	Alias          string              `json:"alias"`
+	Entry          string              `json:"entry"`                                   // 入口模块（多文件部署时为模块路径）
+	Modules        ModuleSet           `gorm:"-" json:"-"`                              // 多文件部署的模块集（路径 -> 内容），单文件部署为空
+	ModuleHashes   JSONMap             `gorm:"type:text" json:"module_hashes"`          // 模块路径 -> SHA-256
+	SourceEntry    string              `json:"source_entry"`                            // 源码入口（TypeScript 部署时为 .ts 路径）
+	Sources        ModuleSet           `gorm:"-" json:"-"`                              // 编译前的源文件（路径 -> 内容），JS 部署为空
+	SourceHashes   JSONMap             `gorm:"type:text" json:"source_hashes"`          // 源文件路径 -> SHA-256
+	Wasm           []byte              `gorm:"-" json:"-"`                              // WebAssembly 二进制（wasm 运行时，存放在 blob 存储中）
+	WasmHash       string              `json:"wasm_hash"`                               // WebAssembly 二进制 SHA-256，非 wasm 运行时为空
+	Workerd        WorkerdConfig       `gorm:"type:json;default:'{}'" json:"workerd"`   // 嵌套结构体，会被展开为WorkerdPort, WorkerdConfPath等字段
+	Status         string              `json:"status"`                                  // 进程状态: running/suspended
+	LastAccessed   time.Time           `json:"last_accessed"`                           // 最后访问时间
+	SmokeTest      *SmokeTest          `gorm:"-" json:"-"`                              // 部署时的冒烟测试（可选，不持久化）
+	LastStartError *StartError         `gorm:"serializer:json" json:"last_start_error"` // 最近一次启动失败（成功启动后保留，以 At 区分）
+	Resources      ResourceLimits      `gorm:"serializer:json" json:"resources"`        // 实例资源上限
+	OOMKills       int                 `json:"oom_kills"`                               // 因内存超限被结束的次数
+	LastOOMAt      *time.Time          `json:"last_oom_at"`                             // 最近一次内存超限被结束的时间
+	Sandbox        SandboxConfig       `gorm:"serializer:json" json:"sandbox"`          // 隔离设置（声明值，为空的字段使用全局默认）
+	Egress         EgressPolicy        `gorm:"serializer:json" json:"egress"`           // 出站规则（为空表示不限制）
+	Services       []ServiceBinding    `gorm:"serializer:json" json:"services"`         // 服务绑定
+	DurableObjects DurableObjectConfig `gorm:"serializer:json" json:"durable_objects"`  // Durable Object 类与迁移
+	Database       DatabaseConfig      `gorm:"serializer:json" json:"database"`         // 私有 SQLite 数据库
+	output         *outputBuffer       // 实例最近的输出（stderr 与 stdout）
+	exited         chan struct{}       // 实例进程退出时关闭（nil 表示未由本注册表启动）
+	oomKilled      bool                // 实例进程是否因内存超限被结束（exited 关闭后有效）
 }
 
 // Registry 函数注册表（单例）
@@ -50,188 +75,234 @@ type Registry struct {
 	subdomainMap map[string]string            // 子域名 -> 函数名
 	Mu           sync.RWMutex                 // 并发安全锁
 	StorageDir   string                       // 存储目录
-	workerdBin   string                       // workerd 二进制路径
 	VersionMap   map[string]*FunctionMetadata // funcName:version -> 元数据（唯一标识版本）
 	aliasMap     map[string]string            // funcName:alias -> version（别名指向版本）
 	db           *gorm.DB                     // 数据库连接
 	ticker       *time.Ticker                 // 超时检查器
+	done         chan struct{}                // Close 时关闭，通知超时检查退出
+	rateLimits   map[string]*RateLimitRule    // funcName:alias -> 限流规则（alias 为空表示函数级）
+	schedules    map[string][]*Schedule       // 函数名 -> 定时任务
+	RateLimiter  *ratelimit.Limiter           // 令牌桶限流器
+	logWriters   map[string]*logs.Writer      // funcName:version -> 日志写入器
+	logMu        sync.Mutex                   // 保护 logWriters
+	runtimes     map[string]Runtime           // 运行时名称 -> 运行后端
+	pending      map[string]*FunctionMetadata // funcName:version -> 正在冒烟测试、尚未上线的版本
+	internal     *internalListener            // 内部监听（出站代理与服务绑定）
+	databases    map[string]*functionDatabase // 函数名 -> 打开的数据库
+	databaseMu   sync.Mutex                   // 保护 databases
 }
 
 var defaultRegistry *Registry
 
-// Default 获取单例注册表
+// Default 获取单例注册表（存储目录为 faas-workerd-storage）
 func Default(workerdBin string) *Registry {
 	if defaultRegistry == nil {
-		// 初始化数据库
-		db, err := gorm.Open(sqlite.Open(filepath.Join(util.GetStorageDir(), "faas.db")), &gorm.Config{})
+		reg, err := New(workerdBin, util.GetStorageDir())
 		if err != nil {
-			panic(fmt.Sprintf("failed to connect database: %v", err))
-		}
-
-		// 自动迁移表结构
-		if err := db.AutoMigrate(&FunctionMetadata{}); err != nil {
-			panic(fmt.Sprintf("failed to migrate database: %v", err))
-		}
-
-		// 创建注册表实例
-		defaultRegistry = &Registry{
-			Latest:       make(map[string]*FunctionMetadata),
-			subdomainMap: make(map[string]string),
-			StorageDir:   util.GetStorageDir(),
-			workerdBin:   workerdBin,
-			VersionMap:   make(map[string]*FunctionMetadata),
-			aliasMap:     make(map[string]string),
-			db:           db,
-			ticker:       time.NewTicker(1 * time.Minute),
-		}
-
-		go defaultRegistry.checkTimeouts()
-
-		// 从数据库加载已保存的函数
-		err = defaultRegistry.loadFromDB()
-		if err != nil {
-			_ = fmt.Errorf("load from DB failed: %w", err)
+			panic(err.Error())
 		}
+		defaultRegistry = reg
 	}
 	return defaultRegistry
 }
 
-// 生成 workerd 配置与代码文件
-func (r *Registry) generateWorkerdFiles(meta *FunctionMetadata) error {
-	// 生成函数代码文件（如 storage/foo.js）
-	codeFile := fmt.Sprintf("%s.js", meta.Name)
-	codePath := filepath.Join(r.StorageDir, codeFile)
-	if err := os.WriteFile(codePath, []byte(meta.Code), 0644); err != nil {
-		return fmt.Errorf("write code: %w", err)
-	}
-	meta.Workerd.CodePath = codePath
-
-	// 生成配置文件（注意 embed 必须是相对路径）
-	confPath := filepath.Join(r.StorageDir, fmt.Sprintf("%s.capnp", meta.Name))
-	confContent := fmt.Sprintf(`
-using Workerd = import "/workerd/workerd.capnp";
-
-const config :Workerd.Config = (
-  services = [
-    (
-      name = "%s",
-      worker = (
-        serviceWorkerScript = embed "%s",
-        compatibilityDate = "2024-05-01",
-		bindings = [
-          %s
-        ]
-      )
-    )
-  ],
-  sockets = [
-    (
-      name = "http",
-      address = "127.0.0.1:%d",
-      http = (),
-      service = "%s"
-    )
-  ]
-);
-`, meta.Name, codeFile, generateWorkerdEnv(meta), meta.Workerd.Port, meta.Name)
-
-	if err := os.WriteFile(confPath, []byte(confContent), 0644); err != nil {
-		return fmt.Errorf("write conf: %w", err)
-	}
-	meta.Workerd.ConfPath = confPath
-
-	// 生成日志文件路径
-	logPath := filepath.Join(r.StorageDir, fmt.Sprintf("%s.log", meta.Name))
-	meta.Workerd.LogPath = logPath
-	return nil
-}
-
-// 启动/停止 workerd 进程
-func (r *Registry) StartWorkerd(meta *FunctionMetadata) error {
-	// 生成配置/代码文件
-	if err := r.generateWorkerdFiles(meta); err != nil {
-		return err
+// New 创建使用指定存储目录的注册表，并从数据库恢复已保存的函数。
+// js / ts / wasm 由 workerd 运行，ExecRuntimeEnabled 为 true 时注册 exec 后端
+func New(workerdBin, storageDir string) (*Registry, error) {
+	if err := os.MkdirAll(storageDir, 0755); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
 	}
 
-	// 启动 workerd 进程（命令：workerd serve 配置文件）
-	cmd := exec.Command(r.workerdBin, "serve", meta.Workerd.ConfPath)
-	fmt.Printf("[DEBUG] Running: %s serve %s\n", r.workerdBin, meta.Workerd.ConfPath)
-	// 重定向日志到文件
-	logFile, err := os.OpenFile(meta.Workerd.LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	// 初始化数据库
+	db, err := gorm.Open(sqlite.Open(filepath.Join(storageDir, "faas.db")), &gorm.Config{})
 	if err != nil {
-		return fmt.Errorf("open log: %w", err)
+		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
-	// 捕获 stderr
-	var stderrBuf bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, logFile)
-	cmd.Stderr = io.MultiWriter(os.Stderr, logFile)
 
-	// 启动进程并记录 PID
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("start workerd: %v, stderr: %s", err, stderrBuf.String())
+	// 自动迁移表结构
+	if err := db.AutoMigrate(&FunctionMetadata{}, &RateLimitRule{}, &FunctionAlias{}, &Schedule{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
-	meta.Workerd.Pid = cmd.Process.Pid
 
-	// 等待端口监听成功
-	if err := util.WaitPortListening("127.0.0.1", meta.Workerd.Port); err != nil {
-		cmd.Process.Kill() // 启动失败，清理进程
-		return fmt.Errorf("wait port: %v, stderr: %s", err, stderrBuf.String())
+	r := &Registry{
+		Latest:       make(map[string]*FunctionMetadata),
+		subdomainMap: make(map[string]string),
+		StorageDir:   storageDir,
+		VersionMap:   make(map[string]*FunctionMetadata),
+		aliasMap:     make(map[string]string),
+		db:           db,
+		ticker:       time.NewTicker(1 * time.Minute),
+		done:         make(chan struct{}),
+		rateLimits:   make(map[string]*RateLimitRule),
+		schedules:    make(map[string][]*Schedule),
+		RateLimiter:  ratelimit.New(),
+		logWriters:   make(map[string]*logs.Writer),
+		runtimes:     make(map[string]Runtime),
+		pending:      make(map[string]*FunctionMetadata),
+		databases:    make(map[string]*functionDatabase),
 	}
-	return nil
+	workerd := NewWorkerdRuntime(r, workerdBin)
+	for _, name := range []string{"js", "ts", "wasm"} {
+		r.runtimes[name] = workerd
+	}
+	if ExecRuntimeEnabled {
+		r.runtimes[ExecRuntimeName] = NewExecRuntime(r)
+	}
+	if r.internal, err = r.startInternalListener(); err != nil {
+		return nil, err
+	}
+
+	// 旧数据库中的代码迁移到 blob 存储
+	if err := r.migrateInlineContent(); err != nil {
+		r.internal.close()
+		return nil, fmt.Errorf("failed to migrate code storage: %w", err)
+	}
+	if err := r.migrateDurableDirs(); err != nil {
+		r.internal.close()
+		return nil, fmt.Errorf("failed to migrate durable object storage: %w", err)
+	}
+	if err := r.migrateDatabaseDirs(); err != nil {
+		r.internal.close()
+		return nil, fmt.Errorf("failed to migrate database storage: %w", err)
+	}
+
+	go r.checkTimeouts()
+	go r.runSchedules()
+
+	// 从数据库加载已保存的函数
+	if err := r.loadFromDB(); err != nil {
+		fmt.Printf("load from DB failed: %v\n", err)
+	}
+	return r, nil
 }
 
-func (r *Registry) stopWorkerd(meta *FunctionMetadata) error {
-	if meta.Workerd.Pid == 0 {
-		return nil // 进程未启动
+// Close 停止所有 workerd 进程并关闭数据库（数据保留，可再次 New 恢复）
+func (r *Registry) Close() error {
+	r.ticker.Stop()
+	close(r.done)
+
+	r.Mu.Lock()
+	for _, meta := range r.VersionMap {
+		if err := r.stopInstance(meta); err != nil {
+			fmt.Printf("failed to stop %s:%s: %v\n", meta.Name, meta.Version, err)
+		}
 	}
+	r.Mu.Unlock()
+	r.internal.close()
+	r.closeDatabases()
 
-	process, err := os.FindProcess(meta.Workerd.Pid)
-	if err != nil {
-		return fmt.Errorf("find process: %w", err)
+	r.logMu.Lock()
+	for key, writer := range r.logWriters {
+		writer.Close()
+		delete(r.logWriters, key)
 	}
+	r.logMu.Unlock()
 
-	// 检查进程是否还活着
-	err = process.Signal(syscall.Signal(0))
+	sqlDB, err := r.db.DB()
 	if err != nil {
-		if errors.Is(err, os.ErrProcessDone) {
-			// 进程已经结束，不需要停止
-			meta.Workerd.Pid = 0
-			return nil
-		}
-		return fmt.Errorf("check process: %w", err)
+		return err
 	}
+	return sqlDB.Close()
+}
 
-	// 发送终止信号
-	if err := process.Signal(syscall.SIGTERM); err != nil {
-		if errors.Is(err, os.ErrProcessDone) {
-			// 已经结束
-			meta.Workerd.Pid = 0
-			return nil
-		}
-		return fmt.Errorf("send signal: %w", err)
+// 版本存储目录
+func (r *Registry) versionDir(funcName, version string) string {
+	return filepath.Join(r.StorageDir, funcName, version)
+}
+
+// LogPath 版本日志文件路径
+func (r *Registry) LogPath(funcName, version string) string {
+	return filepath.Join(r.versionDir(funcName, version), fmt.Sprintf("%s.log", funcName))
+}
+
+// LogWriter 获取版本的日志写入器（不存在则创建）
+func (r *Registry) LogWriter(funcName, version string) *logs.Writer {
+	r.logMu.Lock()
+	defer r.logMu.Unlock()
+
+	key := fmt.Sprintf("%s:%s", funcName, version)
+	w, exists := r.logWriters[key]
+	if !exists {
+		w = logs.NewWriter(r.LogPath(funcName, version), logs.DefaultOptions)
+		r.logWriters[key] = w
 	}
+	return w
+}
 
-	// 等待进程退出
-	_, err = process.Wait()
-	if err != nil && !errors.Is(err, os.ErrProcessDone) {
-		return fmt.Errorf("wait exit: %w", err)
+// 关闭日志写入器并删除版本目录（代码、配置、日志）
+func (r *Registry) removeVersionFiles(funcName, version string) {
+	r.logMu.Lock()
+	key := fmt.Sprintf("%s:%s", funcName, version)
+	if w, exists := r.logWriters[key]; exists {
+		w.Close()
+		delete(r.logWriters, key)
 	}
+	r.logMu.Unlock()
 
-	meta.Workerd.Port = 0
-	meta.Workerd.Pid = 0
-	return nil
+	if err := os.RemoveAll(r.versionDir(funcName, version)); err != nil {
+		fmt.Printf("failed to remove files of %s: %v\n", key, err)
+	}
+}
+
+// 版本相关的 span 属性
+func versionAttrs(meta *FunctionMetadata) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("faas.function", meta.Name),
+		attribute.String("faas.version", meta.Version),
+	}
+}
+
+// Validate 检查新版本的名称与配置（运行时、隔离、出站、服务绑定、数据库），不修改注册表状态。
+// 名称会拼入存储路径与 workerd 配置，RegisterOrUpdate 总会先调用它，任何入口都不能绕过
+func (r *Registry) Validate(meta *FunctionMetadata) error {
+	if err := ValidateName("function name", meta.Name); err != nil {
+		return err
+	}
+	if err := ValidateName("version", meta.Version); err != nil {
+		return err
+	}
+	if _, err := r.runtimeFor(meta); err != nil {
+		return err
+	}
+	if err := checkSandbox(meta); err != nil {
+		return err
+	}
+	if err := checkEgress(meta); err != nil {
+		return err
+	}
+	if err := checkServices(meta); err != nil {
+		return err
+	}
+	return checkDatabase(meta)
 }
 
-// RegisterOrUpdate 注册/更新函数
-func (r *Registry) RegisterOrUpdate(meta *FunctionMetadata) error {
+// RegisterOrUpdate 注册新版本：先启动实例并执行冒烟测试（meta.SmokeTest 不为空时），
+// 全部通过后再持久化并一次性切换 latest 与别名；任一步失败都不改变现有路由，
+// 启动失败或冒烟测试未通过时返回 *StartError
+func (r *Registry) RegisterOrUpdate(ctx context.Context, meta *FunctionMetadata) (err error) {
+	ctx, span := tracing.Start(ctx, "RegisterOrUpdate", versionAttrs(meta)...)
+	defer func() { tracing.End(span, err) }()
+
+	if err := r.Validate(meta); err != nil {
+		return err
+	}
+
 	r.Mu.Lock()
 	defer r.Mu.Unlock()
 
-	// 更新 latest 指向
-	r.aliasMap[fmt.Sprintf("%s:latest", meta.Name)] = meta.Version
-
 	// 生成唯一标识
 	versionKey := fmt.Sprintf("%s:%s", meta.Name, meta.Version)
+	if _, exists := r.VersionMap[versionKey]; exists || r.pending[versionKey] != nil {
+		return ErrVersionExists
+	}
+	if err := r.resolveDurableObjectsLocked(meta); err != nil {
+		return err
+	}
+
+	// 代码写入 blob 存储（相同内容只存一份）
+	if err := r.storeContent(meta); err != nil {
+		return fmt.Errorf("store code: %w", err)
+	}
 
 	// 分配空闲端口
 	freePort, err := util.GetFreePort()
@@ -240,56 +311,72 @@ func (r *Registry) RegisterOrUpdate(meta *FunctionMetadata) error {
 	}
 	meta.Workerd.Port = freePort
 
-	// 启动新版本进程（不影响旧版本）
-	if err := r.StartWorkerd(meta); err != nil {
-		return fmt.Errorf("start new version: %w", err)
+	// 启动新版本进程（不影响旧版本），失败时返回 *StartError
+	if err := r.StartInstance(ctx, meta); err != nil {
+		r.discardLocked(meta)
+		return err
 	}
+	meta.Status = "running"
+	meta.LastAccessed = time.Now()
 
-	// 停止旧函数（更新场景）
-	//if oldMeta, exists := r.Latest[meta.Name]; exists {
-	//	if err := r.stopWorkerd(oldMeta); err != nil {
-	//		return fmt.Errorf("stop old function: %w", err)
-	//	}
-	//	delete(r.subdomainMap, oldMeta.Subdomain)
-	//	// 保留旧记录主键和域名
-	//	meta.ID = oldMeta.ID
-	//	meta.CreatedAt = oldMeta.CreatedAt
-	//	meta.Subdomain = oldMeta.Subdomain
-	//}
-
-	// 处理别名
-	if meta.Alias != "" {
-		aliasKey := fmt.Sprintf("%s:%s", meta.Name, meta.Alias)
-		oldVersion, exists := r.aliasMap[aliasKey]
-		// 移除旧别名的子域名映射
-		if exists {
-			oldMetaKey := fmt.Sprintf("%s:%s", meta.Name, oldVersion)
-			if oldMeta, ok := r.VersionMap[oldMetaKey]; ok {
-				delete(r.subdomainMap, oldMeta.Subdomain)
-			}
+	// 冒烟测试期间释放锁，避免阻塞其他请求；pending 防止同一版本被重复部署，其代码也不会被 GC 回收
+	if meta.SmokeTest != nil {
+		r.pending[versionKey] = meta
+		address := r.Address(meta)
+		r.Mu.Unlock()
+		err := runSmokeTest(ctx, meta, address)
+		r.Mu.Lock()
+		delete(r.pending, versionKey)
+		if err != nil {
+			startErr := r.newStartError(meta, StageSmokeTest, err)
+			r.discardLocked(meta)
+			return startErr
 		}
-
-		// 注册新别名映射
-		r.aliasMap[aliasKey] = meta.Version
-		aliasSubdomain := r.generateAliasSubdomain(meta.Name, meta.Alias)
-		r.subdomainMap[aliasSubdomain] = versionKey // 别名子域名指向版本
 	}
 
-	// 存储元数据
-	r.Latest[meta.Name] = meta
+	// 持久化成功后再切换路由
 	meta.UpdatedAt = time.Now()
 	if err := r.db.Save(meta).Error; err != nil { // gorm.Save会自动判断新增/更新
+		r.discardLocked(meta)
 		return fmt.Errorf("save to db: %w", err)
 	}
+	if meta.Alias != "" && meta.Alias != "latest" {
+		record := &FunctionAlias{FuncName: meta.Name, Alias: meta.Alias, Version: meta.Version}
+		if err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(record).Error; err != nil {
+			fmt.Printf("failed to save alias %s of %s: %v\n", meta.Alias, meta.Name, err)
+		}
+	}
 
-	// 更新内存映射
-	r.Latest[meta.Name] = meta
+	// 更新内存映射：版本、latest 与别名
 	r.VersionMap[versionKey] = meta
 	r.subdomainMap[meta.Subdomain] = versionKey
+	r.Latest[meta.Name] = meta
+	r.aliasMap[fmt.Sprintf("%s:latest", meta.Name)] = meta.Version
+	r.subdomainMap[r.generateAliasSubdomain(meta.Name, "latest")] = versionKey
+	if meta.Alias != "" {
+		r.aliasMap[fmt.Sprintf("%s:%s", meta.Name, meta.Alias)] = meta.Version
+		r.subdomainMap[r.generateAliasSubdomain(meta.Name, meta.Alias)] = versionKey // 别名子域名指向版本
+	}
 
+	// 迁移中删除的类不再被任何版本引用时删除其数据
+	if len(meta.DurableObjects.Deleted) > 0 {
+		r.pruneDurableLocked(meta.Name)
+	}
 	return nil
 }
 
+// discardLocked 清理未能上线的新版本：停止实例、删除版本文件并回收代码（调用方持有锁）
+func (r *Registry) discardLocked(meta *FunctionMetadata) {
+	if err := r.stopInstance(meta); err != nil {
+		fmt.Printf("failed to stop %s:%s: %v\n", meta.Name, meta.Version, err)
+	}
+	meta.Status = ""
+	r.removeVersionFiles(meta.Name, meta.Version)
+	if _, err := r.gcBlobsLocked(); err != nil {
+		fmt.Printf("blob gc failed: %v\n", err)
+	}
+}
+
 // Rollback 别名回滚
 func (r *Registry) Rollback(alias *string, funcName, targetVersion string) error {
 	r.Mu.Lock()
@@ -298,14 +385,21 @@ func (r *Registry) Rollback(alias *string, funcName, targetVersion string) error
 	targetKey := fmt.Sprintf("%s:%s", funcName, targetVersion)
 	targetMeta, exists := r.VersionMap[targetKey]
 	if !exists {
-		return errors.New("target version not found")
+		return ErrVersionNotFound
 	}
 
 	// 若目标版本进程未启动，尝试启动
 	if targetMeta.Workerd.Pid == 0 {
-		if err := r.StartWorkerd(targetMeta); err != nil {
+		freePort, err := util.GetFreePort()
+		if err != nil {
+			return fmt.Errorf("get free port: %w", err)
+		}
+		targetMeta.Workerd.Port = freePort
+		if err := r.StartInstance(context.Background(), targetMeta); err != nil {
 			return fmt.Errorf("start target version: %w", err)
 		}
+		targetMeta.Status = "running"
+		targetMeta.LastAccessed = time.Now()
 	}
 
 	// 后续别名更新逻辑
@@ -323,12 +417,32 @@ func (r *Registry) Rollback(alias *string, funcName, targetVersion string) error
 		*alias = targetMeta.Alias
 	}
 	r.Latest[funcName] = targetMeta
+	r.aliasMap[fmt.Sprintf("%s:latest", funcName)] = targetVersion
+	r.subdomainMap[r.generateAliasSubdomain(funcName, "latest")] = targetKey
 	aliasSubdomain := r.generateAliasSubdomain(funcName, *alias)
 	r.subdomainMap[aliasSubdomain] = targetKey
 
+	// 持久化：重启后 latest 取 UpdatedAt 最新的版本，回滚的目标版本需要刷新 UpdatedAt；
+	// 回滚时指定的别名与别名接口一样记录下来
+	targetMeta.UpdatedAt = time.Now()
+	if err := r.db.Model(targetMeta).UpdateColumn("updated_at", targetMeta.UpdatedAt).Error; err != nil {
+		return fmt.Errorf("save to db: %w", err)
+	}
+	if *alias != "" && *alias != "latest" && *alias != targetMeta.Alias {
+		record := &FunctionAlias{FuncName: funcName, Alias: *alias, Version: targetVersion}
+		if err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(record).Error; err != nil {
+			return fmt.Errorf("save alias: %w", err)
+		}
+	}
 	return nil
 }
 
+// saveStatusLocked 只持久化进程状态与端口（调用方持有锁）。
+// UpdateColumns 不更新 UpdatedAt，latest 的判定不受停止 / 启动影响
+func (r *Registry) saveStatusLocked(meta *FunctionMetadata) error {
+	return r.db.Model(meta).UpdateColumns(map[string]any{"status": meta.Status, "workerd": meta.Workerd}).Error
+}
+
 func (r *Registry) StopFunction(funcName, version string) error {
 	r.Mu.Lock()
 	defer r.Mu.Unlock()
@@ -336,17 +450,43 @@ func (r *Registry) StopFunction(funcName, version string) error {
 	targetKey := fmt.Sprintf("%s:%s", funcName, version)
 	meta, exists := r.VersionMap[targetKey]
 	if !exists {
-		return errors.New("function not found")
+		return ErrVersionNotFound
 	}
 	if meta.Status == "suspended" {
-		return errors.New("function has been stopped")
+		return ErrAlreadyStopped
 	}
 
-	if err := r.stopWorkerd(meta); err != nil {
+	if err := r.stopInstance(meta); err != nil {
 		return err
 	}
 	meta.Status = "suspended"
-	return r.db.Save(meta).Error
+	return r.saveStatusLocked(meta)
+}
+
+// StartFunction 启动已停止的版本
+func (r *Registry) StartFunction(ctx context.Context, funcName, version string) error {
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+
+	meta, exists := r.VersionMap[fmt.Sprintf("%s:%s", funcName, version)]
+	if !exists {
+		return ErrVersionNotFound
+	}
+	if meta.Status == "running" {
+		return ErrAlreadyRunning
+	}
+
+	freePort, err := util.GetFreePort()
+	if err != nil {
+		return fmt.Errorf("get free port: %w", err)
+	}
+	meta.Workerd.Port = freePort
+	if err := r.StartInstance(ctx, meta); err != nil {
+		return err
+	}
+	meta.Status = "running"
+	meta.LastAccessed = time.Now()
+	return r.saveStatusLocked(meta)
 }
 
 // DeleteFunction 删除整个函数（包括所有版本）
@@ -362,13 +502,13 @@ func (r *Registry) DeleteFunction(funcName string) error {
 	}
 
 	if len(versionsToDelete) == 0 {
-		return errors.New("function not found")
+		return ErrFunctionNotFound
 	}
 
 	// 停止所有版本进程并清理映射
 	for _, meta := range versionsToDelete {
 		// 停止进程
-		if err := r.stopWorkerd(meta); err != nil {
+		if err := r.stopInstance(meta); err != nil {
 			return fmt.Errorf("failed to stop version %s: %w", meta.Version, err)
 		}
 
@@ -386,7 +526,13 @@ func (r *Registry) DeleteFunction(funcName string) error {
 		// 从versionMap移除
 		versionKey := fmt.Sprintf("%s:%s", funcName, meta.Version)
 		delete(r.VersionMap, versionKey)
+		r.removeVersionFiles(funcName, meta.Version)
 	}
+	if err := os.RemoveAll(r.durableDir(funcName)); err != nil {
+		fmt.Printf("failed to remove durable object data of %s: %v\n", funcName, err)
+	}
+	r.removeDatabase(funcName)
+	os.Remove(filepath.Join(r.StorageDir, funcName))
 
 	// 清理latest别名
 	latestAliasKey := fmt.Sprintf("%s:latest", funcName)
@@ -395,13 +541,29 @@ func (r *Registry) DeleteFunction(funcName string) error {
 	delete(r.subdomainMap, latestSubdomain)
 
 	// 从数据库删除所有版本
-	if err := r.db.Where("name = ?", funcName).Delete(&FunctionMetadata{}).Error; err != nil {
+	if err := r.db.Unscoped().Where("name = ?", funcName).Delete(&FunctionMetadata{}).Error; err != nil {
 		return fmt.Errorf("database delete failed: %w", err)
 	}
 
+	// 清理限流规则与别名记录
+	if err := r.deleteRateLimitsLocked(funcName); err != nil {
+		return err
+	}
+	if err := r.deleteSchedulesLocked(funcName); err != nil {
+		return err
+	}
+	if err := r.deleteAliasesLocked(funcName); err != nil {
+		return err
+	}
+
 	// 从内存映射移除函数
 	delete(r.Latest, funcName)
 
+	// 回收不再被引用的代码
+	if _, err := r.gcBlobsLocked(); err != nil {
+		fmt.Printf("blob gc failed: %v\n", err)
+	}
+
 	return nil
 }
 
@@ -412,32 +574,30 @@ func (r *Registry) DeleteFunctionVersion(funcName, version string) error {
 	versionKey := fmt.Sprintf("%s:%s", funcName, version)
 	meta, exists := r.VersionMap[versionKey]
 	if !exists {
-		return errors.New("function version not found")
+		return ErrVersionNotFound
 	}
 
 	// 停止该版本的进程
-	if err := r.stopWorkerd(meta); err != nil {
+	if err := r.stopInstance(meta); err != nil {
 		return err
 	}
 
 	// 清理子域名映射
 	delete(r.subdomainMap, meta.Subdomain)
 
-	// 清理别名映射（如果该版本有别名）
-	if meta.Alias != "" {
-		aliasKey := fmt.Sprintf("%s:%s", funcName, meta.Alias)
-		delete(r.aliasMap, aliasKey)
-		aliasSubdomain := r.generateAliasSubdomain(funcName, meta.Alias)
-		delete(r.subdomainMap, aliasSubdomain)
+	// 清理指向该版本的全部别名（包括通过别名接口设置的）
+	if err := r.deleteVersionAliasesLocked(funcName, version); err != nil {
+		return err
 	}
 
 	// 从数据库删除该版本
-	if err := r.db.Where("name = ? AND version = ?", funcName, version).Delete(&FunctionMetadata{}).Error; err != nil {
+	if err := r.db.Unscoped().Where("name = ? AND version = ?", funcName, version).Delete(&FunctionMetadata{}).Error; err != nil {
 		return err
 	}
 
 	// 从内存映射中删除
 	delete(r.VersionMap, versionKey)
+	r.removeVersionFiles(funcName, version)
 
 	// 如果删除的是最新版本，需要重新计算最新版本
 	if r.Latest[funcName] != nil && r.Latest[funcName].Version == version {
@@ -461,10 +621,16 @@ func (r *Registry) DeleteFunctionVersion(funcName, version string) error {
 		}
 	}
 
+	// 回收不再被引用的代码与 Durable Object 数据
+	if _, err := r.gcBlobsLocked(); err != nil {
+		fmt.Printf("blob gc failed: %v\n", err)
+	}
+	r.pruneDurableLocked(funcName)
+
 	return nil
 }
 
-// 从数据库加载函数元数据并启动进程
+// 从数据库加载函数元数据。实例进程不在加载时启动，全部版本标记为挂起，收到请求时再唤醒
 func (r *Registry) loadFromDB() error {
 	r.Mu.Lock()
 	defer r.Mu.Unlock()
@@ -477,20 +643,19 @@ func (r *Registry) loadFromDB() error {
 	latestVersions := make(map[string]string)
 
 	for _, meta := range metas {
-		// 重置端口（避免重启后端口冲突）
-		meta.Workerd.Port = 0
-		freePort, err := util.GetFreePort()
-		if err != nil {
-			fmt.Printf("failed to get free port for function %s: %v\n", meta.Name, err)
+		if err := r.loadContent(meta); err != nil {
+			fmt.Printf("failed to load code of %s:%s: %v\n", meta.Name, meta.Version, err)
 			continue
 		}
-		meta.Workerd.Port = freePort
-		// 重新生成配置文件并启动进程
-		if err := r.StartWorkerd(meta); err != nil {
-			// 记录启动失败的函数，但继续加载其他函数
-			fmt.Printf("failed to restart function %s: %v\n", meta.Name, err)
-			continue
+		// 重置端口与 PID（上次运行的进程已随服务退出）
+		meta.Workerd.Port = 0
+		meta.Workerd.Pid = 0
+		meta.Status = "suspended" // 重启后默认挂起
+		meta.LastAccessed = time.Time{}
+		if err := r.saveStatusLocked(meta); err != nil {
+			fmt.Printf("failed to reset status of %s:%s: %v\n", meta.Name, meta.Version, err)
 		}
+
 		// 重建 versionMap
 		versionKey := fmt.Sprintf("%s:%s", meta.Name, meta.Version)
 		r.VersionMap[versionKey] = meta
@@ -511,11 +676,6 @@ func (r *Registry) loadFromDB() error {
 			r.Latest[meta.Name] = meta
 			latestVersions[meta.Name] = meta.Version // 记录最新版本
 		}
-
-		meta.Status = "suspended" // 重启后默认挂起
-		meta.LastAccessed = time.Time{}
-
-		r.db.Save(meta)
 	}
 
 	for funcName, latestVersion := range latestVersions {
@@ -530,6 +690,16 @@ func (r *Registry) loadFromDB() error {
 		}
 	}
 
+	if err := r.loadAliasesLocked(); err != nil {
+		return err
+	}
+	if err := r.loadRateLimitsLocked(); err != nil {
+		return err
+	}
+	if err := r.loadSchedulesLocked(); err != nil {
+		return err
+	}
+
 	fmt.Printf("loaded %d functions from database\n", len(r.Latest))
 	return nil
 }
@@ -541,31 +711,34 @@ func (r *Registry) DeleteVersion(funcName, version string) error {
 	versionKey := fmt.Sprintf("%s:%s", funcName, version)
 	meta, exists := r.VersionMap[versionKey]
 	if !exists {
-		return errors.New("version not found")
+		return ErrVersionNotFound
 	}
 
 	// 停止进程
-	if err := r.stopWorkerd(meta); err != nil {
+	if err := r.stopInstance(meta); err != nil {
 		return fmt.Errorf("stop version: %w", err)
 	}
 
 	// 从数据库删除
-	if err := r.db.Where("name = ? AND version = ?", funcName, version).Delete(&FunctionMetadata{}).Error; err != nil {
+	if err := r.db.Unscoped().Where("name = ? AND version = ?", funcName, version).Delete(&FunctionMetadata{}).Error; err != nil {
 		return fmt.Errorf("delete from db: %w", err)
 	}
 
 	// 清理映射
 	delete(r.VersionMap, versionKey)
 	delete(r.subdomainMap, meta.Subdomain)
+	r.removeVersionFiles(funcName, version)
 
 	// 清理别名
-	for aliasKey, v := range r.aliasMap {
-		if v == version && strings.HasPrefix(aliasKey, funcName+":") {
-			aliasSubdomain := r.generateAliasSubdomain(funcName, strings.TrimPrefix(aliasKey, funcName+":"))
-			delete(r.subdomainMap, aliasSubdomain)
-			delete(r.aliasMap, aliasKey)
-		}
+	if err := r.deleteVersionAliasesLocked(funcName, version); err != nil {
+		return err
+	}
+
+	// 回收不再被引用的代码与 Durable Object 数据
+	if _, err := r.gcBlobsLocked(); err != nil {
+		fmt.Printf("blob gc failed: %v\n", err)
 	}
+	r.pruneDurableLocked(funcName)
 
 	return nil
 }
@@ -627,22 +800,58 @@ func (r *Registry) generateAliasSubdomain(funcName, alias string) string {
 	return fmt.Sprintf("%s.%s.func.local", alias, funcName)
 }
 
+// InstanceCounts 按进程状态统计版本数量
+func (r *Registry) InstanceCounts() map[string]int {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+
+	counts := map[string]int{"running": 0, "suspended": 0}
+	for _, meta := range r.VersionMap {
+		if meta.Status == "running" {
+			counts["running"]++
+		} else {
+			counts["suspended"]++
+		}
+	}
+	return counts
+}
+
 // 检查超时函数
 func (r *Registry) checkTimeouts() {
-	for range r.ticker.C {
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.ticker.C:
+		}
 		r.Mu.Lock()
 		for _, meta := range r.VersionMap {
+			// 进程异常退出：回收并标记为挂起，下次请求时重新启动
+			if meta.Status == "running" {
+				if err := r.healthLocked(meta); err != nil {
+					fmt.Printf("%s:%s is unhealthy: %v\n", meta.Name, meta.Version, err)
+					if err := r.stopInstance(meta); err != nil {
+						fmt.Printf("failed to reap %s:%s %v\n", meta.Name, meta.Version, err)
+					}
+					if meta.oomKilled {
+						r.recordOOMLocked(meta)
+					}
+					meta.Status = "suspended"
+					continue
+				}
+			}
 			if meta.Version == r.Latest[meta.Name].Version {
 				continue
 			}
 			if meta.Status == "running" &&
 				time.Since(meta.LastAccessed) > 5*time.Minute {
 				// 5分钟无访问，挂起进程
-				if err := r.stopWorkerd(meta); err != nil {
+				if err := r.stopInstance(meta); err != nil {
 					fmt.Printf("failed to suspend %s:%s %v\n", meta.Name, meta.Version, err)
 					continue
 				}
 				meta.Status = "suspended"
+				metrics.IncSuspend(meta.Name, meta.Version)
 				fmt.Printf("suspended %s:%s due to inactivity\n", meta.Name, meta.Version)
 			}
 		}
@@ -650,21 +859,6 @@ func (r *Registry) checkTimeouts() {
 	}
 }
 
-// 生成环境变量
-func generateWorkerdEnv(meta *FunctionMetadata) string {
-	if meta.EnvVars == nil || len(meta.EnvVars) == 0 {
-		return ""
-	}
-	var bindings []string
-	for key, value := range meta.EnvVars {
-		escapedValue := strings.ReplaceAll(value, `"`, `\"`)
-		escapedValue = strings.ReplaceAll(escapedValue, "\n", "\\n")
-		bindings = append(bindings,
-			fmt.Sprintf(`( name = "%s", text = "%s" )`, key, escapedValue))
-	}
-	return strings.Join(bindings, ",\n")
-}
-
 // 实现gorm.Valuer接口，将WorkerdConfig转换为JSON字符串
 func (w WorkerdConfig) Value() (driver.Value, error) {
 	return json.Marshal(w)
@@ -679,6 +873,9 @@ func (w *WorkerdConfig) Scan(value interface{}) error {
 	return json.Unmarshal(b, &w)
 }
 
+// ModuleSet 模块路径 -> 内容（base64 编码，支持二进制模块）
+type ModuleSet map[string][]byte
+
 // 自定义 JSON 类型做映射
 type JSONMap map[string]string
 
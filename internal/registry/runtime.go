@@ -0,0 +1,318 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"faas/internal/metrics"
+	"faas/internal/tracing"
+	"faas/internal/util"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Runtime 函数运行后端。注册表负责分配端口、维护状态与路由，后端负责生成产物与管理实例进程
+type Runtime interface {
+	// Prepare 在版本目录中生成运行所需的产物（配置、代码等），每次启动前调用
+	Prepare(meta *FunctionMetadata) error
+	// Start 在 meta.Workerd.Port 上启动实例并等待就绪，记录进程 PID
+	Start(ctx context.Context, meta *FunctionMetadata) error
+	// Stop 停止实例（未启动或已退出时直接返回）
+	Stop(meta *FunctionMetadata) error
+	// Health 检查实例进程是否存活且端口可以连接
+	Health(meta *FunctionMetadata) error
+	// Address 实例的 HTTP 地址（host:port）
+	Address(meta *FunctionMetadata) string
+}
+
+// RegisterRuntime 注册运行后端，部署请求的 runtime 字段按名称选择后端（同名覆盖）
+func (r *Registry) RegisterRuntime(name string, rt Runtime) {
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+	r.runtimes[name] = rt
+}
+
+// HasRuntime 是否注册了指定名称的运行后端
+func (r *Registry) HasRuntime(name string) bool {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+	_, exists := r.runtimes[name]
+	return exists
+}
+
+// runtimeFor 查找版本使用的运行后端（调用方持有锁）
+func (r *Registry) runtimeFor(meta *FunctionMetadata) (Runtime, error) {
+	rt, exists := r.runtimes[meta.Runtime]
+	if !exists {
+		return nil, invalidf("runtime %s is not enabled", meta.Runtime)
+	}
+	return rt, nil
+}
+
+// StartInstance 生成产物并启动版本实例（调用方持有锁并已分配 meta.Workerd.Port）。
+// 启动失败时返回 *StartError（含解析出的错误位置与实例输出），并记录为版本的 LastStartError
+func (r *Registry) StartInstance(ctx context.Context, meta *FunctionMetadata) (err error) {
+	ctx, span := tracing.Start(ctx, "StartInstance", append(versionAttrs(meta), attribute.String("faas.runtime", meta.Runtime))...)
+	startAt := time.Now()
+	defer func() {
+		metrics.ObserveColdStart(meta.Name, meta.Version, time.Since(startAt), err)
+		tracing.End(span, err)
+	}()
+
+	rt, err := r.runtimeFor(meta)
+	if err != nil {
+		return err
+	}
+	err = rt.Prepare(meta)
+	if err == nil {
+		err = rt.Start(ctx, meta)
+	}
+	if err != nil {
+		startErr := r.newStartError(meta, StageStart, err)
+		r.recordStartErrorLocked(meta, startErr)
+		return startErr
+	}
+	return nil
+}
+
+// stopInstance 停止版本实例；后端未注册（如配置变更后）时按进程直接停止
+func (r *Registry) stopInstance(meta *FunctionMetadata) error {
+	if rt, err := r.runtimeFor(meta); err == nil {
+		return rt.Stop(meta)
+	}
+	return stopProcess(meta)
+}
+
+// Address 版本实例的 HTTP 地址，供反向代理转发
+func (r *Registry) Address(meta *FunctionMetadata) string {
+	if rt, err := r.runtimeFor(meta); err == nil {
+		return rt.Address(meta)
+	}
+	return processAddress(meta)
+}
+
+// healthLocked 检查版本实例是否健康（调用方持有锁）
+func (r *Registry) healthLocked(meta *FunctionMetadata) error {
+	rt, err := r.runtimeFor(meta)
+	if err != nil {
+		return err
+	}
+	return rt.Health(meta)
+}
+
+// startProcess 启动实例进程并等待端口监听：输出写入版本日志，并保留末尾部分用于启动失败时的错误信息；
+// 等待失败时结束进程
+func (r *Registry) startProcess(ctx context.Context, meta *FunctionMetadata, cmd *exec.Cmd) error {
+	// 重定向日志到文件（按大小轮转）
+	logFile := r.LogWriter(meta.Name, meta.Version)
+	meta.output = newOutputBuffer(outputBufferLimit)
+	cmd.Stdout = io.MultiWriter(os.Stdout, logFile, meta.output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, logFile, meta.output)
+
+	// 隔离运行：改为在新的命名空间中启动初始化进程，由它构建隔离环境后运行实例
+	sandbox, err := r.prepareSandbox(meta, cmd)
+	if err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+
+	// 资源限制：cgroup v2 可用时进程创建即放入实例 cgroup，否则启动后以 rlimit 限制
+	meta.oomKilled = false
+	limits, err := prepareLimits(meta, cmd)
+	if err != nil {
+		sandbox.close()
+		return fmt.Errorf("resource limits: %w", err)
+	}
+
+	// 启动进程并记录 PID；进程由后台 goroutine 回收，退出时关闭 exited
+	if err := cmd.Start(); err != nil {
+		sandbox.close()
+		limits.release()
+		return fmt.Errorf("start %s: %w", meta.Runtime, err)
+	}
+	meta.Workerd.Pid = cmd.Process.Pid
+	exited := make(chan struct{})
+	meta.exited = exited
+	go func() {
+		cmd.Wait()
+		meta.oomKilled = limits.release()
+		close(exited)
+	}()
+	// 资源限制设置完成后隔离环境才开始运行实例，避免实例在限制生效前启动
+	if err := limits.started(cmd.Process.Pid); err != nil {
+		sandbox.close()
+		cmd.Process.Kill()
+		<-exited
+		meta.Workerd.Pid = 0
+		return fmt.Errorf("resource limits: %w", err)
+	}
+	if err := sandbox.started(); err != nil {
+		cmd.Process.Kill()
+		<-exited
+		meta.Workerd.Pid = 0
+		return fmt.Errorf("sandbox: %w", err)
+	}
+
+	// 等待端口监听成功，进程提前退出（如配置或脚本错误）时不再等待；
+	// 隔离网络时等待实例的 unix socket，再由平台在分配的端口上转发
+	_, waitSpan := tracing.Start(ctx, "WaitPortListening", attribute.Int("faas.port", meta.Workerd.Port))
+	if socket := sandbox.socketPath(); socket != "" {
+		err = util.WaitListening("unix", socket, exited)
+		if err == nil {
+			err = relaySocket(processAddress(meta), socket, exited)
+		}
+	} else {
+		err = util.WaitPortListening("127.0.0.1", meta.Workerd.Port, exited)
+	}
+	tracing.End(waitSpan, err)
+	if errors.Is(err, util.ErrWaitAborted) {
+		meta.Workerd.Pid = 0
+		if meta.oomKilled {
+			r.recordOOMLocked(meta)
+			return fmt.Errorf("%s exceeded its memory limit (%d MiB) during startup", meta.Runtime, meta.Resources.MemoryMB)
+		}
+		return fmt.Errorf("%s exited during startup: %s", meta.Runtime, cmd.ProcessState)
+	}
+	if err != nil {
+		cmd.Process.Kill() // 启动失败，清理进程
+		<-exited
+		meta.Workerd.Pid = 0
+		return fmt.Errorf("wait port: %w", err)
+	}
+	return nil
+}
+
+// outputBufferLimit 每个实例保留的输出上限
+const outputBufferLimit = 16 << 10
+
+// outputBuffer 只保留最近 limit 字节的并发安全缓冲区
+type outputBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func newOutputBuffer(limit int) *outputBuffer {
+	return &outputBuffer{limit: limit}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.limit:]...)
+	}
+	return len(p), nil
+}
+
+// String 缓冲区内容，未启动过进程（nil）时为空
+func (b *outputBuffer) String() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// stopTimeout 发送 SIGTERM 后等待进程退出的时间，超时后强制结束
+const stopTimeout = 5 * time.Second
+
+// stopProcess 向实例进程发送 SIGTERM 并等待退出
+func stopProcess(meta *FunctionMetadata) error {
+	if meta.Workerd.Pid == 0 {
+		return nil // 进程未启动
+	}
+
+	process, err := os.FindProcess(meta.Workerd.Pid)
+	if err != nil {
+		return fmt.Errorf("find process: %w", err)
+	}
+
+	// 进程由 startProcess 的 goroutine 回收，这里只发送信号并等待 exited 关闭
+	if meta.exited != nil {
+		select {
+		case <-meta.exited: // 已经结束
+		default:
+			if err := process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+				return fmt.Errorf("send signal: %w", err)
+			}
+			select {
+			case <-meta.exited:
+			case <-time.After(stopTimeout):
+				process.Kill()
+				<-meta.exited
+			}
+		}
+		meta.Workerd.Port = 0
+		meta.Workerd.Pid = 0
+		return nil
+	}
+
+	// 检查进程是否还活着
+	err = process.Signal(syscall.Signal(0))
+	if err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			// 进程已经结束，不需要停止
+			meta.Workerd.Pid = 0
+			return nil
+		}
+		return fmt.Errorf("check process: %w", err)
+	}
+
+	// 发送终止信号
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			// 已经结束
+			meta.Workerd.Pid = 0
+			return nil
+		}
+		return fmt.Errorf("send signal: %w", err)
+	}
+
+	// 等待进程退出
+	_, err = process.Wait()
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("wait exit: %w", err)
+	}
+
+	meta.Workerd.Port = 0
+	meta.Workerd.Pid = 0
+	return nil
+}
+
+// processHealth 进程存在且本地端口可以连接
+func processHealth(meta *FunctionMetadata) error {
+	if meta.Workerd.Pid == 0 {
+		return errors.New("process not started")
+	}
+	select {
+	case <-meta.exited:
+		return fmt.Errorf("process %d exited", meta.Workerd.Pid)
+	default:
+	}
+	process, err := os.FindProcess(meta.Workerd.Pid)
+	if err != nil {
+		return fmt.Errorf("find process: %w", err)
+	}
+	if err := process.Signal(syscall.Signal(0)); err != nil {
+		return fmt.Errorf("process %d: %w", meta.Workerd.Pid, err)
+	}
+	conn, err := net.DialTimeout("tcp", processAddress(meta), time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// processAddress 实例监听的本地地址
+func processAddress(meta *FunctionMetadata) string {
+	return fmt.Sprintf("127.0.0.1:%d", meta.Workerd.Port)
+}
@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+)
+
+// 隔离模式
+const (
+	SandboxOn  = "on"  // 在独立的 user / mount / pid 命名空间中运行实例
+	SandboxOff = "off" // 以平台进程的用户直接运行
+)
+
+// 隔离模式下的网络
+const (
+	NetworkHost     = "host"     // 与平台共用网络
+	NetworkIsolated = "isolated" // 独立网络命名空间：实例只能通过 unix socket 被平台访问，出站请求只能经由平台的出站代理（声明了出站规则时）
+)
+
+// SandboxDefault 部署时未声明的隔离设置使用的全局默认值
+var SandboxDefault = SandboxConfig{Mode: SandboxOff, Network: NetworkHost}
+
+// SandboxConfig 实例进程的隔离设置，字段为空时使用 SandboxDefault。
+// 开启后实例运行在独立的 user / mount / pid（可选 network）命名空间中，只能看到只读的版本目录与系统库，
+// 没有任何 capability，并由 seccomp 禁止挂载、ptrace、加载内核模块等系统调用
+type SandboxConfig struct {
+	Mode    string `json:"mode,omitempty" binding:"omitempty,oneof=on off"`
+	Network string `json:"network,omitempty" binding:"omitempty,oneof=host isolated"`
+}
+
+// resolve 以全局默认值补全未声明的字段
+func (c SandboxConfig) resolve() SandboxConfig {
+	if c.Mode == "" {
+		c.Mode = SandboxDefault.Mode
+	}
+	if c.Network == "" {
+		c.Network = SandboxDefault.Network
+	}
+	return c
+}
+
+// Enabled 是否隔离运行
+func (c SandboxConfig) Enabled() bool {
+	return c.resolve().Mode == SandboxOn
+}
+
+// IsolateNetwork 是否隔离网络（仅在开启隔离时生效）
+func (c SandboxConfig) IsolateNetwork() bool {
+	c = c.resolve()
+	return c.Mode == SandboxOn && c.Network == NetworkIsolated
+}
+
+// checkSandbox 检查版本的隔离设置是否受后端支持：exec 后端的进程自行监听 TCP 端口，无法隔离网络
+func checkSandbox(meta *FunctionMetadata) error {
+	if meta.Runtime == ExecRuntimeName && meta.Sandbox.IsolateNetwork() {
+		return invalidf("network isolation is not supported by the %s runtime, set sandbox.network to %s", ExecRuntimeName, NetworkHost)
+	}
+	return nil
+}
+
+// sandboxSocketPath 隔离网络时实例监听的 unix socket（位于版本目录的 run/ 下，隔离环境内外路径相同）；
+// 不隔离网络时为空
+func (r *Registry) sandboxSocketPath(meta *FunctionMetadata) (string, error) {
+	if !meta.Sandbox.IsolateNetwork() {
+		return "", nil
+	}
+	dir, err := filepath.Abs(r.versionDir(meta.Name, meta.Version))
+	if err != nil {
+		return "", fmt.Errorf("resolve version dir: %w", err)
+	}
+	path := filepath.Join(dir, "run", "http.sock")
+	if len(path) >= 108 { // sockaddr_un.sun_path 的长度限制
+		return "", fmt.Errorf("socket path %s is too long", path)
+	}
+	return path, nil
+}
+
+// relaySocket 在平台的网络中监听 address，把连接转发到实例的 unix socket，直到 done 关闭
+func relaySocket(address, socketPath string, done <-chan struct{}) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", address, err)
+	}
+	go func() {
+		<-done
+		ln.Close()
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go relayConn(conn, socketPath)
+		}
+	}()
+	return nil
+}
+
+func relayConn(conn net.Conn, socketPath string) {
+	defer conn.Close()
+	upstream, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.(*net.UnixConn).CloseWrite()
+	}()
+	io.Copy(conn, upstream)
+}
@@ -0,0 +1,426 @@
+//go:build linux
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxInitArg 隔离环境初始化进程的 argv[0]：平台在新的命名空间中重新执行自身，由 SandboxInit 接管
+const sandboxInitArg = "faas-sandbox-init"
+
+// sandboxSystemPaths 以只读方式提供给实例的系统目录与文件（动态库、解释器、DNS 与证书配置），不存在的跳过
+var sandboxSystemPaths = []string{
+	"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32",
+	"/etc/ld.so.cache", "/etc/ld.so.conf", "/etc/ld.so.conf.d", "/etc/alternatives",
+	"/etc/resolv.conf", "/etc/hosts", "/etc/nsswitch.conf", "/etc/host.conf", "/etc/gai.conf",
+	"/etc/ssl", "/etc/ca-certificates", "/etc/pki", "/etc/localtime", "/etc/passwd", "/etc/group",
+}
+
+// sandboxDevices 提供给实例的设备文件
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+
+// sandboxSpec 平台通过管道（初始化进程的 fd 3）传递的启动参数
+type sandboxSpec struct {
+	Root           string   `json:"root"` // 新根目录（tmpfs）的挂载点
+	Path           string   `json:"path"`
+	Args           []string `json:"args"`
+	Env            []string `json:"env"`
+	Dir            string   `json:"dir"`
+	ReadOnly       []string `json:"read_only"` // 以相同路径只读挂载的宿主机路径
+	Writable       []string `json:"writable"`  // 以相同路径可写挂载的宿主机路径
+	IsolateNetwork bool     `json:"isolate_network"`
+}
+
+// sandboxProcess 一次隔离启动：进程启动并设置好资源限制后才通过管道发送参数，初始化进程收到后再执行实例
+type sandboxProcess struct {
+	spec   sandboxSpec
+	reader *os.File // 管道读端（传给初始化进程）
+	writer *os.File
+	socket string // 隔离网络时实例监听的 unix socket
+}
+
+// prepareSandbox 按版本的隔离设置改写 cmd：改为在新的 user / mount / pid 命名空间中执行平台自身的初始化进程，
+// 实例的路径、参数与环境变量在启动后通过管道传递；未开启隔离时返回 nil
+func (r *Registry) prepareSandbox(meta *FunctionMetadata, cmd *exec.Cmd) (*sandboxProcess, error) {
+	if !meta.Sandbox.Enabled() {
+		return nil, nil
+	}
+	if err := checkSandbox(meta); err != nil {
+		return nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate executable: %w", err)
+	}
+	versionDir, err := filepath.Abs(r.versionDir(meta.Name, meta.Version))
+	if err != nil {
+		return nil, fmt.Errorf("resolve version dir: %w", err)
+	}
+	root, err := filepath.Abs(filepath.Join(r.StorageDir, ".sandbox"))
+	if err != nil {
+		return nil, fmt.Errorf("resolve sandbox root: %w", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("create sandbox root: %w", err)
+	}
+	path, err := filepath.Abs(cmd.Path)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", cmd.Path, err)
+	}
+
+	spec := sandboxSpec{
+		Root:           root,
+		Path:           path,
+		Args:           cmd.Args,
+		Env:            cmd.Env,
+		Dir:            cmd.Dir,
+		IsolateNetwork: meta.Sandbox.IsolateNetwork(),
+	}
+	if spec.Env == nil {
+		spec.Env = os.Environ()
+	}
+	if spec.Dir == "" {
+		spec.Dir = versionDir
+	}
+	for _, p := range sandboxSystemPaths {
+		if _, err := os.Stat(p); err == nil {
+			spec.ReadOnly = append(spec.ReadOnly, p)
+		}
+	}
+	spec.ReadOnly = append(spec.ReadOnly, versionDir)
+	if !underAny(path, spec.ReadOnly) {
+		spec.ReadOnly = append(spec.ReadOnly, path)
+	}
+
+	// 隔离网络时实例只监听版本目录下的 unix socket，由平台转发到分配的端口
+	socket, err := r.sandboxSocketPath(meta)
+	if err != nil {
+		return nil, err
+	}
+	if socket != "" {
+		if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+			return nil, fmt.Errorf("create socket dir: %w", err)
+		}
+		if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+		spec.Writable = append(spec.Writable, filepath.Dir(socket))
+	}
+	// Durable Object 数据目录可写
+	if len(meta.DurableObjects.Classes) > 0 {
+		dir, err := filepath.Abs(r.durableDir(meta.Name))
+		if err != nil {
+			return nil, fmt.Errorf("resolve durable object dir: %w", err)
+		}
+		spec.Writable = append(spec.Writable, dir)
+	}
+	// 隔离网络时出站请求、服务绑定与数据库绑定只能经由内部监听的 unix socket
+	if spec.IsolateNetwork && usesInternal(meta) && r.internal.socket != "" {
+		spec.ReadOnly = append(spec.ReadOnly, filepath.Dir(r.internal.socket))
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create pipe: %w", err)
+	}
+	cmd.Path = self
+	cmd.Args = []string{sandboxInitArg}
+	cmd.Env = []string{}
+	cmd.Dir = ""
+	cmd.ExtraFiles = []*os.File{reader}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// 命名空间内的 root 映射为平台进程的用户，没有任何额外权限
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Geteuid(), Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getegid(), Size: 1}}
+	cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+	return &sandboxProcess{spec: spec, reader: reader, writer: writer, socket: socket}, nil
+}
+
+// underAny path 是否位于 dirs 中某个目录之下
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// started 进程启动后发送启动参数，初始化进程收到后才开始构建隔离环境
+func (s *sandboxProcess) started() error {
+	if s == nil {
+		return nil
+	}
+	s.reader.Close()
+	defer s.writer.Close()
+	if err := json.NewEncoder(s.writer).Encode(s.spec); err != nil {
+		return fmt.Errorf("send sandbox spec: %w", err)
+	}
+	return nil
+}
+
+// close 进程未能启动时关闭管道
+func (s *sandboxProcess) close() {
+	if s == nil {
+		return
+	}
+	s.reader.Close()
+	s.writer.Close()
+}
+
+// socketPath 隔离网络时实例监听的 unix socket，否则为空
+func (s *sandboxProcess) socketPath() string {
+	if s == nil {
+		return ""
+	}
+	return s.socket
+}
+
+// SandboxInit 隔离环境初始化进程的入口：当前进程是平台以 sandboxInitArg 启动的初始化进程时接管并不再返回，
+// 否则直接返回。使用注册表的程序（包括测试）需要在 main / TestMain 开头调用
+func SandboxInit() {
+	if len(os.Args) == 0 || os.Args[0] != sandboxInitArg {
+		return
+	}
+	// 权限、seccomp 与网络命名空间都是线程级的，全部在同一线程上设置，实例进程从该线程创建
+	runtime.LockOSThread()
+	code, err := runSandboxInit()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+// runSandboxInit 构建隔离环境并运行实例进程；作为 pid 命名空间的 init 转发终止信号、回收子进程，
+// 返回实例进程的退出码
+func runSandboxInit() (int, error) {
+	specFile := os.NewFile(3, "sandbox-spec")
+	var spec sandboxSpec
+	if err := json.NewDecoder(specFile).Decode(&spec); err != nil {
+		return 0, fmt.Errorf("read spec: %w", err)
+	}
+	specFile.Close()
+
+	if err := setupSandboxRoot(&spec); err != nil {
+		return 0, err
+	}
+	if spec.IsolateNetwork {
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			return 0, fmt.Errorf("unshare network: %w", err)
+		}
+	}
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT)
+	if err := dropPrivileges(); err != nil {
+		return 0, err
+	}
+
+	pid, err := syscall.ForkExec(spec.Path, spec.Args, &syscall.ProcAttr{
+		Dir:   spec.Dir,
+		Env:   spec.Env,
+		Files: []uintptr{0, 1, 2},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("exec %s: %w", spec.Path, err)
+	}
+	go func() {
+		for sig := range signals {
+			syscall.Kill(pid, sig.(syscall.Signal))
+		}
+	}()
+	for {
+		var status syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &status, 0, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("wait: %w", err)
+		}
+		if wpid != pid {
+			continue
+		}
+		if status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return status.ExitStatus(), nil
+	}
+}
+
+// setupSandboxRoot 以 tmpfs 为新的根目录，挂载可写的 /tmp、系统库、版本目录、设备文件与新的 /proc，
+// 切换根目录后根目录设为只读
+func setupSandboxRoot(spec *sandboxSpec) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	root := spec.Root
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755,size=1m"); err != nil {
+		return fmt.Errorf("mount root: %w", err)
+	}
+	// /tmp 先于其他挂载：版本目录等可能位于 /tmp 下，挂载点建在新的 /tmp 中
+	if err := mountAt(root, "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777,size=64m"); err != nil {
+		return err
+	}
+	for _, path := range spec.ReadOnly {
+		if err := bindPath(root, path, true); err != nil {
+			return err
+		}
+	}
+	for _, path := range spec.Writable {
+		if err := bindPath(root, path, false); err != nil {
+			return err
+		}
+	}
+	for _, dev := range sandboxDevices {
+		if _, err := os.Stat(dev); err == nil {
+			if err := bindPath(root, dev, false); err != nil {
+				return err
+			}
+		}
+	}
+	for name, target := range map[string]string{"fd": "/proc/self/fd", "stdin": "/proc/self/fd/0", "stdout": "/proc/self/fd/1", "stderr": "/proc/self/fd/2"} {
+		if err := os.Symlink(target, filepath.Join(root, "dev", name)); err != nil {
+			return fmt.Errorf("link /dev/%s: %w", name, err)
+		}
+	}
+	if err := mountAt(root, "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC|unix.MS_RDONLY, ""); err != nil {
+		return err
+	}
+
+	// 切换根目录并卸载原有的文件系统
+	if err := unix.Chdir(root); err != nil {
+		return fmt.Errorf("chdir root: %w", err)
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot root: %w", err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount root read-only: %w", err)
+	}
+	if err := unix.Chdir(spec.Dir); err != nil {
+		return fmt.Errorf("chdir %s: %w", spec.Dir, err)
+	}
+	return nil
+}
+
+// bindPath 把宿主机路径以相同路径挂载到新根目录下。指向目录的符号链接（如 /bin -> usr/bin）按原样重建
+func bindPath(root, path string, readOnly bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	target := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	if link, err := os.Lstat(path); err == nil && link.Mode()&os.ModeSymlink != 0 && info.IsDir() {
+		dest, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("read link %s: %w", path, err)
+		}
+		return os.Symlink(dest, target)
+	}
+
+	if info.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else if _, statErr := os.Stat(target); statErr != nil {
+		err = os.WriteFile(target, nil, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("create mount point %s: %w", path, err)
+	}
+	if err := unix.Mount(path, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", path, err)
+	}
+	if readOnly {
+		if err := makeReadOnly(target); err != nil {
+			return fmt.Errorf("make %s read-only: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// makeReadOnly 将挂载点（包括其下的子挂载）设为只读；内核不支持 mount_setattr（< 5.12）时只重新挂载顶层
+func makeReadOnly(target string) error {
+	err := unix.MountSetattr(unix.AT_FDCWD, target, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+	if !errors.Is(err, unix.ENOSYS) {
+		return err
+	}
+	// 用户命名空间中重新挂载必须保留原有的 nosuid / nodev / noexec 等标志
+	var st unix.Statfs_t
+	if err := unix.Statfs(target, &st); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND | unix.MS_RDONLY)
+	for _, f := range []uintptr{unix.MS_NOSUID, unix.MS_NODEV, unix.MS_NOEXEC, unix.MS_NOATIME, unix.MS_NODIRATIME} {
+		if uintptr(st.Flags)&f != 0 { // ST_* 与 MS_* 的取值相同
+			flags |= f
+		}
+	}
+	if st.Flags&unix.ST_RELATIME != 0 {
+		flags |= unix.MS_RELATIME
+	}
+	return unix.Mount("", target, "", flags, "")
+}
+
+// mountAt 在新根目录下创建目录并挂载文件系统
+func mountAt(root, path, fstype string, flags uintptr, data string) error {
+	target := filepath.Join(root, path)
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if err := unix.Mount(fstype, target, fstype, flags, data); err != nil {
+		return fmt.Errorf("mount %s: %w", path, err)
+	}
+	return nil
+}
+
+// dropPrivileges 清空当前线程的 capability（包括 bounding set，执行实例后也无法重新获得），
+// 禁止获取新权限并安装 seccomp 过滤器
+func dropPrivileges() error {
+	last := unix.CAP_LAST_CAP
+	if data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			last = n
+		}
+	}
+	for c := 0; c <= last; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil && !errors.Is(err, unix.EINVAL) {
+			return fmt.Errorf("drop capability %d: %w", c, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil && !errors.Is(err, unix.EINVAL) {
+		return fmt.Errorf("clear ambient capabilities: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	if err := installSeccomp(); err != nil {
+		return err
+	}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}, &data[0]); err != nil {
+		return fmt.Errorf("clear capabilities: %w", err)
+	}
+	return nil
+}
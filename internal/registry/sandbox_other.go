@@ -0,0 +1,32 @@
+//go:build !linux
+
+package registry
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// sandboxProcess 非 Linux 平台不支持隔离运行
+type sandboxProcess struct{}
+
+// prepareSandbox 非 Linux 平台无法隔离，开启隔离的版本启动失败（不以未隔离的方式运行）
+func (r *Registry) prepareSandbox(meta *FunctionMetadata, cmd *exec.Cmd) (*sandboxProcess, error) {
+	if meta.Sandbox.Enabled() {
+		return nil, errors.New("sandbox requires Linux")
+	}
+	return nil, nil
+}
+
+func (s *sandboxProcess) started() error {
+	return nil
+}
+
+func (s *sandboxProcess) close() {}
+
+func (s *sandboxProcess) socketPath() string {
+	return ""
+}
+
+// SandboxInit 非 Linux 平台没有隔离环境的初始化进程，直接返回
+func SandboxInit() {}
@@ -0,0 +1,249 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduleHeader 定时触发的请求带有该请求头，值为触发的 cron 表达式
+const ScheduleHeader = "X-Faas-Schedule"
+
+// scheduleTimeout 单次定时触发的最长执行时间
+const scheduleTimeout = time.Minute
+
+// Schedule 定时触发：按 Cron 以 POST 请求调用函数 latest 版本的 Path
+type Schedule struct {
+	ID         uint       `gorm:"primaryKey" json:"-"`
+	FuncName   string     `gorm:"uniqueIndex:idx_schedule_target;not null" json:"func_name"`
+	Cron       string     `gorm:"uniqueIndex:idx_schedule_target;not null" json:"cron"`
+	Path       string     `gorm:"uniqueIndex:idx_schedule_target;not null" json:"path"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"` // 最近一次触发时间
+	LastStatus int        `json:"last_status,omitempty"` // 最近一次触发的响应状态码
+	LastError  string     `json:"last_error,omitempty"`  // 最近一次触发失败的原因
+	CreatedAt  time.Time  `json:"created_at"`
+	spec       *cronSpec  `gorm:"-"`
+}
+
+// Key 定时任务唯一标识（同一函数内 cron + path）
+func (s *Schedule) Key() string {
+	return s.Cron + " " + s.Path
+}
+
+// normalize 校验表达式与路径，路径为空时为 /
+func (s *Schedule) normalize() error {
+	spec, err := parseCron(s.Cron)
+	if err != nil {
+		return invalidf("%v", err)
+	}
+	s.Cron = strings.Join(strings.Fields(s.Cron), " ")
+	if s.Path == "" {
+		s.Path = "/"
+	}
+	if !strings.HasPrefix(s.Path, "/") {
+		return invalidf("schedule path %q must start with /", s.Path)
+	}
+	s.spec = spec
+	return nil
+}
+
+// ValidateSchedule 校验定时任务的 cron 表达式与路径
+func ValidateSchedule(cron, path string) error {
+	s := &Schedule{Cron: cron, Path: path}
+	return s.normalize()
+}
+
+// SetSchedules 以 schedules 为函数的全部定时任务：新增缺少的、删除未列出的，已有任务保留运行记录
+func (r *Registry) SetSchedules(funcName string, schedules []Schedule) error {
+	wanted := make(map[string]*Schedule, len(schedules))
+	for i := range schedules {
+		s := &Schedule{FuncName: funcName, Cron: schedules[i].Cron, Path: schedules[i].Path}
+		if err := s.normalize(); err != nil {
+			return err
+		}
+		wanted[s.Key()] = s
+	}
+
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+	if _, exists := r.Latest[funcName]; !exists {
+		return ErrFunctionNotFound
+	}
+
+	current := r.schedules[funcName]
+	kept := make([]*Schedule, 0, len(wanted))
+	for _, s := range current {
+		if _, ok := wanted[s.Key()]; !ok {
+			if err := r.db.Delete(s).Error; err != nil {
+				return fmt.Errorf("delete schedule: %w", err)
+			}
+			continue
+		}
+		delete(wanted, s.Key())
+		kept = append(kept, s)
+	}
+	for _, s := range wanted {
+		if err := r.db.Create(s).Error; err != nil {
+			return fmt.Errorf("save schedule: %w", err)
+		}
+		kept = append(kept, s)
+	}
+	if len(kept) == 0 {
+		delete(r.schedules, funcName)
+	} else {
+		r.schedules[funcName] = kept
+	}
+	return nil
+}
+
+// ListSchedules 列出函数的定时任务，按 cron 与路径排序
+func (r *Registry) ListSchedules(funcName string) []Schedule {
+	r.Mu.RLock()
+	defer r.Mu.RUnlock()
+	return r.listSchedulesLocked(funcName)
+}
+
+func (r *Registry) listSchedulesLocked(funcName string) []Schedule {
+	schedules := make([]Schedule, 0, len(r.schedules[funcName]))
+	for _, s := range r.schedules[funcName] {
+		schedules = append(schedules, *s)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Key() < schedules[j].Key() })
+	return schedules
+}
+
+// RunSchedules 触发在 at 这一分钟到期的全部定时任务，等待全部请求结束。
+// 请求经路由处理器（SetServiceHandler 设置）发给函数的 latest 版本，挂起的实例会被唤醒
+func (r *Registry) RunSchedules(at time.Time) {
+	at = at.Truncate(time.Minute)
+	r.Mu.RLock()
+	var due []*Schedule
+	for _, list := range r.schedules {
+		for _, s := range list {
+			if s.spec.matches(at) {
+				due = append(due, s)
+			}
+		}
+	}
+	r.Mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, s := range due {
+		wg.Add(1)
+		go func(s *Schedule) {
+			defer wg.Done()
+			status, err := r.fireSchedule(s)
+			r.recordScheduleRun(s, at, status, err)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// fireSchedule 以 POST 请求调用函数 latest 版本的 Path，返回响应状态码
+func (r *Registry) fireSchedule(s *Schedule) (int, error) {
+	handler := r.internal.services
+	if handler == nil {
+		return 0, fmt.Errorf("router is not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), scheduleTimeout)
+	defer cancel()
+	host := r.generateAliasSubdomain(s.FuncName, "latest")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+host+s.Path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set(ScheduleHeader, s.Cron)
+	w := &scheduleResponse{header: make(http.Header)}
+	handler.ServeHTTP(w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status >= http.StatusInternalServerError {
+		return w.status, fmt.Errorf("function returned %d", w.status)
+	}
+	return w.status, nil
+}
+
+// recordScheduleRun 记录触发结果（任务已被删除时忽略）
+func (r *Registry) recordScheduleRun(s *Schedule, at time.Time, status int, err error) {
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+	s.LastRunAt, s.LastStatus, s.LastError = &at, status, ""
+	if err != nil {
+		s.LastError = err.Error()
+		fmt.Printf("schedule %s of %s failed: %v\n", s.Key(), s.FuncName, err)
+	}
+	for _, current := range r.schedules[s.FuncName] {
+		if current == s {
+			if err := r.db.Model(s).Select("last_run_at", "last_status", "last_error").Updates(s).Error; err != nil {
+				fmt.Printf("failed to save schedule run of %s: %v\n", s.FuncName, err)
+			}
+			return
+		}
+	}
+}
+
+// runSchedules 在每分钟开始时触发到期的定时任务，Close 时退出
+func (r *Registry) runSchedules() {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-r.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		go r.RunSchedules(next)
+	}
+}
+
+// 删除函数时清理其定时任务（调用方持有锁）
+func (r *Registry) deleteSchedulesLocked(funcName string) error {
+	if err := r.db.Where("func_name = ?", funcName).Delete(&Schedule{}).Error; err != nil {
+		return fmt.Errorf("delete schedules: %w", err)
+	}
+	delete(r.schedules, funcName)
+	return nil
+}
+
+// 从数据库加载定时任务（调用方持有锁），无法解析的表达式被忽略
+func (r *Registry) loadSchedulesLocked() error {
+	var schedules []*Schedule
+	if err := r.db.Find(&schedules).Error; err != nil {
+		return fmt.Errorf("load schedules: %w", err)
+	}
+	for _, s := range schedules {
+		if err := s.normalize(); err != nil {
+			fmt.Printf("ignore schedule %s of %s: %v\n", s.Key(), s.FuncName, err)
+			continue
+		}
+		r.schedules[s.FuncName] = append(r.schedules[s.FuncName], s)
+	}
+	return nil
+}
+
+// scheduleResponse 定时触发的响应只记录状态码，正文丢弃
+type scheduleResponse struct {
+	header http.Header
+	status int
+}
+
+func (w *scheduleResponse) Header() http.Header { return w.header }
+
+func (w *scheduleResponse) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(p), nil
+}
+
+func (w *scheduleResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
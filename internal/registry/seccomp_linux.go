@@ -0,0 +1,92 @@
+//go:build linux && (amd64 || arm64)
+
+package registry
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccompArch 各架构在 seccomp_data.arch 中的取值
+var seccompArch = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+}
+
+// seccompDenied 隔离环境中禁止的系统调用（返回 EPERM）：挂载与命名空间、调试其他进程、
+// 内核模块与 kexec、eBPF 与性能事件、密钥管理、修改系统时间与主机名等
+var seccompDenied = []uint32{
+	unix.SYS_MOUNT, unix.SYS_UMOUNT2, unix.SYS_PIVOT_ROOT, unix.SYS_CHROOT,
+	unix.SYS_FSOPEN, unix.SYS_FSCONFIG, unix.SYS_FSMOUNT, unix.SYS_FSPICK,
+	unix.SYS_MOVE_MOUNT, unix.SYS_OPEN_TREE, unix.SYS_MOUNT_SETATTR,
+	unix.SYS_UNSHARE, unix.SYS_SETNS,
+	unix.SYS_PTRACE, unix.SYS_PROCESS_VM_READV, unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_KEXEC_LOAD, unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_INIT_MODULE, unix.SYS_FINIT_MODULE, unix.SYS_DELETE_MODULE,
+	unix.SYS_BPF, unix.SYS_PERF_EVENT_OPEN, unix.SYS_USERFAULTFD,
+	unix.SYS_KEYCTL, unix.SYS_ADD_KEY, unix.SYS_REQUEST_KEY,
+	unix.SYS_OPEN_BY_HANDLE_AT, unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_REBOOT, unix.SYS_SWAPON, unix.SYS_SWAPOFF, unix.SYS_ACCT, unix.SYS_QUOTACTL,
+	unix.SYS_SETTIMEOFDAY, unix.SYS_CLOCK_SETTIME, unix.SYS_CLOCK_ADJTIME,
+	unix.SYS_SETHOSTNAME, unix.SYS_SETDOMAINNAME,
+}
+
+// cloneNamespaceFlags clone 创建新命名空间的标志，带这些标志的 clone 被禁止
+const cloneNamespaceFlags = unix.CLONE_NEWNS | unix.CLONE_NEWUTS | unix.CLONE_NEWIPC | unix.CLONE_NEWUSER |
+	unix.CLONE_NEWPID | unix.CLONE_NEWNET | unix.CLONE_NEWCGROUP | unix.CLONE_NEWTIME
+
+// x32SyscallBit x86-64 上 x32 ABI 系统调用号的标志位
+const x32SyscallBit = 0x40000000
+
+// installSeccomp 为当前线程安装 seccomp 过滤器（由此创建的进程继承），调用前需要设置 no_new_privs
+func installSeccomp() error {
+	filter := seccompFilter(seccompArch[runtime.GOARCH])
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return fmt.Errorf("install seccomp filter: %w", err)
+	}
+	return nil
+}
+
+// seccompFilter 生成 BPF 过滤程序：其他架构的系统调用直接结束进程；clone3 返回 ENOSYS
+// 使 libc 退回到可以检查标志的 clone
+func seccompFilter(arch uint32) []unix.SockFilter {
+	load := func(offset uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: offset}
+	}
+	jump := func(op uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_JMP | op | unix.BPF_K, K: k, Jt: jt, Jf: jf}
+	}
+	ret := func(k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: k}
+	}
+	deny := ret(unix.SECCOMP_RET_ERRNO | uint32(syscall.EPERM))
+
+	// seccomp_data：nr 位于偏移 0，arch 位于偏移 4，args[0] 的低 32 位位于偏移 16
+	filter := []unix.SockFilter{
+		load(4),
+		jump(unix.BPF_JEQ, arch, 1, 0),
+		ret(unix.SECCOMP_RET_KILL_PROCESS),
+		load(0),
+	}
+	if arch == unix.AUDIT_ARCH_X86_64 {
+		filter = append(filter, jump(unix.BPF_JGE, x32SyscallBit, 0, 1), deny)
+	}
+	for _, nr := range seccompDenied {
+		filter = append(filter, jump(unix.BPF_JEQ, nr, 0, 1), deny)
+	}
+	filter = append(filter,
+		jump(unix.BPF_JEQ, unix.SYS_CLONE3, 0, 1),
+		ret(unix.SECCOMP_RET_ERRNO|uint32(syscall.ENOSYS)),
+		jump(unix.BPF_JEQ, unix.SYS_CLONE, 0, 3),
+		load(16),
+		jump(unix.BPF_JSET, cloneNamespaceFlags, 0, 1),
+		deny,
+		ret(unix.SECCOMP_RET_ALLOW),
+	)
+	return filter
+}
@@ -0,0 +1,13 @@
+//go:build linux && !amd64 && !arm64
+
+package registry
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// installSeccomp 只为 amd64 / arm64 提供了过滤规则，其他架构无法开启隔离
+func installSeccomp() error {
+	return fmt.Errorf("seccomp is not supported on %s", runtime.GOARCH)
+}
@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ServiceBinding 服务绑定：函数代码通过 env.<Name>.fetch() 调用同一平台上的另一个函数，
+// 请求经平台的内部监听按别名路由到目标版本（挂起时唤醒），不经过外部 DNS 与路由端口
+type ServiceBinding struct {
+	Name     string `json:"name" binding:"required"`     // 绑定名（worker 中 env 的属性名）
+	Function string `json:"function" binding:"required"` // 目标函数
+	Alias    string `json:"alias,omitempty"`             // 目标别名或版本，默认 latest
+}
+
+// ServiceCallerHeader 服务绑定调用转发给目标函数时携带的调用方（<函数>:<版本>），由平台校验后设置；
+// 路由端口收到的外部请求中的同名请求头会被删除
+const ServiceCallerHeader = "X-Faas-Caller"
+
+// serviceBindingHeader workerd 在服务绑定请求中注入的绑定名
+const serviceBindingHeader = "X-Faas-Service-Binding"
+
+// bindingNamePattern 绑定名必须是合法的 JavaScript 标识符
+var bindingNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// target 绑定目标的子域名（别名或版本），交给路由处理器解析
+func (b ServiceBinding) target() string {
+	alias := b.Alias
+	if alias == "" {
+		alias = "latest"
+	}
+	return fmt.Sprintf("%s.%s.func.local", alias, b.Function)
+}
+
+// checkServices 检查版本的服务绑定：绑定名不能重复，也不能与环境变量等其他绑定同名；只有 workerd 后端支持
+func checkServices(meta *FunctionMetadata) error {
+	if len(meta.Services) == 0 {
+		return nil
+	}
+	if meta.Runtime == ExecRuntimeName {
+		return invalidf("service bindings are not supported by the %s runtime", ExecRuntimeName)
+	}
+	seen := make(map[string]bool, len(meta.Services))
+	for _, b := range meta.Services {
+		if !bindingNamePattern.MatchString(b.Name) {
+			return invalidf("invalid service binding name %q", b.Name)
+		}
+		if seen[b.Name] {
+			return invalidf("duplicate service binding %q", b.Name)
+		}
+		seen[b.Name] = true
+		if _, exists := meta.EnvVars[b.Name]; exists || b.Name == WasmModuleBinding || b.Name == WasmEnvBinding {
+			return invalidf("service binding %q conflicts with another binding", b.Name)
+		}
+		if b.Function == "" || strings.ContainsAny(b.Function, ".:/") || strings.ContainsAny(b.Alias, ".:/") {
+			return invalidf("invalid target %s for service binding %q", b.target(), b.Name)
+		}
+	}
+	return nil
+}
+
+// serviceServiceName 服务绑定在生成的 workerd 配置中对应的服务名
+func serviceServiceName(binding string) string {
+	return "faas-service-" + binding
+}
+
+// serviceBindings 返回服务绑定的 worker 绑定项，以及指向内部监听的 external 服务
+func (r *Registry) serviceBindings(meta *FunctionMetadata) (bindings, services string, err error) {
+	var items, defs []string
+	for _, b := range meta.Services {
+		name := serviceServiceName(b.Name)
+		def, err := r.internal.externalService(meta, name, "host", [2]string{serviceBindingHeader, b.Name})
+		if err != nil {
+			return "", "", err
+		}
+		items = append(items, fmt.Sprintf(`( name = "%s", service = "%s" )`, b.Name, name))
+		defs = append(defs, ",\n"+def)
+	}
+	return strings.Join(items, ",\n"), strings.Join(defs, ""), nil
+}
+
+// SetServiceHandler 设置服务绑定请求的处理器（平台的路由处理器）：请求的 Host 已改为目标的子域名，
+// 并带有 ServiceCallerHeader。需在部署声明了服务绑定的函数之前设置
+func (r *Registry) SetServiceHandler(h http.Handler) {
+	r.internal.services = h
+}
+
+// serveBinding 把服务绑定请求交给路由处理器
+func (l *internalListener) serveBinding(w http.ResponseWriter, req *http.Request, c *caller, name string) {
+	var target string
+	for _, b := range c.services {
+		if b.Name == name {
+			target = b.target()
+		}
+	}
+	if target == "" {
+		http.Error(w, fmt.Sprintf("service binding %q not found", name), http.StatusNotFound)
+		return
+	}
+	if l.services == nil {
+		http.Error(w, "service bindings are not available", http.StatusServiceUnavailable)
+		return
+	}
+	req.Host = target
+	req.Header.Set(ServiceCallerHeader, c.name+":"+c.version)
+	l.services.ServeHTTP(w, req)
+}
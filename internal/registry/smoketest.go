@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"context"
+	"faas/internal/tracing"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SmokeTest 部署时发给新版本的验证请求，通过后才切换 latest 与别名
+type SmokeTest struct {
+	Method       string            `json:"method" binding:"omitempty,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"` // 默认 GET
+	Path         string            `json:"path" binding:"omitempty,startswith=/"`                                   // 默认 /
+	Headers      map[string]string `json:"headers"`
+	Body         string            `json:"body"`
+	ExpectStatus int               `json:"expect_status" binding:"omitempty,min=100,max=599"` // 期望的状态码，默认要求 2xx
+	ExpectBody   string            `json:"expect_body"`                                       // 响应正文需包含的内容（可选）
+	TimeoutMs    int               `json:"timeout_ms" binding:"omitempty,min=1,max=60000"`    // 超时，默认 5 秒
+}
+
+// smokeTestBodyLimit 冒烟测试读取的响应正文上限
+const smokeTestBodyLimit = 64 << 10
+
+// runSmokeTest 直接向实例地址发送冒烟测试请求（不经过代理与限流，调用方不持有锁），
+// 返回的错误由调用方附上实例输出转为 *StartError
+func runSmokeTest(ctx context.Context, meta *FunctionMetadata, address string) (err error) {
+	test := meta.SmokeTest
+	ctx, span := tracing.Start(ctx, "SmokeTest", append(versionAttrs(meta), attribute.String("faas.address", address))...)
+	defer func() { tracing.End(span, err) }()
+
+	timeout := 5 * time.Second
+	if test.TimeoutMs > 0 {
+		timeout = time.Duration(test.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	method, path := defaultString(test.Method, http.MethodGet), defaultString(test.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+address+path, strings.NewReader(test.Body))
+	if err != nil {
+		return fmt.Errorf("invalid request: %v", err)
+	}
+	req.Host = meta.Subdomain
+	for key, value := range test.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, smokeTestBodyLimit))
+	if err != nil {
+		return fmt.Errorf("%s %s: read body: %v", method, path, err)
+	}
+
+	if test.ExpectStatus != 0 && resp.StatusCode != test.ExpectStatus {
+		return fmt.Errorf("%s %s: status %d, want %d", method, path, resp.StatusCode, test.ExpectStatus)
+	}
+	if test.ExpectStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return fmt.Errorf("%s %s: status %d, want 2xx", method, path, resp.StatusCode)
+	}
+	if test.ExpectBody != "" && !strings.Contains(string(body), test.ExpectBody) {
+		return fmt.Errorf("%s %s: body does not contain %q", method, path, test.ExpectBody)
+	}
+	return nil
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,140 @@
+package registry
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// locationPattern file:line[:column]，如 workerd 的 "at hello.js:3:7"、capnp 的 "hello.capnp:12:3: error: ..."
+	locationPattern = regexp.MustCompile(`([\w./-]+\.(?:capnp|js|mjs|cjs|ts|mts|py|rb|sh|go)):(\d+)(?::(\d+))?`)
+	// pythonLocationPattern Python traceback 中的 File "app.py", line 3
+	pythonLocationPattern = regexp.MustCompile(`File "([^"]+)", line (\d+)`)
+	// errorLinePattern 输出中描述错误的行
+	errorLinePattern = regexp.MustCompile(`(?i)\b(error|uncaught|exception|panic)\b`)
+)
+
+// sourceLineLimit Source 字段的最大长度（字符）
+const sourceLineLimit = 200
+
+// newStartError 根据错误与实例输出构造 StartError：从输出中解析错误信息与位置（只接受版本目录中存在的文件），
+// 并读取出错的源码行。启动失败时 Message 优先取输出中的错误行，冒烟测试失败时保留测试的结论
+func (r *Registry) newStartError(meta *FunctionMetadata, stage string, err error) *StartError {
+	output := meta.output.String()
+	dir, _ := filepath.Abs(r.versionDir(meta.Name, meta.Version))
+	startErr := &StartError{Stage: stage, Message: err.Error(), Output: output, At: time.Now()}
+
+	if message := errorLine(output); message != "" && stage == StageStart {
+		startErr.Message = strings.ReplaceAll(message, dir+string(filepath.Separator), "")
+	}
+	for _, loc := range errorLocations(output) {
+		file, ok := versionFile(dir, loc.file)
+		if !ok {
+			continue
+		}
+		startErr.File, startErr.Line, startErr.Column = file, loc.line, loc.column
+		startErr.Source = sourceLine(filepath.Join(dir, filepath.FromSlash(file)), loc.line)
+		break
+	}
+	return startErr
+}
+
+// recordStartErrorLocked 记录版本最近一次启动失败，已保存的版本同时写入数据库（调用方持有锁）
+func (r *Registry) recordStartErrorLocked(meta *FunctionMetadata, startErr *StartError) {
+	meta.LastStartError = startErr
+	if meta.ID == 0 {
+		return // 新版本尚未保存
+	}
+	if err := r.db.Model(meta).Select("last_start_error").UpdateColumns(meta).Error; err != nil {
+		fmt.Printf("failed to save start error of %s:%s: %v\n", meta.Name, meta.Version, err)
+	}
+}
+
+// errorLine 输出中的错误信息：Python traceback 取最后一行，其余取第一条包含 error / uncaught / exception / panic 的行
+func errorLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if strings.Contains(output, "Traceback (most recent call last)") {
+		return strings.TrimSpace(lines[len(lines)-1])
+	}
+	for _, line := range lines {
+		if errorLinePattern.MatchString(line) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+type errorLocation struct {
+	file         string
+	line, column int
+}
+
+// errorLocations 输出中出现的位置，按可能性排序：Python traceback 由内向外，其余按出现顺序
+func errorLocations(output string) []errorLocation {
+	var locations []errorLocation
+	if matches := pythonLocationPattern.FindAllStringSubmatch(output, -1); matches != nil {
+		for i := len(matches) - 1; i >= 0; i-- {
+			line, _ := strconv.Atoi(matches[i][2])
+			locations = append(locations, errorLocation{file: matches[i][1], line: line})
+		}
+		return locations
+	}
+	for _, match := range locationPattern.FindAllStringSubmatch(output, -1) {
+		line, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		locations = append(locations, errorLocation{file: match[1], line: line, column: column})
+	}
+	return locations
+}
+
+// versionFile 将输出中的文件名解析为版本目录下的相对路径：绝对路径需位于版本目录内，
+// 相对路径依次在版本目录、modules/（多模块部署）和 app/（exec 后端）下查找
+func versionFile(dir, name string) (string, bool) {
+	var candidates []string
+	if filepath.IsAbs(name) {
+		rel, err := filepath.Rel(dir, name)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		candidates = []string{rel}
+	} else {
+		name = strings.TrimPrefix(filepath.Clean(name), "./")
+		candidates = []string{name, filepath.Join("modules", name), filepath.Join("app", name)}
+	}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, "..") {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, candidate)); err == nil && info.Mode().IsRegular() {
+			return filepath.ToSlash(candidate), true
+		}
+	}
+	return "", false
+}
+
+// sourceLine 读取文件的第 line 行（从 1 开始），过长时截断
+func sourceLine(path string, line int) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if n == line {
+			text := []rune(strings.TrimSpace(scanner.Text()))
+			if len(text) > sourceLineLimit {
+				text = append(text[:sourceLineLimit], '…')
+			}
+			return string(text)
+		}
+	}
+	return ""
+}
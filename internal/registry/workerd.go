@@ -0,0 +1,275 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WorkerdRuntime 以 workerd 运行 JavaScript / TypeScript（编译后）/ WebAssembly 函数的后端：
+// 每个版本生成独立的 capnp 配置，由 workerd serve 启动
+type WorkerdRuntime struct {
+	reg *Registry
+	bin string // workerd 二进制路径
+}
+
+// NewWorkerdRuntime 创建 workerd 后端
+func NewWorkerdRuntime(reg *Registry, workerdBin string) *WorkerdRuntime {
+	return &WorkerdRuntime{reg: reg, bin: workerdBin}
+}
+
+func (w *WorkerdRuntime) Prepare(meta *FunctionMetadata) error {
+	return w.reg.generateWorkerdFiles(meta)
+}
+
+// Start 启动 workerd 进程（命令：workerd serve 配置文件）
+func (w *WorkerdRuntime) Start(ctx context.Context, meta *FunctionMetadata) error {
+	confPath, err := filepath.Abs(meta.Workerd.ConfPath) // 隔离运行时工作目录不同
+	if err != nil {
+		return fmt.Errorf("resolve config path: %w", err)
+	}
+	cmd := exec.Command(w.bin, "serve", confPath)
+	return w.reg.startProcess(ctx, meta, cmd)
+}
+
+func (w *WorkerdRuntime) Stop(meta *FunctionMetadata) error {
+	return stopProcess(meta)
+}
+
+func (w *WorkerdRuntime) Health(meta *FunctionMetadata) error {
+	return processHealth(meta)
+}
+
+func (w *WorkerdRuntime) Address(meta *FunctionMetadata) string {
+	return processAddress(meta)
+}
+
+// 生成 workerd 配置与代码文件
+func (r *Registry) generateWorkerdFiles(meta *FunctionMetadata) error {
+	// 每个版本独立目录（如 storage/foo/v1/），避免不同版本互相覆盖
+	dir := r.versionDir(meta.Name, meta.Version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create version dir: %w", err)
+	}
+
+	// 生成函数代码文件（如 storage/foo/v1/foo.js）
+	codeFile := fmt.Sprintf("%s.js", meta.Name)
+	codePath := filepath.Join(dir, codeFile)
+	if err := r.linkBlob(meta.CodeHash, []byte(meta.Code), codePath); err != nil {
+		return fmt.Errorf("write code: %w", err)
+	}
+	meta.Workerd.CodePath = codePath
+
+	// 单文件为 Service Worker 脚本；多文件部署以 ES 模块加载，入口模块放在首位
+	script := fmt.Sprintf(`serviceWorkerScript = embed "%s"`, codeFile)
+	if len(meta.Modules) > 0 {
+		modules, err := r.writeModules(dir, meta)
+		if err != nil {
+			return err
+		}
+		script = fmt.Sprintf("modules = [\n          %s\n        ]", modules)
+	} else if len(meta.DurableObjects.Classes) > 0 {
+		// Durable Object 类需要从 ES 模块导出
+		script = fmt.Sprintf(`modules = [ (name = "%s", esModule = embed "%s") ]`, codeFile, codeFile)
+	}
+
+	// wasm 运行时：胶水脚本为 Service Worker 脚本，WebAssembly 模块通过 wasmModule 绑定注入
+	bindings := generateWorkerdEnv(meta)
+	if len(meta.Wasm) > 0 {
+		wasmFile := fmt.Sprintf("%s.wasm", meta.Name)
+		if err := r.linkBlob(meta.WasmHash, meta.Wasm, filepath.Join(dir, wasmFile)); err != nil {
+			return fmt.Errorf("write wasm: %w", err)
+		}
+		wasmBindings, err := generateWasmBindings(meta, wasmFile)
+		if err != nil {
+			return err
+		}
+		if bindings != "" {
+			bindings += ",\n"
+		}
+		bindings += wasmBindings
+	}
+
+	// 监听地址：隔离网络时为版本目录下的 unix socket（由平台转发到分配的端口）
+	address := fmt.Sprintf("127.0.0.1:%d", meta.Workerd.Port)
+	socket, err := r.sandboxSocketPath(meta)
+	if err != nil {
+		return err
+	}
+	if socket != "" {
+		address = "unix:" + socket
+	}
+
+	// 服务绑定与出站规则：请求经由平台的内部监听
+	serviceBindings, services, err := r.serviceBindings(meta)
+	if err != nil {
+		return err
+	}
+	if serviceBindings != "" {
+		if bindings != "" {
+			bindings += ",\n"
+		}
+		bindings += serviceBindings
+	}
+	egressService, globalOutbound, err := r.egressService(meta)
+	if err != nil {
+		return err
+	}
+	services += egressService
+
+	// Durable Object：命名空间绑定，数据保存在函数的存储目录（所有版本共用）
+	durableBindings, durableWorker, durableService, err := r.durableObjectConfig(meta)
+	if err != nil {
+		return err
+	}
+	if durableBindings != "" {
+		if bindings != "" {
+			bindings += ",\n"
+		}
+		bindings += durableBindings
+	}
+	services += durableService
+
+	// 数据库绑定：语句由平台的内部监听在函数的 SQLite 数据库上执行
+	databaseBinding, databaseService, err := r.databaseBinding(meta)
+	if err != nil {
+		return err
+	}
+	if databaseBinding != "" {
+		if bindings != "" {
+			bindings += ",\n"
+		}
+		bindings += databaseBinding
+	}
+	services += databaseService
+
+	// 生成配置文件（注意 embed 必须是相对路径）
+	confPath := filepath.Join(dir, fmt.Sprintf("%s.capnp", meta.Name))
+	confContent := fmt.Sprintf(`
+using Workerd = import "/workerd/workerd.capnp";
+
+const config :Workerd.Config = (
+  services = [
+    (
+      name = "%s",
+      worker = (
+        %s,
+        compatibilityDate = "2024-05-01",%s
+		bindings = [
+          %s
+        ]
+      )
+    )%s
+  ],
+  sockets = [
+    (
+      name = "http",
+      address = "%s",
+      http = (),
+      service = "%s"
+    )
+  ]
+);
+`, meta.Name, script, globalOutbound+durableWorker, bindings, services, address, meta.Name)
+
+	if err := os.WriteFile(confPath, []byte(confContent), 0644); err != nil {
+		return fmt.Errorf("write conf: %w", err)
+	}
+	meta.Workerd.ConfPath = confPath
+
+	// 生成日志文件路径
+	meta.Workerd.LogPath = r.LogPath(meta.Name, meta.Version)
+	return nil
+}
+
+// writeModules 将模块集写入版本目录的 modules/ 下，返回 workerd modules 配置项
+func (r *Registry) writeModules(dir string, meta *FunctionMetadata) (string, error) {
+	if _, exists := meta.Modules[meta.Entry]; !exists {
+		return "", fmt.Errorf("entry module %q not found", meta.Entry)
+	}
+	names := make([]string, 0, len(meta.Modules))
+	for name := range meta.Modules {
+		if name != meta.Entry {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	names = append([]string{meta.Entry}, names...)
+
+	modulesDir := filepath.Join(dir, "modules")
+	items := make([]string, 0, len(names))
+	for _, name := range names {
+		target := filepath.Join(modulesDir, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, modulesDir+string(filepath.Separator)) {
+			return "", fmt.Errorf("invalid module path %q", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", fmt.Errorf("create module dir: %w", err)
+		}
+		if err := r.linkBlob(meta.ModuleHashes[name], meta.Modules[name], target); err != nil {
+			return "", fmt.Errorf("write module %s: %w", name, err)
+		}
+		items = append(items, fmt.Sprintf(`(name = "%s", %s = embed "modules/%s")`, name, moduleType(name), name))
+	}
+	return strings.Join(items, ",\n          "), nil
+}
+
+// moduleType 按扩展名确定 workerd 模块类型
+func moduleType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".js", ".mjs":
+		return "esModule"
+	case ".cjs":
+		return "commonJsModule"
+	case ".json":
+		return "json"
+	case ".wasm":
+		return "wasm"
+	case ".txt", ".html", ".css", ".md", ".svg":
+		return "text"
+	default:
+		return "data"
+	}
+}
+
+// 生成环境变量
+func generateWorkerdEnv(meta *FunctionMetadata) string {
+	if meta.EnvVars == nil || len(meta.EnvVars) == 0 {
+		return ""
+	}
+	var bindings []string
+	for key, value := range meta.EnvVars {
+		escapedValue := strings.ReplaceAll(value, `"`, `\"`)
+		escapedValue = strings.ReplaceAll(escapedValue, "\n", "\\n")
+		bindings = append(bindings,
+			fmt.Sprintf(`( name = "%s", text = "%s" )`, key, escapedValue))
+	}
+	return strings.Join(bindings, ",\n")
+}
+
+// WasmModuleBinding wasm 运行时中 WebAssembly.Module 的全局绑定名
+const WasmModuleBinding = "WASM_MODULE"
+
+// WasmEnvBinding wasm 运行时中以 JSON 对象形式提供全部环境变量的全局绑定名（供胶水脚本传给 WASI environ）
+const WasmEnvBinding = "WASM_ENV"
+
+// generateWasmBindings 生成 WebAssembly 模块绑定与环境变量 JSON 绑定
+func generateWasmBindings(meta *FunctionMetadata, wasmFile string) (string, error) {
+	env := meta.EnvVars
+	if env == nil {
+		env = JSONMap{}
+	}
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return "", fmt.Errorf("encode wasm env: %w", err)
+	}
+	return fmt.Sprintf(`( name = "%s", wasmModule = embed "%s" ),
+( name = "%s", json = %s )`, WasmModuleBinding, wasmFile, WasmEnvBinding, strconv.Quote(string(envJSON))), nil
+}
@@ -0,0 +1,42 @@
+package registry
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestGenerateWasmBindings(t *testing.T) {
+	pattern := regexp.MustCompile(`^\( name = "WASM_MODULE", wasmModule = embed "([^"]+)" \),\n\( name = "WASM_ENV", json = ("(?:[^"\\]|\\.)*") \)$`)
+	tests := []struct {
+		name string
+		env  JSONMap
+		want map[string]string
+	}{
+		{name: "no env", env: nil, want: map[string]string{}},
+		{name: "escaped values", env: JSONMap{"GREETING": `say "hi"`, "MULTI": "a\nb", "PATH": `C:\bin`}, want: map[string]string{"GREETING": `say "hi"`, "MULTI": "a\nb", "PATH": `C:\bin`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateWasmBindings(&FunctionMetadata{EnvVars: tt.env}, "fn.wasm")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := pattern.FindStringSubmatch(got)
+			if m == nil || m[1] != "fn.wasm" {
+				t.Fatalf("bindings = %s", got)
+			}
+			// json 绑定的值为 capnp 字符串，解码后为环境变量的 JSON 对象
+			text, err := strconv.Unquote(m[2])
+			if err != nil {
+				t.Fatalf("json literal %s: %v", m[2], err)
+			}
+			var env map[string]string
+			if err := json.Unmarshal([]byte(text), &env); err != nil || !reflect.DeepEqual(env, tt.want) {
+				t.Fatalf("WASM_ENV = %s (%v), want %v", text, err, tt.want)
+			}
+		})
+	}
+}
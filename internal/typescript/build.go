@@ -0,0 +1,177 @@
+// Package typescript 部署时在进程内将 TypeScript 转换为 workerd 可直接加载的 JavaScript。
+//
+// 转换只擦除类型语法（类型注解、interface/type、泛型参数、as/satisfies、非空断言、import type 等），
+// 被擦除的字节替换为空格，输出与源码行列号一致；需要生成代码的语法（enum、namespace、参数属性）
+// 会报错，与 Node.js 的 --experimental-strip-types 行为一致。转换不做类型检查。
+package typescript
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Diagnostic 一条编译错误，行列号从 1 开始（列按字符计）
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// Error 编译失败，包含全部诊断信息
+type Error struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *Error) Error() string {
+	msg := "typescript: " + e.Diagnostics[0].String()
+	if n := len(e.Diagnostics) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
+
+// Output 编译结果
+type Output struct {
+	Entry   string            // 编译后的入口模块路径
+	Modules map[string][]byte // 编译后的模块与原样保留的资源文件
+	Module  bool              // 是否需要以 ES 模块方式加载（多文件或入口含 import/export）
+}
+
+// IsSource 是否为需要编译的 TypeScript 源文件（.d.ts 只含声明，不参与编译）
+func IsSource(name string) bool {
+	return (strings.HasSuffix(name, ".ts") || strings.HasSuffix(name, ".mts")) && !IsDeclaration(name)
+}
+
+// IsDeclaration 是否为类型声明文件
+func IsDeclaration(name string) bool {
+	return strings.HasSuffix(name, ".d.ts") || strings.HasSuffix(name, ".d.mts")
+}
+
+// OutputName 源文件编译后的模块路径：.ts → .js，.mts → .mjs
+func OutputName(name string) string {
+	if strings.HasSuffix(name, ".mts") {
+		return strings.TrimSuffix(name, ".mts") + ".mjs"
+	}
+	return strings.TrimSuffix(name, ".ts") + ".js"
+}
+
+// Transpile 擦除单个文件中的类型语法
+func Transpile(file, src string) (string, error) {
+	t, b := strip(src)
+	if b != nil {
+		return "", &Error{Diagnostics: []Diagnostic{diagnostic(file, src, b)}}
+	}
+	return string(t.out), nil
+}
+
+// Build 从入口出发编译所有被引用到的 TypeScript 模块：相对路径的 import 按
+// 原路径、.ts/.mts 扩展名、.js → .ts 替换、目录 index.ts 的顺序解析，并改写为编译后的模块路径。
+// 非 TypeScript 文件原样保留，未被引用的 .ts 文件不会出现在输出中
+func Build(files map[string][]byte, entry string) (*Output, error) {
+	if _, ok := files[entry]; !ok || !IsSource(entry) {
+		return nil, &Error{Diagnostics: []Diagnostic{{File: entry, Line: 1, Column: 1, Message: "entry must be an existing .ts or .mts file"}}}
+	}
+
+	out := &Output{Entry: OutputName(entry), Modules: make(map[string][]byte)}
+	for name, data := range files {
+		if !IsSource(name) && !IsDeclaration(name) {
+			out.Modules[name] = data
+		}
+	}
+
+	var diags []Diagnostic
+	queue, seen := []string{entry}, map[string]bool{entry: true}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		src := string(files[name])
+
+		t, b := strip(src)
+		if b != nil {
+			diags = append(diags, diagnostic(name, src, b))
+			continue
+		}
+		if name == entry {
+			out.Module = t.module || len(files) > 1
+		}
+		if _, clash := out.Modules[OutputName(name)]; clash {
+			diags = append(diags, Diagnostic{File: name, Line: 1, Column: 1, Message: fmt.Sprintf("output %s conflicts with an existing file", OutputName(name))})
+			continue
+		}
+
+		// 从后向前改写，保证前面的字节偏移不受影响
+		code := t.out
+		sort.Slice(t.refs, func(i, j int) bool { return t.refs[i].start > t.refs[j].start })
+		for _, ref := range t.refs {
+			if !strings.HasPrefix(ref.path, "./") && !strings.HasPrefix(ref.path, "../") {
+				continue // 裸模块名（如 node:buffer、cloudflare:sockets）交给 workerd 解析
+			}
+			target, ok := resolve(files, path.Join(path.Dir(name), ref.path))
+			if !ok {
+				diags = append(diags, diagnostic(name, src, &bailout{pos: ref.start, msg: fmt.Sprintf("cannot resolve module %q", ref.path)}))
+				continue
+			}
+			if IsSource(target) && !seen[target] {
+				seen[target] = true
+				queue = append(queue, target)
+			}
+			spec := rewriteSpecifier(ref.path, path.Join(path.Dir(name), ref.path), target)
+			quote := code[ref.start]
+			code = append(code[:ref.start:ref.start], append([]byte(string(quote)+spec+string(quote)), code[ref.end:]...)...)
+		}
+		out.Modules[OutputName(name)] = code
+	}
+
+	if len(diags) > 0 {
+		sort.SliceStable(diags, func(i, j int) bool { return diags[i].File < diags[j].File })
+		return nil, &Error{Diagnostics: diags}
+	}
+	return out, nil
+}
+
+// resolve 解析相对模块路径对应的文件
+func resolve(files map[string][]byte, p string) (string, bool) {
+	candidates := []string{p, p + ".ts", p + ".mts", p + ".js", p + ".mjs", p + "/index.ts", p + "/index.js"}
+	// TypeScript 惯例：源码中写 ./util.js 指向 util.ts
+	if strings.HasSuffix(p, ".js") {
+		candidates = append([]string{strings.TrimSuffix(p, ".js") + ".ts"}, candidates...)
+	} else if strings.HasSuffix(p, ".mjs") {
+		candidates = append([]string{strings.TrimSuffix(p, ".mjs") + ".mts"}, candidates...)
+	}
+	for _, c := range candidates {
+		if _, ok := files[c]; ok && !IsDeclaration(c) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// rewriteSpecifier 将模块说明符改写为指向编译后的文件
+func rewriteSpecifier(spec, joined, target string) string {
+	if IsSource(target) {
+		target = OutputName(target)
+	}
+	if target == joined {
+		return spec
+	}
+	if strings.HasPrefix(target, joined) {
+		return spec + target[len(joined):] // 补全扩展名或 /index.js
+	}
+	return strings.TrimSuffix(spec, path.Ext(spec)) + path.Ext(target) // ./util.ts → ./util.js
+}
+
+// diagnostic 将字节偏移换算为行列号
+func diagnostic(file, src string, b *bailout) Diagnostic {
+	pos := min(b.pos, len(src))
+	line := strings.Count(src[:pos], "\n") + 1
+	col := utf8.RuneCountInString(src[strings.LastIndexByte(src[:pos], '\n')+1:pos]) + 1
+	return Diagnostic{File: file, Line: line, Column: col, Message: b.msg}
+}
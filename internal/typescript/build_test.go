@@ -0,0 +1,186 @@
+package typescript
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTranspile(t *testing.T) {
+	// 被擦除的类型语法替换为等长的空格，输出与源码行列号一致
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"generic call", "const m = new Map<string, number>();", "const m = new Map                ();"},
+		{"explicit type argument", "f<T>(x);", "f   (x);"},
+		{"comparison", "const b = a < c && d > e;", "const b = a < c && d > e;"},
+		{"chained comparison", "const r = a < b > c;", "const r = a < b > c;"},
+		{"generic declaration", "function id<T extends object = {}>(x: T): T { return x; }", "function id                       (x   )    { return x; }"},
+		{"as", "const x = y as unknown as string;", "const x = y                     ;"},
+		{"satisfies", "const cfg = { a: 1 } satisfies Config;", "const cfg = { a: 1 }                 ;"},
+		{"non-null", "const v = obj!.field!;", "const v = obj .field ;"},
+		{"non-null assignment", "x! = 1;", "x  = 1;"},
+		{"inequality", "a != b;", "a != b;"},
+		{"definite assignment", "let x: number = 1, y!: string;", "let x         = 1, y         ;"},
+		{"arrow return type", "const add = (a: number, b: number): number => a + b;", "const add = (a        , b        )         => a + b;"},
+		{"this and rest parameters", "function f(this: Window, ...rest: any[]) {}", "function f(              ...rest       ) {}"},
+		{"regex", "const r = /ab+c/g.test(s);", "const r = /ab+c/g.test(s);"},
+		{"division", "const d = total / count / 2;", "const d = total / count / 2;"},
+		{"template literal", "const t = `sum ${a as number} and ${`${b!}`}`;", "const t = `sum ${a          } and ${`${b }`}`;"},
+		{"class fields", "class C { private a?: number = 1; declare b: string; }", "class C {         a          = 1;                    }"},
+		{"declarations", "interface Foo { a: string }\ntype Bar = Foo | null;\nexport {};", "                           \n                      \nexport {};"},
+		{"type imports", "import type { A } from './a';\nimport { type B, c } from './b';", "                             \nimport {         c } from './b';"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transpile("t.ts", tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("Transpile(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranspileDiagnostics(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want Diagnostic
+	}{
+		{"enum", "const a = 1;\n  enum Color { Red }", Diagnostic{File: "t.ts", Line: 2, Column: 3, Message: "enums are not supported; use a plain object instead"}},
+		{"parameter property", "class A {\n  constructor(private x: number) {}\n}", Diagnostic{File: "t.ts", Line: 2, Column: 15, Message: "parameter properties are not supported; assign the field in the constructor body"}},
+		{"namespace", "namespace N {}", Diagnostic{File: "t.ts", Line: 1, Column: 1, Message: "namespaces are not supported; use ES modules instead"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Transpile("t.ts", tt.src)
+			var tsErr *Error
+			if !errors.As(err, &tsErr) || len(tsErr.Diagnostics) != 1 || tsErr.Diagnostics[0] != tt.want {
+				t.Fatalf("Transpile(%q) error = %v, want %v", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	files := map[string][]byte{
+		"src/index.ts": []byte(`import { greet } from "./util.js";
+import { helper } from "./lib/helper";
+import { config } from "./config";
+import type { Options } from "./types";
+export default { fetch: (req: Request) => new Response(greet(helper(config))) };
+`),
+		"src/util.ts":             []byte(`export const greet = (s: string): string => "hi " + s;`),
+		"src/lib/helper.ts":       []byte(`export function helper<T>(x: T): T { return x; }`),
+		"src/config/index.ts":     []byte(`export const config = "x" as const;`),
+		"src/types.d.ts":          []byte(`export interface Options { a: string }`),
+		"src/unused.ts":           []byte(`export const unused: number = 1;`),
+		"src/static/logo.svg":     []byte(`<svg/>`),
+		"src/legacy/plain.js":     []byte(`export const plain = 1;`),
+		"src/legacy/unrelated.ts": []byte(`enum Broken { A }`),
+	}
+	out, err := Build(files, "src/index.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Entry != "src/index.js" || !out.Module {
+		t.Fatalf("entry = %q, module = %v", out.Entry, out.Module)
+	}
+	// 未被引用的 .ts（含无法编译的）与 .d.ts 不出现在输出中，其他文件原样保留
+	want := []string{"src/config/index.js", "src/index.js", "src/legacy/plain.js", "src/lib/helper.js", "src/static/logo.svg", "src/util.js"}
+	if names := sortedNames(out.Modules); !reflect.DeepEqual(names, want) {
+		t.Fatalf("modules = %v, want %v", names, want)
+	}
+	// 说明符改写为编译后的路径；import type 被擦除，不需要解析
+	wantIndex := `import { greet } from "./util.js";
+import { helper } from "./lib/helper.js";
+import { config } from "./config/index.js";
+` + strings.Repeat(" ", len(`import type { Options } from "./types";`)) + `
+export default { fetch: (req         ) => new Response(greet(helper(config))) };
+`
+	if got := string(out.Modules["src/index.js"]); got != wantIndex {
+		t.Fatalf("index.js =\n%s\nwant\n%s", got, wantIndex)
+	}
+	if got := string(out.Modules["src/util.js"]); got != `export const greet = (s        )         => "hi " + s;` {
+		t.Fatalf("util.js = %q", got)
+	}
+
+	// .mts 编译为 .mjs，单文件且没有 import/export 时按 service worker 脚本加载
+	out, err = Build(map[string][]byte{"main.mts": []byte(`import { a } from "./a.mjs"; console.log(a);`), "a.mts": []byte(`export const a: number = 1;`)}, "main.mts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out.Modules["main.mjs"]); out.Entry != "main.mjs" || got != `import { a } from "./a.mjs"; console.log(a);` {
+		t.Fatalf("entry = %q, main.mjs = %q", out.Entry, got)
+	}
+	out, err = Build(map[string][]byte{"worker.ts": []byte(`addEventListener("fetch", (e: FetchEvent) => {});`)}, "worker.ts")
+	if err != nil || out.Module {
+		t.Fatalf("single service worker: %+v, %v", out, err)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		entry string
+		want  []Diagnostic
+	}{
+		{
+			name:  "missing entry",
+			files: map[string][]byte{"index.ts": nil},
+			entry: "main.ts",
+			want:  []Diagnostic{{File: "main.ts", Line: 1, Column: 1, Message: "entry must be an existing .ts or .mts file"}},
+		},
+		{
+			name:  "unresolved import",
+			files: map[string][]byte{"index.ts": []byte("const x = 1;\nimport { a } from \"./missing\";")},
+			entry: "index.ts",
+			want:  []Diagnostic{{File: "index.ts", Line: 2, Column: 19, Message: `cannot resolve module "./missing"`}},
+		},
+		{
+			name: "errors in imported modules",
+			files: map[string][]byte{
+				"index.ts": []byte(`import "./b"; import "./a";`),
+				"a.ts":     []byte(`enum A { X }`),
+				"b.ts":     []byte("\nnamespace B {}"),
+			},
+			entry: "index.ts",
+			want: []Diagnostic{
+				{File: "a.ts", Line: 1, Column: 1, Message: "enums are not supported; use a plain object instead"},
+				{File: "b.ts", Line: 2, Column: 1, Message: "namespaces are not supported; use ES modules instead"},
+			},
+		},
+		{
+			name:  "output conflict",
+			files: map[string][]byte{"index.ts": []byte(`export {};`), "index.js": []byte(`export {};`)},
+			entry: "index.ts",
+			want:  []Diagnostic{{File: "index.ts", Line: 1, Column: 1, Message: "output index.js conflicts with an existing file"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Build(tt.files, tt.entry)
+			var tsErr *Error
+			if !errors.As(err, &tsErr) || !reflect.DeepEqual(tsErr.Diagnostics, tt.want) {
+				t.Fatalf("Build error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func sortedNames(m map[string][]byte) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
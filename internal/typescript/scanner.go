@@ -0,0 +1,396 @@
+package typescript
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokNumber
+	tokString
+	tokTemplate
+	tokRegExp
+	tokPunct
+	tokPrivateName // #name
+)
+
+// token 词法单元，start/end 为源码中的字节偏移
+type token struct {
+	kind    tokenKind
+	text    string
+	start   int
+	end     int
+	newline bool      // 与前一个单元之间有换行
+	subs    [][]token // 模板字符串中各个 ${} 的单元（各自以 EOF 结尾）
+}
+
+func (t token) is(text string) bool {
+	return (t.kind == tokPunct || t.kind == tokKeyword) && t.text == text
+}
+
+// isWord 标识符或关键字（TS 的上下文关键字如 type / as 都是标识符）
+func (t token) isWord(text string) bool {
+	return (t.kind == tokIdent || t.kind == tokKeyword) && t.text == text
+}
+
+// keywords 会影响正则/除号判断与语句识别的保留字
+var keywords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true,
+	"debugger": true, "default": true, "delete": true, "do": true, "else": true, "export": true,
+	"extends": true, "finally": true, "for": true, "function": true, "if": true, "import": true,
+	"in": true, "instanceof": true, "new": true, "return": true, "super": true, "switch": true,
+	"this": true, "throw": true, "try": true, "typeof": true, "var": true, "void": true,
+	"while": true, "with": true, "yield": true, "let": true, "await": true, "enum": true,
+	"null": true, "true": true, "false": true,
+}
+
+// 可以结束一个表达式的关键字（其后的 / 为除号）
+var operandKeywords = map[string]bool{"this": true, "super": true, "null": true, "true": true, "false": true}
+
+// punctuators 按长度降序匹配（> 单独成词，便于拆分泛型的 >>）
+var punctuators = []string{
+	"...", "===", "!==", "**=", "<<=", "&&=", "||=", "??=",
+	"=>", "==", "!=", "<=", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"++", "--", "&&", "||", "??", "?.", "**", "<<",
+	"{", "}", "(", ")", "[", "]", ";", ",", "<", ">", "+", "-", "*", "/", "%",
+	"&", "|", "^", "!", "~", "?", ":", "=", ".", "@",
+}
+
+// scanner 将源码切分为词法单元（不做语法分析，仅用于类型擦除）
+type scanner struct {
+	src    string
+	pos    int
+	tokens []token
+	err    *scanError
+}
+
+type scanError struct {
+	pos int
+	msg string
+}
+
+func scan(src string) ([]token, *scanError) {
+	s := &scanner{src: src}
+	if strings.HasPrefix(src, "#!") { // hashbang
+		s.pos = strings.IndexByte(src, '\n')
+		if s.pos < 0 {
+			s.pos = len(src)
+		}
+	}
+	s.run(false)
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.tokens = append(s.tokens, token{kind: tokEOF, start: len(src), end: len(src), newline: true})
+	return s.tokens, nil
+}
+
+// run 扫描到源码结束；inTemplate 为 true 时遇到与 ${ 匹配的 } 即返回
+func (s *scanner) run(inTemplate bool) {
+	depth := 0
+	newline := false
+	for s.err == nil {
+		nl := s.skipSpace()
+		newline = newline || nl
+		if s.err != nil || s.pos >= len(s.src) {
+			return
+		}
+
+		start := s.pos
+		c := s.src[s.pos]
+		var tok token
+		switch {
+		case c == '"' || c == '\'':
+			tok = s.string(c)
+		case c == '`':
+			tok = s.template()
+		case c >= '0' && c <= '9' || c == '.' && s.pos+1 < len(s.src) && isDigit(s.src[s.pos+1]):
+			tok = s.number()
+		case c == '#':
+			s.pos++
+			name := s.identifier()
+			if name == "" {
+				s.fail(start, "invalid character '#'")
+				return
+			}
+			tok = token{kind: tokPrivateName, text: "#" + name}
+		case isIdentStart(s.src, s.pos):
+			name := s.identifier()
+			kind := tokIdent
+			if keywords[name] && !s.afterDot() {
+				kind = tokKeyword // 属性名（x.class、p.catch）不是关键字
+			}
+			tok = token{kind: kind, text: name}
+		case c == '/' && s.regexAllowed():
+			tok = s.regexp()
+		default:
+			tok = s.punct()
+			if tok.text == "" {
+				r, _ := utf8.DecodeRuneInString(s.src[s.pos:])
+				s.fail(start, "unexpected character "+strconvQuote(r))
+				return
+			}
+			if inTemplate {
+				switch tok.text {
+				case "{":
+					depth++
+				case "}":
+					if depth == 0 {
+						s.pos = start // 由 template() 处理 }
+						return
+					}
+					depth--
+				}
+			}
+		}
+		if s.err != nil {
+			return
+		}
+		tok.start, tok.end, tok.newline = start, s.pos, newline
+		s.tokens = append(s.tokens, tok)
+		newline = false
+	}
+}
+
+func (s *scanner) fail(pos int, msg string) {
+	if s.err == nil {
+		s.err = &scanError{pos: pos, msg: msg}
+	}
+}
+
+// skipSpace 跳过空白与注释，返回是否跨越了换行
+func (s *scanner) skipSpace() bool {
+	newline := false
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\n' || c == '\r':
+			newline = true
+			s.pos++
+		case c == ' ' || c == '\t' || c == '\v' || c == '\f':
+			s.pos++
+		case c == '/' && strings.HasPrefix(s.src[s.pos:], "//"):
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos++
+			}
+		case c == '/' && strings.HasPrefix(s.src[s.pos:], "/*"):
+			end := strings.Index(s.src[s.pos+2:], "*/")
+			if end < 0 {
+				s.fail(s.pos, "unterminated comment")
+				return newline
+			}
+			if strings.ContainsAny(s.src[s.pos:s.pos+2+end], "\n\r") {
+				newline = true
+			}
+			s.pos += end + 4
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+			if r == '\u2028' || r == '\u2029' {
+				newline = true
+			} else if !unicode.IsSpace(r) && r != '\uFEFF' {
+				return newline
+			}
+			s.pos += size
+		default:
+			return newline
+		}
+	}
+	return newline
+}
+
+func (s *scanner) string(quote byte) token {
+	start := s.pos
+	s.pos++
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos += 2
+			continue
+		case quote:
+			s.pos++
+			return token{kind: tokString, text: s.src[start:s.pos]}
+		case '\n':
+			s.fail(start, "unterminated string literal")
+			return token{}
+		}
+		s.pos++
+	}
+	s.fail(start, "unterminated string literal")
+	return token{}
+}
+
+// template 扫描模板字符串，${} 中的表达式递归扫描为独立的单元序列
+func (s *scanner) template() token {
+	start := s.pos
+	s.pos++
+	var subs [][]token
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos += 2
+			continue
+		case '`':
+			s.pos++
+			return token{kind: tokTemplate, text: s.src[start:s.pos], subs: subs}
+		case '$':
+			if s.pos+1 < len(s.src) && s.src[s.pos+1] == '{' {
+				s.pos += 2
+				outer := s.tokens
+				s.tokens = nil
+				s.run(true)
+				if s.err == nil && s.pos >= len(s.src) {
+					s.fail(start, "unterminated template literal")
+				}
+				if s.err != nil {
+					return token{}
+				}
+				subs = append(subs, append(s.tokens, token{kind: tokEOF, start: s.pos, end: s.pos}))
+				s.tokens = outer
+				s.pos++ // }
+				continue
+			}
+		}
+		s.pos++
+	}
+	s.fail(start, "unterminated template literal")
+	return token{}
+}
+
+func (s *scanner) number() token {
+	start := s.pos
+	digits := func(hex bool) {
+		for s.pos < len(s.src) {
+			c := s.src[s.pos]
+			if isDigit(c) || c == '_' || hex && (c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				s.pos++
+				continue
+			}
+			break
+		}
+	}
+	if s.src[s.pos] == '0' && s.pos+1 < len(s.src) && strings.ContainsRune("xXoObB", rune(s.src[s.pos+1])) {
+		s.pos += 2
+		digits(true)
+	} else {
+		digits(false)
+		if s.pos < len(s.src) && s.src[s.pos] == '.' {
+			s.pos++
+			digits(false)
+		}
+		if s.pos < len(s.src) && (s.src[s.pos] == 'e' || s.src[s.pos] == 'E') {
+			s.pos++
+			if s.pos < len(s.src) && (s.src[s.pos] == '+' || s.src[s.pos] == '-') {
+				s.pos++
+			}
+			digits(false)
+		}
+	}
+	if s.pos < len(s.src) && s.src[s.pos] == 'n' { // BigInt
+		s.pos++
+	}
+	return token{kind: tokNumber, text: s.src[start:s.pos]}
+}
+
+func (s *scanner) identifier() string {
+	start := s.pos
+	for s.pos < len(s.src) {
+		if s.src[s.pos] == '\\' { // \uXXXX 形式的转义
+			s.pos += 2
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if r == '$' || r == '_' || unicode.IsLetter(r) || (s.pos > start && (unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) || r == '\u200C' || r == '\u200D')) {
+			s.pos += size
+			continue
+		}
+		break
+	}
+	return s.src[start:s.pos]
+}
+
+func (s *scanner) regexp() token {
+	start := s.pos
+	s.pos++
+	inClass := false
+	for s.pos < len(s.src) {
+		switch c := s.src[s.pos]; c {
+		case '\\':
+			s.pos += 2
+			continue
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				s.pos++
+				s.identifier() // flags
+				return token{kind: tokRegExp, text: s.src[start:s.pos]}
+			}
+		case '\n':
+			s.fail(start, "unterminated regular expression")
+			return token{}
+		}
+		s.pos++
+	}
+	s.fail(start, "unterminated regular expression")
+	return token{}
+}
+
+func (s *scanner) punct() token {
+	for _, p := range punctuators {
+		if strings.HasPrefix(s.src[s.pos:], p) {
+			// ?. 后跟数字时是三元运算符（a?.5:b）
+			if p == "?." && s.pos+2 < len(s.src) && isDigit(s.src[s.pos+2]) {
+				continue
+			}
+			s.pos += len(p)
+			return token{kind: tokPunct, text: p}
+		}
+	}
+	return token{}
+}
+
+func (s *scanner) afterDot() bool {
+	if len(s.tokens) == 0 {
+		return false
+	}
+	prev := s.tokens[len(s.tokens)-1]
+	return prev.kind == tokPunct && (prev.text == "." || prev.text == "?.")
+}
+
+// regexAllowed 根据前一个单元判断 / 是正则的开始还是除号
+func (s *scanner) regexAllowed() bool {
+	if len(s.tokens) == 0 {
+		return true
+	}
+	prev := s.tokens[len(s.tokens)-1]
+	switch prev.kind {
+	case tokIdent, tokNumber, tokString, tokTemplate, tokRegExp, tokPrivateName:
+		return false
+	case tokKeyword:
+		return !operandKeywords[prev.text]
+	}
+	return !(prev.text == ")" || prev.text == "]" || prev.text == "}" || prev.text == "++" || prev.text == "--")
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(src string, pos int) bool {
+	if src[pos] == '\\' {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(src[pos:])
+	return r == '$' || r == '_' || unicode.IsLetter(r)
+}
+
+func strconvQuote(r rune) string {
+	return "'" + string(r) + "'"
+}
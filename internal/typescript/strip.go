@@ -0,0 +1,822 @@
+package typescript
+
+import "strings"
+
+// importRef 保留下来的模块引用（import/export ... from "x"、import("x")），start/end 为字符串字面量的字节范围
+type importRef struct {
+	start, end int
+	path       string
+}
+
+// transpiler 单个文件的类型擦除状态：out 与 src 等长，被擦除的字节替换为空格（保留换行），
+// 因此输出中的行列号与源码一一对应，运行时错误栈可以直接对照 TS 源码
+type transpiler struct {
+	src    string
+	out    []byte
+	refs   []importRef
+	module bool // 是否保留了 import/export 语句
+}
+
+// bailout 语法错误时中止擦除（由 strip 恢复）
+type bailout struct {
+	pos int
+	msg string
+}
+
+// strip 擦除 src 中的 TypeScript 类型语法，返回等长的 JavaScript 源码
+func strip(src string) (t *transpiler, err *bailout) {
+	toks, serr := scan(src)
+	if serr != nil {
+		return nil, &bailout{pos: serr.pos, msg: serr.msg}
+	}
+	t = &transpiler{src: src, out: []byte(src)}
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(*bailout)
+			if !ok {
+				panic(r)
+			}
+			t, err = nil, b
+		}
+	}()
+	t.newWalker(toks).walk(0, len(toks)-1, true)
+	return t, nil
+}
+
+// walker 遍历一段连续的词法单元（模板字符串的 ${} 各自是独立的一段）
+type walker struct {
+	t     *transpiler
+	toks  []token
+	match []int // 括号配对：( [ { 对应的闭合单元下标
+}
+
+func (t *transpiler) newWalker(toks []token) *walker {
+	w := &walker{t: t, toks: toks, match: make([]int, len(toks))}
+	var stack []int
+	for i, tok := range toks {
+		w.match[i] = -1
+		if tok.kind != tokPunct {
+			continue
+		}
+		switch tok.text {
+		case "(", "[", "{":
+			stack = append(stack, i)
+		case ")", "]", "}":
+			want := map[string]string{")": "(", "]": "[", "}": "{"}[tok.text]
+			if len(stack) == 0 || toks[stack[len(stack)-1]].text != want {
+				w.fail(i, "unexpected '"+tok.text+"'")
+			}
+			open := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			w.match[open], w.match[i] = i, open
+		}
+	}
+	if len(stack) > 0 {
+		open := stack[len(stack)-1]
+		w.fail(open, "'"+toks[open].text+"' was not closed")
+	}
+	return w
+}
+
+func (w *walker) fail(i int, msg string) {
+	panic(&bailout{pos: w.toks[i].start, msg: msg})
+}
+
+// tok 越界时返回 EOF 单元
+func (w *walker) tok(i int) token {
+	if i < 0 {
+		return token{kind: tokEOF}
+	}
+	if i >= len(w.toks) {
+		return w.toks[len(w.toks)-1]
+	}
+	return w.toks[i]
+}
+
+// erase 擦除 [from, to) 范围内的单元
+func (w *walker) erase(from, to int) {
+	if to <= from {
+		return
+	}
+	for p := w.toks[from].start; p < w.toks[to-1].end; p++ {
+		if c := w.t.out[p]; c != '\n' && c != '\r' {
+			w.t.out[p] = ' '
+		}
+	}
+}
+
+// expect 要求 i 处为指定符号
+func (w *walker) expect(i int, text string) {
+	if !w.tok(i).is(text) {
+		w.fail(i, "'"+text+"' expected")
+	}
+}
+
+// endsExpr 该单元能否结束一个表达式（其后的 < ! as 才可能是 TS 语法）
+func endsExpr(t token) bool {
+	switch t.kind {
+	case tokIdent, tokNumber, tokString, tokTemplate, tokRegExp, tokPrivateName:
+		return true
+	case tokKeyword:
+		return operandKeywords[t.text]
+	case tokPunct:
+		return t.text == ")" || t.text == "]"
+	}
+	return false
+}
+
+// walk 处理 [from, to) 范围；block 为 true 时按语句序列处理（对象字面量也按此处理，二者对擦除没有区别）
+func (w *walker) walk(from, to int, block bool) {
+	decl, expectBinding := false, false
+	for j := from; j < to; {
+		t := w.toks[j]
+		if block && w.statementStart(j, from) {
+			if k, ok := w.statement(j); ok {
+				j, decl, expectBinding = k, false, false
+				continue
+			}
+		}
+		if decl && t.newline && endsExpr(w.tok(j-1)) && !continuesExpr(t) {
+			decl = false
+		}
+
+		switch {
+		case (t.is("let") || t.is("const") || t.is("var")) && startsBinding(w.tok(j+1)):
+			decl, expectBinding = true, true
+			j++
+		case expectBinding:
+			expectBinding = false
+			if t.is("{") || t.is("[") {
+				w.walk(j+1, w.match[j], false)
+				j = w.match[j] + 1
+			} else {
+				j++
+			}
+			if w.tok(j).is("!") { // 明确赋值断言 let x!: T
+				w.erase(j, j+1)
+				j++
+			}
+			if w.tok(j).is(":") {
+				end := w.skipType(j + 1)
+				w.erase(j, end)
+				j = end
+			}
+		case decl && t.is(","):
+			expectBinding = true
+			j++
+		default:
+			if t.is(";") || t.is("in") || t.isWord("of") {
+				decl = false
+			}
+			j = w.step(j)
+		}
+	}
+}
+
+// startsBinding let/const/var 之后是否为声明的绑定（排除把 let 当标识符用的情况）
+func startsBinding(t token) bool {
+	return t.kind == tokIdent || t.is("{") || t.is("[") || t.kind == tokKeyword && !keywords[t.text]
+}
+
+// continuesExpr 行首单元是否延续上一行的表达式
+func continuesExpr(t token) bool {
+	if t.kind != tokPunct {
+		return t.isWord("as") || t.isWord("satisfies") || t.is("in") || t.is("instanceof")
+	}
+	switch t.text {
+	case "(", "[", "{", "!", "~", "++", "--", "@", ";", "}", ")", "]":
+		return false
+	}
+	return true
+}
+
+// step 在表达式上下文中处理 j 处的单元，返回下一个待处理的下标
+func (w *walker) step(j int) int {
+	t := w.toks[j]
+	prev := w.tok(j - 1)
+	switch {
+	case t.is("("):
+		close := w.match[j]
+		if w.isParams(j) {
+			w.walkParams(j+1, close)
+			return w.returnType(close + 1)
+		}
+		w.walk(j+1, close, false)
+		return close + 1
+	case t.is("["):
+		w.walk(j+1, w.match[j], false)
+		return w.match[j] + 1
+	case t.is("{"):
+		w.walk(j+1, w.match[j], true)
+		return w.match[j] + 1
+	case t.kind == tokTemplate:
+		for _, sub := range t.subs {
+			w.t.newWalker(sub).walk(0, len(sub)-1, false)
+		}
+		return j + 1
+	case t.is("function"):
+		return w.function(j)
+	case t.is("class"):
+		return w.class(j)
+	case t.isWord("abstract") && w.tok(j+1).is("class") && !w.tok(j+1).newline:
+		w.erase(j, j+1)
+		return j + 1
+	case (t.isWord("as") || t.isWord("satisfies")) && (endsExpr(prev) || prev.is("}")) && startsType(w.tok(j+1)):
+		end := j + 2
+		if !(t.isWord("as") && w.tok(j+1).is("const")) {
+			end = w.skipType(j + 1)
+		}
+		w.erase(j, end)
+		return end
+	case t.is("!") && !t.newline && endsExpr(prev) && !w.closesControlHead(j-1):
+		w.erase(j, j+1) // 非空断言 x!
+		return j + 1
+	case t.is("<"):
+		return w.angle(j)
+	case t.is("import") && w.tok(j+1).is("(") && w.tok(j+2).kind == tokString && w.tok(j+3).is(")"):
+		w.addRef(w.tok(j + 2))
+		return j + 4
+	}
+	return j + 1
+}
+
+// angle 处理表达式中的 <：泛型调用实参、泛型箭头函数的类型参数，或普通的小于号
+func (w *walker) angle(j int) int {
+	prev := w.tok(j - 1)
+	if endsExpr(prev) {
+		if end, ok := w.trySkip(func() int { return w.skipTypeArgs(j) }); ok && followsTypeArgs(w.tok(end)) {
+			w.erase(j, end)
+			return end
+		}
+		return j + 1
+	}
+	if prev.is("++") || prev.is("--") {
+		return j + 1
+	}
+	if end, ok := w.trySkip(func() int { return w.skipTypeParams(j) }); ok && w.tok(end).is("(") && w.isParams(end) {
+		w.erase(j, end)
+		return end
+	}
+	w.fail(j, "angle-bracket type assertions and JSX are not supported; use 'as'")
+	return j
+}
+
+// closesControlHead i 处是否为 if (...) / while (...) 等语句头的右括号
+func (w *walker) closesControlHead(i int) bool {
+	if !w.tok(i).is(")") {
+		return false
+	}
+	head := w.tok(w.match[i] - 1)
+	return head.is("if") || head.is("while") || head.is("for") || head.is("with") || head.is("await") && w.tok(w.match[i]-2).is("for")
+}
+
+// followsTypeArgs 泛型实参之后允许出现的单元（否则按小于号处理）
+func followsTypeArgs(t token) bool {
+	if t.kind == tokTemplate || t.kind == tokEOF {
+		return true
+	}
+	switch t.text {
+	case "(", ")", "]", ",", ";", ".", "?.":
+		return t.kind == tokPunct
+	}
+	return false
+}
+
+// trySkip 试探性地解析类型，失败时不报错
+func (w *walker) trySkip(fn func() int) (end int, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isBail := r.(*bailout); !isBail {
+				panic(r)
+			}
+			end, ok = 0, false
+		}
+	}()
+	return fn(), true
+}
+
+// isParams 判断 ( 是否为函数参数列表：其后为 =>、同一行的 {，或返回类型之后跟 => / {
+func (w *walker) isParams(open int) bool {
+	prev := w.tok(open - 1)
+	if prev.is("if") || prev.is("while") || prev.is("for") || prev.is("switch") || prev.is("with") {
+		return false
+	}
+	if prev.is("await") && w.tok(open-2).is("for") {
+		return false // for await (...)
+	}
+	if prev.is("catch") {
+		return true
+	}
+	close := w.match[open]
+	next := w.tok(close + 1)
+	switch {
+	case next.is("=>"):
+		return true
+	case next.is("{"):
+		return !next.newline
+	case next.is(":"):
+		// 三元表达式 a ? (b) : c => d 与 a ? f(b) : c => d 中的 : 不是返回类型
+		if prev.is("?") {
+			return false
+		}
+		end, ok := w.trySkip(func() int { return w.skipType(close + 2) })
+		if !ok {
+			return false
+		}
+		if w.tok(end).is("=>") {
+			return !endsExpr(prev) || prev.isWord("async")
+		}
+		return w.tok(end).is("{") && !w.tok(end).newline
+	}
+	return false
+}
+
+// returnType 擦除参数列表之后的返回类型注解
+func (w *walker) returnType(j int) int {
+	if !w.tok(j).is(":") {
+		return j
+	}
+	end := w.skipType(j + 1)
+	w.erase(j, end)
+	return end
+}
+
+// walkParams 处理参数列表 [from, to)：擦除 this 参数、可选标记与类型注解
+func (w *walker) walkParams(from, to int) {
+	const (
+		binding = iota
+		afterBinding
+		defaultValue
+	)
+	state := binding
+	for j := from; j < to; {
+		t := w.toks[j]
+		if t.is(",") && state != binding {
+			state = binding
+			j++
+			continue
+		}
+		switch state {
+		case binding:
+			if isAccessModifier(t) && !w.tok(j+1).is(",") && !w.tok(j+1).is(":") && !w.tok(j+1).is("?") && j+1 < to {
+				w.fail(j, "parameter properties are not supported; assign the field in the constructor body")
+			}
+			if t.is("@") {
+				w.fail(j, "parameter decorators are not supported")
+			}
+			if t.is("this") && w.tok(j+1).is(":") {
+				end := w.skipType(j + 2)
+				if w.tok(end).is(",") {
+					end++
+				}
+				w.erase(j, end)
+				j = end
+				continue
+			}
+			if t.is("...") {
+				j++
+				continue
+			}
+			if t.is("{") || t.is("[") {
+				w.walk(j+1, w.match[j], false)
+				j = w.match[j] + 1
+			} else {
+				j++
+			}
+			state = afterBinding
+		case afterBinding:
+			switch {
+			case t.is("?"):
+				w.erase(j, j+1)
+				j++
+			case t.is(":"):
+				end := w.skipType(j + 1)
+				w.erase(j, end)
+				j = end
+			case t.is("="):
+				state = defaultValue
+				j++
+			default:
+				state = defaultValue
+			}
+		default:
+			j = w.step(j)
+		}
+	}
+}
+
+func isAccessModifier(t token) bool {
+	switch t.text {
+	case "public", "private", "protected", "readonly", "override":
+		return t.kind == tokIdent
+	}
+	return false
+}
+
+// function 处理 function 声明/表达式；没有函数体的重载签名整体擦除
+func (w *walker) function(j int) int {
+	k := j + 1
+	if w.tok(k).is("*") {
+		k++
+	}
+	if w.tok(k).kind == tokIdent {
+		k++
+	}
+	if w.tok(k).is("<") {
+		end := w.skipTypeParams(k)
+		w.erase(k, end)
+		k = end
+	}
+	if !w.tok(k).is("(") {
+		return j + 1
+	}
+	close := w.match[k]
+	w.walkParams(k+1, close)
+	k = w.returnType(close + 1)
+	if w.tok(k).is("{") {
+		return k
+	}
+	start := j
+	for start > 0 && (w.tok(start-1).isWord("async") || w.tok(start-1).is("export") || w.tok(start-1).is("default")) {
+		start--
+	}
+	if w.tok(k).is(";") {
+		k++
+	}
+	w.erase(start, k)
+	return k
+}
+
+// class 处理类头（类型参数、泛型父类实参、implements）与类体
+func (w *walker) class(j int) int {
+	k := j + 1
+	if n := w.tok(k); n.kind == tokIdent && !n.isWord("implements") {
+		k++
+	}
+	if w.tok(k).is("<") {
+		end := w.skipTypeParams(k)
+		w.erase(k, end)
+		k = end
+	}
+	if w.tok(k).is("extends") {
+		k++
+		for !w.tok(k).is("{") && !w.tok(k).isWord("implements") && w.tok(k).kind != tokEOF {
+			if w.tok(k).is("<") {
+				end := w.skipTypeArgs(k)
+				w.erase(k, end)
+				k = end
+				continue
+			}
+			k = w.step(k)
+		}
+	}
+	if w.tok(k).isWord("implements") {
+		start := k
+		k = w.skipType(k + 1)
+		for w.tok(k).is(",") {
+			k = w.skipType(k + 1)
+		}
+		w.erase(start, k)
+	}
+	w.expect(k, "{")
+	w.walkClass(k+1, w.match[k])
+	return w.match[k] + 1
+}
+
+var memberModifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "readonly": true, "override": true,
+	"abstract": true, "declare": true, "static": true, "async": true, "get": true, "set": true, "accessor": true,
+}
+
+// walkClass 逐个处理类成员
+func (w *walker) walkClass(from, to int) {
+	for j := from; j < to; {
+		if w.toks[j].is(";") {
+			j++
+			continue
+		}
+		start := j
+		if w.toks[j].is("@") { // 装饰器原样保留
+			j++
+			for w.tok(j).kind == tokIdent || w.tok(j).is(".") {
+				j++
+			}
+			if w.tok(j).is("(") {
+				j = w.step(j)
+			}
+			continue
+		}
+
+		bodyless := false // abstract / declare 成员没有运行时语义，整体擦除
+		for memberModifiers[w.toks[j].text] && w.toks[j].kind == tokIdent && isMemberName(w.tok(j+1)) {
+			switch w.toks[j].text {
+			case "abstract", "declare":
+				bodyless = true
+			case "public", "private", "protected", "readonly", "override":
+				w.erase(j, j+1)
+			}
+			j++
+		}
+		if w.toks[j].is("{") { // static { } 初始化块
+			w.walk(j+1, w.match[j], true)
+			j = w.match[j] + 1
+			continue
+		}
+		if w.toks[j].is("[") && w.tok(j+1).kind == tokIdent && w.tok(j+2).is(":") { // 索引签名
+			end := w.skipType(w.match[j] + 2)
+			if w.tok(end).is(";") || w.tok(end).is(",") {
+				end++
+			}
+			w.erase(start, end)
+			j = end
+			continue
+		}
+
+		if w.toks[j].is("*") {
+			j++
+		}
+		if w.toks[j].is("[") {
+			w.walk(j+1, w.match[j], false)
+			j = w.match[j] + 1
+		} else {
+			j++
+		}
+		if w.tok(j).is("?") || w.tok(j).is("!") {
+			w.erase(j, j+1)
+			j++
+		}
+		if w.tok(j).is("<") {
+			end := w.skipTypeParams(j)
+			w.erase(j, end)
+			j = end
+		}
+
+		if w.tok(j).is("(") { // 方法
+			close := w.match[j]
+			w.walkParams(j+1, close)
+			j = w.returnType(close + 1)
+			if w.tok(j).is("{") && !bodyless {
+				w.walk(j+1, w.match[j], true)
+				j = w.match[j] + 1
+				continue
+			}
+			if w.tok(j).is(";") {
+				j++
+			}
+			w.erase(start, j) // 重载签名或抽象方法
+			continue
+		}
+
+		if w.tok(j).is(":") { // 字段类型
+			end := w.skipType(j + 1)
+			w.erase(j, end)
+			j = end
+		}
+		if w.tok(j).is("=") {
+			j++
+			for j < to && !w.toks[j].is(";") && !(w.toks[j].newline && (endsExpr(w.tok(j-1)) || w.tok(j-1).is("}")) && !continuesExpr(w.toks[j])) {
+				j = w.step(j)
+			}
+		}
+		if w.tok(j).is(";") {
+			j++
+		}
+		if bodyless {
+			w.erase(start, j)
+		}
+	}
+}
+
+// isMemberName 修饰符之后是否还是成员的一部分（否则修饰符本身就是成员名，如 get() {}）
+func isMemberName(t token) bool {
+	if t.kind == tokPunct {
+		return t.is("[") || t.is("{") || t.is("*")
+	}
+	return t.kind != tokEOF
+}
+
+// statementStart 粗略判断 j 是否位于语句开头
+func (w *walker) statementStart(j, from int) bool {
+	if j == from || w.toks[j].newline {
+		return true
+	}
+	prev := w.tok(j - 1)
+	return prev.is(";") || prev.is("{") || prev.is("}") || prev.is("export")
+}
+
+// statement 处理只在语句层出现的 TS 语法，返回是否已处理
+func (w *walker) statement(j int) (int, bool) {
+	t, n := w.toks[j], w.tok(j+1)
+	sameLine := !n.newline
+	switch {
+	case t.isWord("type") && n.kind == tokIdent && sameLine && (w.tok(j+2).is("=") || w.tok(j+2).is("<")):
+		end := w.typeAlias(j)
+		w.erase(j, end)
+		return end, true
+	case t.isWord("interface") && n.kind == tokIdent && sameLine && (w.tok(j+2).is("{") || w.tok(j+2).is("<") || w.tok(j+2).is("extends")):
+		end := w.interfaceDecl(j)
+		w.erase(j, end)
+		return end, true
+	case t.isWord("declare") && sameLine && declarable(n):
+		end := w.statementEnd(j)
+		w.erase(j, end)
+		return end, true
+	case t.is("enum") && n.kind == tokIdent, t.is("const") && n.is("enum"):
+		w.fail(j, "enums are not supported; use a plain object instead")
+	case (t.isWord("namespace") || t.isWord("module")) && (n.kind == tokIdent || n.kind == tokString) && sameLine &&
+		(w.tok(j+2).is("{") || w.tok(j+2).is(".")):
+		w.fail(j, "namespaces are not supported; use ES modules instead")
+	case t.is("import") && !n.is("(") && !n.is("."):
+		return w.importDecl(j), true
+	case t.is("export"):
+		return w.exportDecl(j)
+	}
+	return j, false
+}
+
+func declarable(t token) bool {
+	switch t.text {
+	case "const", "let", "var", "function", "class", "module", "namespace", "global", "enum", "type", "interface", "abstract", "async":
+		return t.kind == tokIdent || t.kind == tokKeyword
+	}
+	return false
+}
+
+// typeAlias 解析 type X<T> = ...，返回结束下标
+func (w *walker) typeAlias(j int) int {
+	k := j + 2
+	if w.tok(k).is("<") {
+		k = w.skipTypeParams(k)
+	}
+	w.expect(k, "=")
+	k = w.skipType(k + 1)
+	if w.tok(k).is(";") {
+		k++
+	}
+	return k
+}
+
+// interfaceDecl 解析 interface X<T> extends A, B { ... }，返回结束下标
+func (w *walker) interfaceDecl(j int) int {
+	k := j + 2
+	if w.tok(k).is("<") {
+		k = w.skipTypeParams(k)
+	}
+	if w.tok(k).is("extends") {
+		k = w.skipType(k + 1)
+		for w.tok(k).is(",") {
+			k = w.skipType(k + 1)
+		}
+	}
+	w.expect(k, "{")
+	return w.match[k] + 1
+}
+
+// statementEnd 找到 declare 语句的结束位置（; 或不再延续的换行）
+func (w *walker) statementEnd(j int) int {
+	for k := j; ; {
+		t := w.tok(k)
+		switch {
+		case t.kind == tokEOF || t.is("}") || t.is(")") || t.is("]"):
+			return k
+		case k > j && t.newline && !continuesLine(w.tok(k-1)) && !continuesExpr(t) && !t.is("{"):
+			return k
+		case t.is(";"):
+			return k + 1
+		case t.is("(") || t.is("[") || t.is("{"):
+			k = w.match[k] + 1
+		default:
+			k++
+		}
+	}
+}
+
+// continuesLine 行尾单元是否要求下一行继续（运算符、逗号等）
+func continuesLine(t token) bool {
+	if t.kind == tokPunct {
+		return !(t.is(")") || t.is("]") || t.is("}") || t.is(">"))
+	}
+	if t.kind == tokKeyword {
+		return !operandKeywords[t.text]
+	}
+	return t.isWord("keyof")
+}
+
+// importDecl 处理 import 语句：擦除 import type 与类型说明符，记录模块引用
+func (w *walker) importDecl(j int) int {
+	k := j + 1
+	if n := w.tok(k); n.isWord("type") && !w.tok(k+1).isWord("from") && !w.tok(k+1).is(",") && !w.tok(k+1).is("=") {
+		end := w.moduleClauseEnd(k, false)
+		w.erase(j, end)
+		return end
+	}
+	if w.tok(k).kind == tokIdent && w.tok(k+1).is("=") {
+		w.fail(j, "'import x = require()' is not supported; use ES module imports")
+	}
+	w.t.module = true
+	return w.moduleClauseEnd(k, true)
+}
+
+// exportDecl 处理 export 语句中的 TS 语法；普通的 export 声明交回 walk 继续处理
+func (w *walker) exportDecl(j int) (int, bool) {
+	n, nn := w.tok(j+1), w.tok(j+2)
+	switch {
+	case n.isWord("type") && (nn.is("{") || nn.is("*")):
+		end := w.moduleClauseEnd(j+2, false)
+		w.erase(j, end)
+		return end, true
+	case n.isWord("type") && nn.kind == tokIdent && (w.tok(j+3).is("=") || w.tok(j+3).is("<")):
+		end := w.typeAlias(j + 1)
+		w.erase(j, end)
+		return end, true
+	case n.isWord("interface") && nn.kind == tokIdent:
+		end := w.interfaceDecl(j + 1)
+		w.erase(j, end)
+		return end, true
+	case n.is("default") && nn.isWord("interface") && w.tok(j+3).kind == tokIdent:
+		end := w.interfaceDecl(j + 2)
+		w.erase(j, end)
+		return end, true
+	case n.isWord("declare") && declarable(nn):
+		end := w.statementEnd(j + 1)
+		w.erase(j, end)
+		return end, true
+	case n.isWord("as") && nn.isWord("namespace"):
+		end := w.statementEnd(j)
+		w.erase(j, end)
+		return end, true
+	case n.is("="):
+		w.fail(j, "'export =' is not supported; use 'export default'")
+	case n.is("import"):
+		w.fail(j, "'export import' is not supported")
+	case n.is("{") || n.is("*"):
+		w.t.module = true
+		return w.moduleClauseEnd(j+1, true), true
+	}
+	w.t.module = true
+	return j + 1, true
+}
+
+// moduleClauseEnd 跳过 import/export 的说明符与 from 子句，擦除 { type X } 形式的类型说明符
+func (w *walker) moduleClauseEnd(k int, record bool) int {
+	for {
+		t := w.tok(k)
+		switch {
+		case t.is("{"):
+			w.typeSpecifiers(k)
+			k = w.match[k] + 1
+		case t.kind == tokString:
+			if record {
+				w.addRef(t)
+			}
+			k++
+			if a := w.tok(k); (a.is("with") || a.isWord("assert")) && !a.newline && w.tok(k+1).is("{") {
+				k = w.match[k+1] + 1
+			}
+			if w.tok(k).is(";") {
+				k++
+			}
+			return k
+		case t.is(";"):
+			return k + 1
+		case t.kind == tokEOF, t.newline && k > 0 && !continuesLine(w.tok(k-1)) && !t.isWord("from") && !t.is("{") && !t.is(","):
+			return k
+		default:
+			k++
+		}
+	}
+}
+
+// typeSpecifiers 擦除 { a, type B, type C as D } 中带 type 修饰的说明符
+func (w *walker) typeSpecifiers(open int) {
+	close := w.match[open]
+	for k := open + 1; k < close; {
+		end := k
+		for end < close && !w.toks[end].is(",") {
+			end++
+		}
+		next := end
+		if end < close {
+			next++ // 连同逗号
+		}
+		if w.toks[k].isWord("type") && end-k > 1 && !w.toks[k+1].isWord("as") {
+			w.erase(k, next)
+		}
+		k = next
+	}
+}
+
+func (w *walker) addRef(t token) {
+	path, ok := unquote(t.text)
+	if ok {
+		w.t.refs = append(w.t.refs, importRef{start: t.start, end: t.end, path: path})
+	}
+}
+
+// unquote 解析不含转义的模块路径字符串
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || strings.ContainsRune(s, '\\') {
+		return "", false
+	}
+	return s[1 : len(s)-1], true
+}
@@ -0,0 +1,228 @@
+package typescript
+
+// 类型语法只需要被准确地跳过（整体擦除），因此这里只识别边界，不建立语法树
+
+// startsType 该单元能否作为类型的开头
+func startsType(t token) bool {
+	switch t.kind {
+	case tokEOF, tokRegExp, tokPrivateName:
+		return false
+	case tokPunct:
+		switch t.text {
+		case "(", "[", "{", "<", "-", "|", "&":
+			return true
+		}
+		return false
+	case tokKeyword:
+		return !t.is("in") && !t.is("instanceof")
+	}
+	return true
+}
+
+// skipType 跳过一个完整的类型（含条件类型），返回其后的下标
+func (w *walker) skipType(k int) int {
+	k = w.skipUnion(k)
+	if w.tok(k).is("extends") && !w.tok(k).newline {
+		k = w.skipUnion(k + 1)
+		w.expect(k, "?")
+		k = w.skipType(k + 1)
+		w.expect(k, ":")
+		k = w.skipType(k + 1)
+	}
+	return k
+}
+
+func (w *walker) skipUnion(k int) int {
+	if w.tok(k).is("|") {
+		k++
+	}
+	k = w.skipIntersection(k)
+	for w.tok(k).is("|") {
+		k = w.skipIntersection(k + 1)
+	}
+	return k
+}
+
+func (w *walker) skipIntersection(k int) int {
+	if w.tok(k).is("&") {
+		k++
+	}
+	k = w.skipOperator(k)
+	for w.tok(k).is("&") {
+		k = w.skipOperator(k + 1)
+	}
+	return k
+}
+
+// skipOperator keyof / readonly / unique / infer 前缀，以及 T[] / T[K] 后缀
+func (w *walker) skipOperator(k int) int {
+	t := w.tok(k)
+	if (t.isWord("keyof") || t.isWord("readonly") || t.isWord("unique")) && startsType(w.tok(k+1)) && !w.tok(k+1).newline {
+		return w.skipOperator(k + 1)
+	}
+	if t.isWord("infer") && w.tok(k+1).kind == tokIdent {
+		k += 2
+		if w.tok(k).is("extends") {
+			if end, ok := w.trySkip(func() int { return w.skipUnion(k + 1) }); ok && !w.tok(end).is("?") {
+				k = end
+			}
+		}
+		return k
+	}
+	k = w.skipPrimary(k)
+	for w.tok(k).is("[") && !w.tok(k).newline {
+		if !w.tok(k + 1).is("]") {
+			w.skipType(k + 1)
+		}
+		k = w.match[k] + 1
+	}
+	return k
+}
+
+func (w *walker) skipPrimary(k int) int {
+	t := w.tok(k)
+	switch {
+	case t.is("("):
+		close := w.match[k]
+		if w.tok(close+1).is("=>") && w.functionTypeParams(k) { // 函数类型
+			return w.skipType(close + 2)
+		}
+		end := w.skipType(k + 1)
+		w.expect(end, ")")
+		return close + 1
+	case t.is("<"): // 泛型函数类型 <T>(x: T) => T
+		k = w.skipTypeParams(k)
+		w.expect(k, "(")
+		k = w.match[k] + 1
+		w.expect(k, "=>")
+		return w.skipType(k + 1)
+	case t.isWord("abstract") && w.tok(k+1).is("new"):
+		return w.skipPrimary(k + 1)
+	case t.is("new"): // 构造签名类型
+		k++
+		if w.tok(k).is("<") {
+			k = w.skipTypeParams(k)
+		}
+		w.expect(k, "(")
+		k = w.match[k] + 1
+		w.expect(k, "=>")
+		return w.skipType(k + 1)
+	case t.is("{"), t.is("["): // 对象类型、映射类型、元组
+		return w.match[k] + 1
+	case t.kind == tokString, t.kind == tokNumber, t.kind == tokTemplate:
+		return k + 1
+	case t.is("-") && w.tok(k+1).kind == tokNumber:
+		return k + 2
+	case t.is("typeof"):
+		k++
+		if w.tok(k).is("import") {
+			return w.skipImportType(k)
+		}
+		k = w.skipEntityName(k)
+		if w.tok(k).is("<") && !w.tok(k).newline {
+			k = w.skipTypeArgs(k)
+		}
+		return k
+	case t.is("import"):
+		return w.skipImportType(k)
+	case t.isWord("asserts") && (w.tok(k+1).kind == tokIdent || w.tok(k+1).is("this")) && !w.tok(k+1).newline:
+		k += 2
+		if w.tok(k).isWord("is") {
+			k = w.skipType(k + 1)
+		}
+		return k
+	case t.kind == tokIdent || t.kind == tokKeyword && (t.is("void") || t.is("null") || t.is("this") || t.is("true") || t.is("false")):
+		if w.tok(k+1).isWord("is") && !w.tok(k+1).newline { // 类型谓词 x is T
+			return w.skipType(k + 2)
+		}
+		k = w.skipEntityName(k)
+		if w.tok(k).is("<") && !w.tok(k).newline {
+			k = w.skipTypeArgs(k)
+		}
+		return k
+	}
+	w.fail(k, "type expected")
+	return k
+}
+
+// functionTypeParams 区分函数类型的参数列表 (a: T) => U 与带括号的类型 ((a: T) => U)
+func (w *walker) functionTypeParams(open int) bool {
+	first, second := w.tok(open+1), w.tok(open+2)
+	switch {
+	case first.is(")") || first.is("...") || first.is("{") || first.is("["):
+		return true
+	case first.kind == tokIdent || first.is("this"):
+		return second.is(":") || second.is("?") || second.is(",") || second.is(")") || second.is("=")
+	}
+	return false
+}
+
+// skipEntityName 跳过 A.B.C 形式的限定名
+func (w *walker) skipEntityName(k int) int {
+	if t := w.tok(k); t.kind != tokIdent && t.kind != tokKeyword {
+		w.fail(k, "identifier expected")
+	}
+	k++
+	for w.tok(k).is(".") && (w.tok(k+1).kind == tokIdent || w.tok(k+1).kind == tokKeyword) {
+		k += 2
+	}
+	return k
+}
+
+// skipImportType import("x").A.B<T>
+func (w *walker) skipImportType(k int) int {
+	w.expect(k+1, "(")
+	k = w.match[k+1] + 1
+	for w.tok(k).is(".") {
+		k += 2
+	}
+	if w.tok(k).is("<") {
+		k = w.skipTypeArgs(k)
+	}
+	return k
+}
+
+// skipTypeArgs 跳过 <A, B>
+func (w *walker) skipTypeArgs(k int) int {
+	w.expect(k, "<")
+	k = w.skipType(k + 1)
+	for w.tok(k).is(",") {
+		if w.tok(k + 1).is(">") {
+			k++
+			break
+		}
+		k = w.skipType(k + 1)
+	}
+	w.expect(k, ">")
+	return k + 1
+}
+
+// skipTypeParams 跳过 <const T extends X = Y, in out U>
+func (w *walker) skipTypeParams(k int) int {
+	w.expect(k, "<")
+	k++
+	for {
+		for t := w.tok(k); (t.is("const") || t.is("in") || t.isWord("out")) && w.tok(k+1).kind == tokIdent; t = w.tok(k) {
+			k++
+		}
+		if w.tok(k).kind != tokIdent {
+			w.fail(k, "type parameter name expected")
+		}
+		k++
+		if w.tok(k).is("extends") {
+			k = w.skipType(k + 1)
+		}
+		if w.tok(k).is("=") {
+			k = w.skipType(k + 1)
+		}
+		if !w.tok(k).is(",") {
+			break
+		}
+		k++
+		if w.tok(k).is(">") {
+			break
+		}
+	}
+	w.expect(k, ">")
+	return k + 1
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -19,9 +20,16 @@ func GetFreePort() (int, error) {
 	return l.Addr().(*net.TCPAddr).Port, nil
 }
 
-// WaitPortListening 等待端口监听成功（超时5秒）
-func WaitPortListening(host string, port int) error {
-	addr := net.JoinHostPort(host, strconv.Itoa(port))
+// ErrWaitAborted 等待端口期间 done 被关闭（如进程已退出）
+var ErrWaitAborted = errors.New("aborted while waiting for port")
+
+// WaitPortListening 等待端口监听成功（超时5秒），done 关闭时立即返回 ErrWaitAborted
+func WaitPortListening(host string, port int, done <-chan struct{}) error {
+	return WaitListening("tcp", net.JoinHostPort(host, strconv.Itoa(port)), done)
+}
+
+// WaitListening 等待地址（tcp 或 unix socket）可以连接（超时5秒），done 关闭时立即返回 ErrWaitAborted
+func WaitListening(network, address string, done <-chan struct{}) error {
 	timeout := time.After(5 * time.Second)
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
@@ -29,9 +37,11 @@ func WaitPortListening(host string, port int) error {
 	for {
 		select {
 		case <-timeout:
-			return fmt.Errorf("port %d not listening after 5s", port)
+			return fmt.Errorf("%s not listening after 5s", address)
+		case <-done:
+			return ErrWaitAborted
 		case <-ticker.C:
-			conn, err := net.Dial("tcp", addr)
+			conn, err := net.Dial(network, address)
 			if err == nil {
 				conn.Close()
 				return nil
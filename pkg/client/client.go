@@ -0,0 +1,188 @@
+// Package client 是 FaaS 控制面的 Go SDK，封装部署 API（/api/v2）与子域名调用
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client 控制面客户端，可并发使用
+type Client struct {
+	baseURL    string // 部署 API 地址（如 http://localhost:8081）
+	proxyURL   string // 路由转发地址（如 http://localhost:80），Invoke 使用
+	token      string
+	httpClient *http.Client
+	retries    int           // 瞬时错误的最大重试次数
+	backoff    time.Duration // 首次重试等待时间，之后指数增长
+}
+
+// Option 客户端配置项
+type Option func(*Client)
+
+// WithHTTPClient 使用自定义 http.Client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithToken 设置部署令牌（X-Deploy-Token）
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithProxyURL 设置路由转发服务地址，未设置时 Invoke 返回错误
+func WithProxyURL(proxyURL string) Option {
+	return func(c *Client) { c.proxyURL = strings.TrimRight(proxyURL, "/") }
+}
+
+// WithRetry 设置瞬时错误的重试次数与初始退避时间（retries=0 关闭重试）
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// New 创建客户端，baseURL 为部署 API 地址
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		retries:    3,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// maxRetryAfter Retry-After 等待上限，避免被服务端长时间阻塞
+const maxRetryAfter = 10 * time.Second
+
+// do 发送 API 请求并把响应 JSON 解析到 out（out 为 nil 时丢弃响应体）
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var payload []byte
+	var contentType string
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload, contentType = data, "application/json"
+	}
+	data, err := c.doRaw(ctx, method, path, query, contentType, payload)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// doRaw 发送请求体为 payload（类型为 contentType，为空表示无请求体）的 API 请求并返回响应体，非 2xx 响应返回 *APIError
+func (c *Client) doRaw(ctx context.Context, method, path string, query url.Values, contentType string, payload []byte) ([]byte, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	resp, data, err := c.send(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if c.token != "" {
+			req.Header.Set("X-Deploy-Token", c.token)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, parseError(resp, data)
+	}
+	return data, nil
+}
+
+// send 发送请求并读取响应体，按需重试：
+// 429/502/503/504 对所有方法重试；网络错误只对幂等方法重试，避免重复部署
+func (c *Client) send(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, []byte, error) {
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		resp, err := c.httpClient.Do(req)
+		var data []byte
+		if err == nil {
+			data, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		if attempt >= c.retries || ctx.Err() != nil {
+			return resp, data, err
+		}
+		switch {
+		case err != nil && !idempotent(req.Method):
+			return resp, data, err
+		case err == nil && !retryableStatus(resp.StatusCode):
+			return resp, data, nil
+		}
+
+		delay := wait
+		if err == nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = after
+			}
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				return resp, data, nil
+			}
+			return nil, nil, errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		wait *= 2
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter 解析 Retry-After（秒），超过上限时截断
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryAfter), true
+}
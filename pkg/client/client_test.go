@@ -0,0 +1,321 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"faas/internal/api"
+	"faas/internal/registry"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeWorkerdBin 由 TestMain 从 internal/api/testdata/fakeworkerd 编译的 workerd 替身
+var fakeWorkerdBin string
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	dir, err := os.MkdirTemp("", "fakeworkerd")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fakeWorkerdBin = filepath.Join(dir, "workerd")
+	build := exec.Command("go", "build", "-o", fakeWorkerdBin, "../../internal/api/testdata/fakeworkerd")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "build fake workerd:", err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestClient 启动进程内的部署 API 与路由转发服务
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	reg, err := registry.New(fakeWorkerdBin, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reg.Close() })
+
+	apiServer := httptest.NewServer(api.NewRouter(reg))
+	t.Cleanup(apiServer.Close)
+	proxyServer := httptest.NewServer(api.ProxyHandler(reg))
+	t.Cleanup(proxyServer.Close)
+
+	return New(apiServer.URL, WithProxyURL(proxyServer.URL), WithRetry(0, 0))
+}
+
+// workerCode 返回固定正文的函数代码（fake workerd 以 new Response 的参数作为响应正文）
+func workerCode(body string) string {
+	return fmt.Sprintf(`export default { fetch() { return new Response(%q) } }`, body)
+}
+
+var testCode = workerCode("hi")
+
+func TestLifecycle(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	v1, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "js", Code: workerCode("hello v1"), Version: "v1"})
+	if err != nil {
+		t.Fatalf("deploy v1: %v", err)
+	}
+	if v1.Version != "v1" || v1.Status != "running" || v1.Subdomain != "v1.hello.func.local" {
+		t.Fatalf("deploy v1 = %+v", v1)
+	}
+	if _, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "js", Code: workerCode("hello v2"), Version: "v2", EnvVars: map[string]string{"A": "1"}}); err != nil {
+		t.Fatalf("deploy v2: %v", err)
+	}
+
+	list, err := c.List(ctx, &ListOptions{Name: "hel"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if list.Total != 1 || list.Functions[0].LatestVersion != "v2" || list.Functions[0].Versions != 2 {
+		t.Fatalf("list = %+v", list)
+	}
+
+	// 调用 latest 与指定版本
+	resp, err := c.Invoke(ctx, "hello", &InvokeRequest{Path: "/ping"})
+	if err != nil {
+		t.Fatalf("invoke latest: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != "hello v2" {
+		t.Fatalf("invoke latest = %d %q", resp.StatusCode, resp.Body)
+	}
+	resp, err = c.Invoke(ctx, "hello", &InvokeRequest{Target: "v1"})
+	if err != nil || string(resp.Body) != "hello v1" {
+		t.Fatalf("invoke v1 = %v, %v", resp, err)
+	}
+
+	if err := c.Rollback(ctx, "hello", "", "v1"); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	fn, err := c.Get(ctx, "hello")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if fn.LatestVersion != "v1" || fn.Aliases["latest"] != "v1" || len(fn.Versions) != 2 {
+		t.Fatalf("get after rollback = %+v", fn)
+	}
+
+	stopped, err := c.Stop(ctx, "hello", "v2")
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if stopped.Status != "suspended" {
+		t.Fatalf("stop status = %s", stopped.Status)
+	}
+	if _, err := c.Stop(ctx, "hello", "v2"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stop twice: err = %v, want ErrConflict", err)
+	}
+
+	lines, err := waitLogs(ctx, c, "hello", "v1")
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if len(lines) == 0 || !strings.Contains(lines[0].Line, "fakeworkerd listening on") {
+		t.Fatalf("logs = %+v", lines)
+	}
+
+	if err := c.DeleteVersion(ctx, "hello", "v2"); err != nil {
+		t.Fatalf("delete version: %v", err)
+	}
+	if err := c.Delete(ctx, "hello"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := c.Get(ctx, "hello"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeployIsIdempotent(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	first, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "js", Code: testCode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 相同代码与配置：沿用已有版本（包括带相同版本号的重试）
+	for _, version := range []string{"", first.Version} {
+		again, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "js", Code: testCode, Version: version})
+		if err != nil {
+			t.Fatalf("redeploy %q: %v", version, err)
+		}
+		if again.Version != first.Version {
+			t.Fatalf("redeploy %q created version %s, want %s", version, again.Version, first.Version)
+		}
+	}
+
+	// 配置变化时创建新版本，再次部署原配置则 latest 回到原版本
+	if _, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "js", Code: testCode, Version: "env", EnvVars: map[string]string{"A": "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	again, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "js", Code: testCode, Alias: "stable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn, err := c.Get(ctx, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Version != first.Version || fn.LatestVersion != first.Version || fn.Aliases["stable"] != first.Version || len(fn.Versions) != 2 {
+		t.Fatalf("after redeploy: %+v", fn)
+	}
+}
+
+// waitLogs 等待 workerd 输出写入日志文件
+func waitLogs(ctx context.Context, c *Client, funcName, version string) ([]LogLine, error) {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		lines, err := c.Logs(ctx, funcName, &LogOptions{Version: version})
+		if err != nil || len(lines) > 0 || time.Now().After(deadline) {
+			return lines, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "py"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrValidation) {
+		t.Fatalf("invalid deploy: err = %v, want validation error", err)
+	}
+	if apiErr.Code != "validation_failed" || len(apiErr.Details) != 2 {
+		t.Fatalf("invalid deploy: %+v", apiErr)
+	}
+
+	if _, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "js", Code: testCode, Version: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Deploy(ctx, "hello", &DeployRequest{Runtime: "js", Code: testCode + "\n", Version: "v1"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("duplicate version: err = %v, want ErrConflict", err)
+	}
+	if err := c.Rollback(ctx, "hello", "", "v9"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("rollback unknown version: err = %v, want ErrNotFound", err)
+	}
+	// v1 接口的错误（{"error": "..."}）同样被解析
+	if _, err := c.Logs(ctx, "missing", nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("logs of missing function: err = %v, want ErrNotFound", err)
+	}
+	if _, err := c.Invoke(ctx, "missing", &InvokeRequest{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("invoke missing function: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	v1, err := c.Deploy(ctx, "notes", &DeployRequest{Runtime: "js", Code: testCode, Version: "v1", Database: &DatabaseConfig{Binding: "DB"}})
+	if err != nil {
+		t.Fatalf("deploy: %v", err)
+	}
+	if v1.Database.Binding != "DB" {
+		t.Fatalf("deploy database = %+v", v1.Database)
+	}
+
+	migrations := []Migration{{Name: "001_init", SQL: "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)"}}
+	if applied, err := c.Migrate(ctx, "notes", migrations); err != nil || len(applied) != 1 {
+		t.Fatalf("migrate = %v, %v", applied, err)
+	}
+	if applied, err := c.Migrate(ctx, "notes", migrations); err != nil || len(applied) != 0 {
+		t.Fatalf("migrate again = %v, %v", applied, err)
+	}
+	if list, err := c.Migrations(ctx, "notes"); err != nil || len(list) != 1 || list[0].Name != "001_init" || list[0].AppliedAt.IsZero() {
+		t.Fatalf("migrations = %+v, %v", list, err)
+	}
+
+	if result, err := c.Query(ctx, "notes", "INSERT INTO notes (body) VALUES (?)", "hello"); err != nil || result.RowsAffected != 1 || result.LastInsertID != 1 {
+		t.Fatalf("insert = %+v, %v", result, err)
+	}
+	snapshot, err := c.ExportDatabase(ctx, "notes")
+	if err != nil || !strings.HasPrefix(string(snapshot), "SQLite format 3") {
+		t.Fatalf("export = %d bytes, %v", len(snapshot), err)
+	}
+	if _, err := c.Query(ctx, "notes", "DELETE FROM notes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ImportDatabase(ctx, "notes", snapshot); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	result, err := c.Query(ctx, "notes", "SELECT id, body FROM notes")
+	if err != nil || fmt.Sprint(result.Rows) != "[[1 hello]]" {
+		t.Fatalf("query after import = %+v, %v", result, err)
+	}
+
+	var apiErr *APIError
+	if _, err := c.Query(ctx, "notes", "SELEC 1"); !errors.As(err, &apiErr) || !errors.Is(err, ErrBadRequest) || apiErr.Code != "query_failed" {
+		t.Fatalf("invalid query: err = %v, want query_failed", err)
+	}
+	if err := c.ImportDatabase(ctx, "notes", []byte("not a database")); !errors.Is(err, ErrValidation) {
+		t.Fatalf("import invalid file: err = %v, want ErrValidation", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"functions":[],"page":1,"pageSize":20,"total":0}`)
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetry(3, time.Millisecond))
+	if _, err := c.List(context.Background(), nil); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("attempts = %d, want 3", n)
+	}
+
+	// 重试次数用尽后返回最后一次的错误
+	attempts.Store(0)
+	c = New(server.URL, WithRetry(1, time.Millisecond))
+	if _, err := c.List(context.Background(), nil); !errors.Is(err, ErrServer) {
+		t.Fatalf("list: err = %v, want ErrServer", err)
+	}
+}
+
+func TestRetryHonorsContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := New(server.URL).Get(ctx, "hello")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("retry ignored context cancellation (%s)", elapsed)
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Migrate 按顺序执行尚未执行过的数据库迁移，返回本次执行的迁移名。
+// 某个迁移失败时返回错误（query_failed），之前的迁移保留
+func (c *Client) Migrate(ctx context.Context, funcName string, migrations []Migration) ([]string, error) {
+	var resp struct {
+		Applied []string `json:"applied"`
+	}
+	body := map[string]any{"migrations": migrations}
+	if err := c.do(ctx, http.MethodPost, databasePath(funcName)+"/migrations", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Applied, nil
+}
+
+// Migrations 列出已执行的数据库迁移
+func (c *Client) Migrations(ctx context.Context, funcName string) ([]AppliedMigration, error) {
+	var resp struct {
+		Migrations []AppliedMigration `json:"migrations"`
+	}
+	if err := c.do(ctx, http.MethodGet, databasePath(funcName)+"/migrations", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Migrations, nil
+}
+
+// Query 在函数的数据库上执行一条语句，params 依次绑定 ? 占位符
+func (c *Client) Query(ctx context.Context, funcName, sql string, params ...any) (*QueryResult, error) {
+	var result QueryResult
+	body := map[string]any{"sql": sql, "params": params}
+	if err := c.do(ctx, http.MethodPost, databasePath(funcName)+"/query", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExportDatabase 下载函数数据库的快照（SQLite 文件）
+func (c *Client) ExportDatabase(ctx context.Context, funcName string) ([]byte, error) {
+	return c.doRaw(ctx, http.MethodGet, databasePath(funcName)+"/export", nil, "", nil)
+}
+
+// ImportDatabase 用 SQLite 文件替换函数的数据库
+func (c *Client) ImportDatabase(ctx context.Context, funcName string, data []byte) error {
+	_, err := c.doRaw(ctx, http.MethodPut, databasePath(funcName)+"/import", nil, "application/vnd.sqlite3", data)
+	return err
+}
+
+func databasePath(funcName string) string {
+	return functionPath(funcName) + "/database"
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 按错误类别判断：errors.Is(err, client.ErrNotFound)
+var (
+	ErrBadRequest  = errors.New("bad request")
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrRateLimited = errors.New("rate limited")
+	ErrServer      = errors.New("server error")
+)
+
+// FieldError 字段校验错误
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+// Diagnostic TypeScript 编译错误位置
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// StartError 实例启动失败（stage 为 start）或新版本未通过冒烟测试（smoke_test）。
+// 能解析出错误位置时 File / Line / Column 为出错的文件与位置，Source 为该行源码；Output 为实例输出
+type StartError struct {
+	Stage   string    `json:"stage"`
+	Message string    `json:"message"`
+	File    string    `json:"file"`
+	Line    int       `json:"line"`
+	Column  int       `json:"column"`
+	Source  string    `json:"source"`
+	Output  string    `json:"output"`
+	At      time.Time `json:"at"`
+}
+
+// APIError 服务端返回的非 2xx 响应
+type APIError struct {
+	StatusCode  int
+	Code        string // v2 错误码（bad_request / not_found / conflict / validation_failed / compile_failed / start_failed / smoke_test_failed / query_failed / internal_error）
+	Message     string
+	Details     []FieldError
+	Diagnostics []Diagnostic // TypeScript 编译错误（compile_failed）
+	Start       *StartError  // 新版本启动失败（start_failed / smoke_test_failed）
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// Is 将状态码映射为错误类别
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrValidation:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// parseError 解析错误响应，兼容 v2 的 {"error":{...}} 与 v1 的 {"error":"..."}
+func parseError(resp *http.Response, data []byte) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &envelope) == nil && len(envelope.Error) > 0 {
+		var v2 struct {
+			Code        string       `json:"code"`
+			Message     string       `json:"message"`
+			Details     []FieldError `json:"details"`
+			Diagnostics []Diagnostic `json:"diagnostics"`
+			Start       *StartError  `json:"start"`
+		}
+		var v1 string
+		if json.Unmarshal(envelope.Error, &v2) == nil {
+			apiErr.Code, apiErr.Message, apiErr.Details, apiErr.Diagnostics = v2.Code, v2.Message, v2.Details, v2.Diagnostics
+			apiErr.Start = v2.Start
+		} else if json.Unmarshal(envelope.Error, &v1) == nil {
+			apiErr.Message = v1
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
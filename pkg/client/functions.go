@@ -0,0 +1,149 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Deploy 部署新版本，req.Version 为空时由服务端生成版本号
+func (c *Client) Deploy(ctx context.Context, funcName string, req *DeployRequest) (*Version, error) {
+	var v Version
+	if err := c.do(ctx, http.MethodPost, functionPath(funcName)+"/versions", nil, req, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// List 分页列出函数
+func (c *Client) List(ctx context.Context, opts *ListOptions) (*FunctionList, error) {
+	query := url.Values{}
+	if opts != nil {
+		setInt(query, "page", opts.Page)
+		setInt(query, "page_size", opts.PageSize)
+		setString(query, "name", opts.Name)
+		setString(query, "status", opts.Status)
+		setString(query, "runtime", opts.Runtime)
+	}
+	var list FunctionList
+	if err := c.do(ctx, http.MethodGet, "/api/v2/functions", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Get 查询函数详情（包含所有版本与别名）
+func (c *Client) Get(ctx context.Context, funcName string) (*Function, error) {
+	var fn Function
+	if err := c.do(ctx, http.MethodGet, functionPath(funcName), nil, nil, &fn); err != nil {
+		return nil, err
+	}
+	return &fn, nil
+}
+
+// Rollback 将别名指向指定版本，alias 为空时回滚 latest
+func (c *Client) Rollback(ctx context.Context, funcName, alias, version string) error {
+	if alias == "" {
+		alias = "latest"
+	}
+	body := map[string]string{"version": version}
+	return c.do(ctx, http.MethodPut, functionPath(funcName)+"/aliases/"+url.PathEscape(alias), nil, body, nil)
+}
+
+// Stop 停止版本的 workerd 进程
+func (c *Client) Stop(ctx context.Context, funcName, version string) (*Version, error) {
+	var v Version
+	body := map[string]string{"status": "suspended"}
+	if err := c.do(ctx, http.MethodPatch, versionPath(funcName, version), nil, body, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Delete 删除函数及其所有版本
+func (c *Client) Delete(ctx context.Context, funcName string) error {
+	return c.do(ctx, http.MethodDelete, functionPath(funcName), nil, nil, nil)
+}
+
+// DeleteVersion 删除函数的指定版本
+func (c *Client) DeleteVersion(ctx context.Context, funcName, version string) error {
+	return c.do(ctx, http.MethodDelete, versionPath(funcName, version), nil, nil, nil)
+}
+
+// Logs 查询函数日志
+func (c *Client) Logs(ctx context.Context, funcName string, opts *LogOptions) ([]LogLine, error) {
+	query := url.Values{}
+	if opts != nil {
+		setString(query, "version", opts.Version)
+		setString(query, "since", opts.Since)
+		setInt(query, "limit", opts.Limit)
+	}
+	var resp struct {
+		Lines []LogLine `json:"lines"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/logs/"+url.PathEscape(funcName), query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Lines, nil
+}
+
+// Invoke 通过路由转发服务调用函数（按子域名路由，需 WithProxyURL）
+// 函数自身返回的非 2xx 状态不视为错误；平台返回的错误（函数不存在、被限流、唤醒失败）以 *APIError 返回
+func (c *Client) Invoke(ctx context.Context, funcName string, req *InvokeRequest) (*InvokeResponse, error) {
+	if c.proxyURL == "" {
+		return nil, errors.New("client: proxy URL not configured")
+	}
+	method, path := req.Method, req.Path
+	if method == "" {
+		method = http.MethodGet
+	}
+	if path == "" {
+		path = "/"
+	}
+	host := funcName + ".func.local"
+	if req.Target != "" {
+		host = req.Target + "." + host
+	}
+
+	resp, data, err := c.send(ctx, func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, method, c.proxyURL+path, bytes.NewReader(req.Body))
+		if err != nil {
+			return nil, err
+		}
+		for k, values := range req.Header {
+			httpReq.Header[k] = values
+		}
+		httpReq.Host = host
+		return httpReq, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.Get("X-Faas-Error") != "" {
+		return nil, parseError(resp, data)
+	}
+	return &InvokeResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: data}, nil
+}
+
+func functionPath(funcName string) string {
+	return "/api/v2/functions/" + url.PathEscape(funcName)
+}
+
+func versionPath(funcName, version string) string {
+	return functionPath(funcName) + "/versions/" + url.PathEscape(version)
+}
+
+func setString(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+func setInt(query url.Values, key string, value int) {
+	if value > 0 {
+		query.Set(key, strconv.Itoa(value))
+	}
+}
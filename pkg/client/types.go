@@ -0,0 +1,207 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// DeployRequest 部署请求
+type DeployRequest struct {
+	Runtime string            `json:"runtime"`            // 运行时（js / ts / wasm / exec）
+	Code    string            `json:"code,omitempty"`     // 函数源码（wasm 运行时为可选的胶水脚本）
+	Wasm    []byte            `json:"wasm,omitempty"`     // WebAssembly 二进制（wasm 运行时）
+	EnvVars map[string]string `json:"env_vars,omitempty"` // 环境变量
+	Version string            `json:"version,omitempty"`  // 版本号（为空时由服务端生成）
+	Alias   string            `json:"alias,omitempty"`    // 别名
+	// SmokeTest 冒烟测试（可选），新版本通过后才切换 latest 与别名
+	SmokeTest *SmokeTest `json:"smoke_test,omitempty"`
+	// Resources 资源上限（可选）
+	Resources *ResourceLimits `json:"resources,omitempty"`
+	// Sandbox 隔离设置（可选，未声明的字段使用服务端的全局默认）
+	Sandbox *SandboxConfig `json:"sandbox,omitempty"`
+	// Egress 出站规则（可选，未声明表示不限制）
+	Egress *EgressPolicy `json:"egress,omitempty"`
+	// Services 服务绑定（可选）
+	Services []ServiceBinding `json:"services,omitempty"`
+	// DurableObjects Durable Object 类与迁移（可选）
+	DurableObjects *DurableObjectConfig `json:"durable_objects,omitempty"`
+	// Database 私有 SQLite 数据库（可选）
+	Database *DatabaseConfig `json:"database,omitempty"`
+}
+
+// DatabaseConfig 函数私有的 SQLite 数据库，worker 通过以 Binding 为名的 fetch 绑定访问（POST /query、/exec、/batch）
+type DatabaseConfig struct {
+	Binding string `json:"binding,omitempty"`
+}
+
+// Migration 数据库迁移，按 Name 记录，已执行的迁移不再执行
+type Migration struct {
+	Name string `json:"name"`
+	SQL  string `json:"sql"`
+}
+
+// AppliedMigration 已执行的迁移
+type AppliedMigration struct {
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// QueryResult SQL 语句的执行结果
+type QueryResult struct {
+	Columns      []string `json:"columns"`
+	Rows         [][]any  `json:"rows"`
+	RowsAffected int64    `json:"rows_affected"`
+	LastInsertID int64    `json:"last_insert_id"`
+}
+
+// DurableObjectConfig Durable Object 类与迁移：latest 中的类在新版本中消失时，
+// 必须列入 Deleted（删除数据）或由新类的 RenamedFrom 指向它（沿用数据）
+type DurableObjectConfig struct {
+	Classes []DurableObjectClass `json:"classes,omitempty"`
+	Deleted []string             `json:"deleted,omitempty"`
+}
+
+// DurableObjectClass 入口模块导出的 Durable Object 类，以 Binding 为名注入 env
+type DurableObjectClass struct {
+	Binding     string `json:"binding"`
+	ClassName   string `json:"class_name"`
+	RenamedFrom string `json:"renamed_from,omitempty"`
+	Key         string `json:"key,omitempty"` // 数据目录，由服务端分配
+}
+
+// ServiceBinding 服务绑定：函数代码通过 env.<Name>.fetch() 调用同一平台上的另一个函数
+type ServiceBinding struct {
+	Name     string `json:"name"`            // 绑定名（worker 中 env 的属性名）
+	Function string `json:"function"`        // 目标函数
+	Alias    string `json:"alias,omitempty"` // 目标别名或版本，默认 latest
+}
+
+// EgressPolicy 函数发起 fetch 的出站规则，Mode 为空表示不限制
+type EgressPolicy struct {
+	Mode  string       `json:"mode,omitempty"` // allow（白名单）/ deny（黑名单）/ none（禁止所有出站请求）
+	Rules []EgressRule `json:"rules,omitempty"`
+}
+
+// EgressRule 出站规则：Host 为域名（*.example.com 匹配所有子域名）、IP 或 CIDR，Ports 为空时匹配所有端口
+type EgressRule struct {
+	Host  string `json:"host"`
+	Ports []int  `json:"ports,omitempty"`
+}
+
+// SandboxConfig 实例进程的隔离设置
+type SandboxConfig struct {
+	Mode    string `json:"mode,omitempty"`    // on / off
+	Network string `json:"network,omitempty"` // host / isolated（仅在开启隔离时生效）
+}
+
+// ResourceLimits 实例资源上限，零值字段表示不限制
+type ResourceLimits struct {
+	MemoryMB  int     `json:"memory_mb,omitempty"`  // 内存上限（MiB）
+	CPU       float64 `json:"cpu,omitempty"`        // CPU 配额（核数）
+	Pids      int     `json:"pids,omitempty"`       // 进程 / 线程数上限
+	OpenFiles int     `json:"open_files,omitempty"` // 打开文件数上限
+}
+
+// SmokeTest 部署时发给新版本的验证请求
+type SmokeTest struct {
+	Method       string            `json:"method,omitempty"` // 默认 GET
+	Path         string            `json:"path,omitempty"`   // 默认 /
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         string            `json:"body,omitempty"`
+	ExpectStatus int               `json:"expect_status,omitempty"` // 默认要求 2xx
+	ExpectBody   string            `json:"expect_body,omitempty"`   // 响应正文需包含的内容
+	TimeoutMs    int               `json:"timeout_ms,omitempty"`    // 默认 5000
+}
+
+// Version 函数版本
+type Version struct {
+	Version        string               `json:"version"`
+	Runtime        string               `json:"runtime"`
+	Subdomain      string               `json:"subdomain"`
+	Status         string               `json:"status"` // running / suspended
+	Port           int                  `json:"port"`
+	LastAccessed   time.Time            `json:"last_accessed"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	Aliases        []string             `json:"aliases"`
+	EnvKeys        []string             `json:"env_keys"`
+	CodeSize       int                  `json:"code_size"`
+	CodeSHA256     string               `json:"code_sha256"`
+	SourceEntry    string               `json:"source_entry,omitempty"` // TypeScript 版本的源码入口
+	WasmSize       int                  `json:"wasm_size,omitempty"`    // wasm 版本的 WebAssembly 二进制大小
+	WasmSHA256     string               `json:"wasm_sha256,omitempty"`
+	LastStartError *StartError          `json:"last_start_error,omitempty"` // 最近一次启动失败
+	Resources      ResourceLimits       `json:"resources"`
+	OOMKills       int                  `json:"oom_kills,omitempty"` // 因内存超限被结束的次数
+	LastOOMAt      *time.Time           `json:"last_oom_at,omitempty"`
+	Sandbox        SandboxConfig        `json:"sandbox"`            // 生效的隔离设置
+	Egress         EgressPolicy         `json:"egress"`             // 出站规则
+	Services       []ServiceBinding     `json:"services,omitempty"` // 服务绑定
+	DurableObjects []DurableObjectClass `json:"durable_objects,omitempty"`
+	Database       DatabaseConfig       `json:"database"` // 私有 SQLite 数据库（为空表示未申请）
+}
+
+// Function 函数详情
+type Function struct {
+	Name          string            `json:"name"`
+	LatestVersion string            `json:"latest_version"`
+	Aliases       map[string]string `json:"aliases"` // 别名 -> 版本
+	Versions      []Version         `json:"versions"`
+}
+
+// FunctionSummary 函数列表项
+type FunctionSummary struct {
+	Name          string    `json:"name"`
+	LatestVersion string    `json:"latest_version"`
+	Runtime       string    `json:"runtime"`
+	Versions      int       `json:"versions"`
+	Running       int       `json:"running"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ListOptions 函数列表过滤与分页，零值表示不限制
+type ListOptions struct {
+	Page     int
+	PageSize int
+	Name     string // 子串匹配
+	Status   string // running / suspended
+	Runtime  string
+}
+
+// FunctionList 函数列表
+type FunctionList struct {
+	Functions []FunctionSummary `json:"functions"`
+	Page      int               `json:"page"`
+	PageSize  int               `json:"pageSize"`
+	Total     int               `json:"total"`
+}
+
+// LogOptions 日志查询条件
+type LogOptions struct {
+	Version string // 为空时为 latest
+	Since   string // RFC3339 时间或 10m 这样的时长
+	Limit   int    // 最多返回的行数（最新的若干行）
+}
+
+// LogLine 一行函数日志
+type LogLine struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+// InvokeRequest 函数调用请求
+type InvokeRequest struct {
+	Target string // 版本或别名，为空时调用 latest
+	Method string // 默认 GET
+	Path   string // 默认 /
+	Header http.Header
+	Body   []byte
+}
+
+// InvokeResponse 函数调用响应
+type InvokeResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}